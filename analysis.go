@@ -3,60 +3,58 @@ package main
 import (
 	"github.com/EndlessCheng/mahjong-helper/util"
 	"fmt"
+	"errors"
 	"strings"
+	"sort"
 	"github.com/fatih/color"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
 )
 
-func alertBackwardToShanten2(results util.Hand14AnalysisResultList, incShantenResults util.Hand14AnalysisResultList) {
+func alertBackwardToShanten2(output *analysisOutput, results util.Hand14AnalysisResultList, incShantenResults util.Hand14AnalysisResultList) {
 	if len(results) == 0 || len(incShantenResults) == 0 {
 		return
 	}
 
 	if results[0].Result13.Waits.AllCount() < 10 {
 		if results[0].Result13.MixedWaitsScore < incShantenResults[0].Result13.MixedWaitsScore {
-			color.HiGreen("建议向听倒退")
+			output.addAlert(color.FgHiGreen, tr("建议向听倒退"))
 		}
 	}
 }
 
-func _printIncShantenResults14(shanten int, incShantenResults14 util.Hand14AnalysisResultList, mixedRiskTable riskTable) {
+func _addIncShantenResults14(output *analysisOutput, shanten int, incShantenResults14 util.Hand14AnalysisResultList) {
 	if len(incShantenResults14) == 0 {
 		return
 	}
 
-	if len(incShantenResults14[0].OpenTiles) > 0 {
-		fmt.Print("鸣牌后")
-	}
 	// "倒退回" +
-	fmt.Println(util.NumberToChineseShanten(shanten+1) + "：")
-	for _, result := range incShantenResults14 {
-		printWaitsWithImproves13_oneRow(result.Result13, result.DiscardTile, result.OpenTiles, mixedRiskTable)
+	title := shantenName(shanten + 1)
+	if len(incShantenResults14[0].OpenTiles) > 0 {
+		title = trf("鸣牌后%s", title)
 	}
+	output.addSection(title, true, incShantenResults14)
 }
 
-func analysisTiles34(playerInfo *model.PlayerInfo, mixedRiskTable riskTable) error {
-	humanTiles := util.Tiles34ToStr(playerInfo.HandTiles34)
-	if len(playerInfo.Melds) > 0 {
-		humanTiles += " &"
-		for i := len(playerInfo.Melds) - 1; i >= 0; i-- {
-			humanTiles += " " + util.TilesToStr(playerInfo.Melds[i].Tiles)
-		}
-	}
-	fmt.Println(humanTiles)
-	fmt.Println(strings.Repeat("=", len(humanTiles)))
+// 分析结果交给 outputRenderer 输出
+// 返回的 record 用于记录牌谱
+func analysisTiles34(playerInfo *model.PlayerInfo, mixedRiskTable riskTable) (record *analysisRecord, err error) {
+	output := &analysisOutput{hand: humanTilesOfPlayer(playerInfo), mixedRiskTable: mixedRiskTable}
 
 	countOfTiles := util.CountOfTiles34(playerInfo.HandTiles34)
 	switch countOfTiles % 3 {
 	case 1:
 		result := util.CalculateShantenWithImproves13(playerInfo)
-		fmt.Println(util.NumberToChineseShanten(result.Shanten) + "：")
-		printWaitsWithImproves13_oneRow(result, -1, nil, mixedRiskTable)
+		output.addSection(shantenName(result.Shanten), false, util.Hand14AnalysisResultList{{DiscardTile: -1, Result13: result}})
+		record = newAnalysisRecord13(result)
+		if result.Shanten == 0 {
+			alertSelfRiskOfWaits(output, playerInfo, result.Waits)
+		}
 	case 2:
 		shanten, results14, incShantenResults14 := util.CalculateShantenWithImproves14(playerInfo)
+		record = newAnalysisRecord14(shanten, results14, incShantenResults14)
 
 		if shanten == -1 {
-			color.HiRed("【已胡牌】")
+			output.addAlert(color.FgHiRed, tr("【已胡牌】"))
 			break
 		}
 
@@ -64,70 +62,112 @@ func analysisTiles34(playerInfo *model.PlayerInfo, mixedRiskTable riskTable) err
 			if len(results14) > 0 {
 				r13 := results14[0].Result13
 				if r13.RiichiPoint > 0 && r13.FuritenRate == 0 && r13.DamaPoint >= 5200 && r13.DamaWaits.AllCount() == r13.Waits.AllCount() {
-					color.HiGreen("默听打点充足：追求和率默听，追求打点立直")
+					output.addAlert(color.FgHiGreen, tr("默听打点充足：追求和率默听，追求打点立直"))
 				}
+				alertSelfRiskOfWaits(output, playerInfo, r13.Waits)
 				// 局收支相近时，提示：局收支相近，追求和率打xx，追求打点打xx
 			}
 		} else if shanten == 1 {
-			if len(playerInfo.DiscardTiles) < 9 {
-				alertBackwardToShanten2(results14, incShantenResults14)
+			if playerInfo.PassedTurns() < 9 {
+				alertBackwardToShanten2(output, results14, incShantenResults14)
 			}
 		}
 
 		if len(results14) > 0 {
-			fmt.Println(util.NumberToChineseShanten(shanten) + "：")
-			for _, result := range results14 {
-				printWaitsWithImproves13_oneRow(result.Result13, result.DiscardTile, result.OpenTiles, mixedRiskTable)
-			}
-		}
-		if len(incShantenResults14) > 0 {
-			_printIncShantenResults14(shanten, incShantenResults14, mixedRiskTable)
+			output.addSection(shantenName(shanten), false, results14)
 		}
+		_addIncShantenResults14(output, shanten, incShantenResults14)
 	default:
-		return fmt.Errorf("参数错误: %d 张牌", countOfTiles)
+		return nil, fmt.Errorf(tr("参数错误: %d 张牌"), countOfTiles)
+	}
+
+	outputRenderer.renderAnalysis(output)
+
+	return
+}
+
+// 听牌时，从他家的视角分析自家的待牌
+// 他家看来比同类牌安全的待牌（如筋牌陷阱）容易被打出；待牌整体看起来危险时，立直后他家容易弃和
+func alertSelfRiskOfWaits(output *analysisOutput, playerInfo *model.PlayerInfo, waits util.Waits) {
+	if len(playerInfo.SelfRiskTiles34) == 0 {
+		return
 	}
 
-	fmt.Println()
+	tiles := []int{}
+	for tile, left := range waits {
+		if left > 0 {
+			tiles = append(tiles, tile)
+		}
+	}
+	if len(tiles) == 0 {
+		return
+	}
+	sort.Ints(tiles)
 
-	return nil
+	risks := []string{}
+	sujiTraps := []string{}
+	multiSum := 0.0
+	leftSum := 0
+	for _, tile := range tiles {
+		multi := util.SelfRiskAgariMulti(tile, playerInfo)
+		risks = append(risks, fmt.Sprintf("%s[%.1f%%]", tileName(tile), playerInfo.SelfRiskTiles34[tile]))
+		if multi > 1 {
+			sujiTraps = append(sujiTraps, tileName(tile))
+		}
+		multiSum += multi * float64(waits[tile])
+		leftSum += waits[tile]
+	}
+
+	output.addAlert(color.FgHiCyan, trf("他家视角下待牌的铳率: %s", strings.Join(risks, " ")))
+	if len(sujiTraps) > 0 {
+		output.addAlert(color.FgHiGreen, trf("筋牌陷阱: %s，他家容易放铳", strings.Join(sujiTraps, " ")))
+	}
+	if multiSum/float64(leftSum) < 1 {
+		output.addAlert(color.FgHiYellow, tr("待牌在他家看来较危险，立直后他家容易弃和"))
+	}
 }
 
-// 分析鸣牌
+// 分析鸣牌，分析结果交给 outputRenderer 输出
 // playerInfo: 自家信息
 // targetTile34: 他家舍牌
 // isRedFive: 此舍牌是否为赤5
 // allowChi: 是否能吃
+// allowPon: 是否能碰
 // mixedRiskTable: 危险度表
-func analysisMeld(playerInfo *model.PlayerInfo, targetTile34 int, isRedFive bool, allowChi bool, mixedRiskTable riskTable) {
+// 返回的 record 用于记录牌谱，不能鸣牌时为 nil
+func analysisMeld(playerInfo *model.PlayerInfo, targetTile34 int, isRedFive bool, allowChi bool, allowPon bool, mixedRiskTable riskTable) (record *analysisRecord) {
 	// 原始手牌分析
 	result := util.CalculateShantenWithImproves13(playerInfo)
 
 	// 副露分析
-	shanten, results14, incShantenResults14 := util.CalculateMeld(playerInfo, targetTile34, isRedFive, allowChi)
+	shanten, results14, incShantenResults14 := util.CalculateMeld(playerInfo, targetTile34, isRedFive, allowChi, allowPon)
 
 	if len(results14) == 0 && len(incShantenResults14) == 0 {
 		return
 	}
+	record = newAnalysisRecord14(shanten, results14, incShantenResults14)
+	record.result13 = result
 
-	raw := util.Tiles34ToStr(playerInfo.HandTiles34) + " + " + util.Tile34ToStr(targetTile34) + "?"
-	fmt.Println(raw)
-	fmt.Println(strings.Repeat("=", len(raw)))
+	humanTargetTile := util.Tile34ToStr(targetTile34)
+	if isRedFive {
+		humanTargetTile = "0" + humanTargetTile[1:]
+	}
+	raw := humanTilesOfPlayer(playerInfo) + " + " + humanTargetTile + "?"
+	output := &analysisOutput{hand: raw, mixedRiskTable: mixedRiskTable}
+	defer outputRenderer.renderAnalysis(output)
 
-	fmt.Println("当前" + util.NumberToChineseShanten(result.Shanten) + "：")
-	printWaitsWithImproves13_oneRow(result, -1, nil, mixedRiskTable)
+	output.addSection(trf("当前%s", shantenName(result.Shanten)), false, util.Hand14AnalysisResultList{{DiscardTile: -1, Result13: result}})
 
 	if shanten == -1 {
-		color.HiRed("【已胡牌】")
+		output.addAlert(color.FgHiRed, tr("【已胡牌】"))
 		return
 	}
 
-	fmt.Print("鸣牌后")
-
 	if shanten == 0 {
 		// 局收支相近时，提示：局收支相近，追求和率打xx，追求打点打xx
 	} else if shanten == 1 {
 		//if len(playerInfo.DiscardTiles) < 9 {
-		//	alertBackwardToShanten2(results14, incShantenResults14)
+		//	alertBackwardToShanten2(output, results14, incShantenResults14)
 		//}
 	}
 
@@ -136,14 +176,11 @@ func analysisMeld(playerInfo *model.PlayerInfo, targetTile34 int, isRedFive bool
 	const maxShown = 10
 
 	if len(results14) > 0 {
-		fmt.Println(util.NumberToChineseShanten(shanten) + "：")
 		shownResults14 := results14
 		if len(shownResults14) > maxShown {
 			shownResults14 = shownResults14[:maxShown]
 		}
-		for _, result := range shownResults14 {
-			printWaitsWithImproves13_oneRow(result.Result13, result.DiscardTile, result.OpenTiles, mixedRiskTable)
-		}
+		output.addSection(trf("鸣牌后%s", shantenName(shanten)), false, shownResults14)
 	}
 
 	if len(incShantenResults14) > 0 {
@@ -151,51 +188,275 @@ func analysisMeld(playerInfo *model.PlayerInfo, targetTile34 int, isRedFive bool
 		if len(shownIncResults14) > maxShown {
 			shownIncResults14 = shownIncResults14[:maxShown]
 		}
-		_printIncShantenResults14(shanten, shownIncResults14, mixedRiskTable)
+		_addIncShantenResults14(output, shanten, shownIncResults14)
 	}
+
+	return
 }
 
-func analysisHumanTiles(humanTilesInfo *model.HumanTilesInfo) (tiles34 []int, err error) {
-	humanTiles := humanTilesInfo.HumanTiles
-	doraTiles := []int{}
-	if humanTilesInfo.HumanDoraTiles != "" {
-		doraTiles = util.MustStrToTiles(humanTilesInfo.HumanDoraTiles)
+// 将命令行中输入的手牌和局况转换成 analysisRequest，记法见 parseHumanTiles
+// 手牌后写上 + 和一张他家舍牌时分析鸣牌，如 24m 55p & (777z) + 3m
+func newAnalysisRequest(humanTilesInfo *model.HumanTilesInfo) (*analysisRequest, error) {
+	splits := strings.Split(humanTilesInfo.HumanTiles, "+")
+	if len(splits) > 2 {
+		return nil, fmt.Errorf(tr("参数错误: %s 中有多个 +"), humanTilesInfo.HumanTiles)
 	}
 
-	splits := strings.Split(humanTiles, "+")
+	r := &analysisRequest{
+		Tiles:          splits[0],
+		Dora:           humanTilesInfo.HumanDoraTiles,
+		DoraIndicators: humanTilesInfo.HumanDoraIndicators,
+		RoundWind:      humanTilesInfo.HumanRoundWind,
+		SelfWind:       humanTilesInfo.HumanSelfWind,
+		IsRiichi:       humanTilesInfo.IsRiichi,
+		Turn:           humanTilesInfo.Turn,
+		Discards:       humanTilesInfo.HumanDiscardTiles,
+		Seen:           humanTilesInfo.HumanSeenTiles,
+		AllowChi:       true,
+	}
 	if len(splits) == 2 {
-		tiles34, err = util.StrToTiles34(splits[0])
-		if err != nil {
-			return
-		}
-
 		rawTargetTile := strings.TrimSpace(splits[1])
 		if len(rawTargetTile) > 2 {
 			rawTargetTile = rawTargetTile[:2]
 		}
-		var targetTile34 int
-		targetTile34, err = util.StrToTile34(rawTargetTile)
-		if err != nil {
+		r.TargetTile = rawTargetTile
+	}
+	return r, nil
+}
+
+// 分析命令行中输入的手牌，返回的 playerInfo 为分析前的自家信息
+func analysisHumanTiles(humanTilesInfo *model.HumanTilesInfo) (playerInfo *model.PlayerInfo, err error) {
+	r, err := newAnalysisRequest(humanTilesInfo)
+	if err != nil {
+		return nil, err
+	}
+	if playerInfo, err = r.playerInfo(); err != nil {
+		return nil, err
+	}
+	//playerInfo.IsTsumo = true
+	_, err = r.analysisPlayerInfo(copyPlayerInfo(playerInfo))
+	return
+}
+
+// POST /analysis 的请求
+// 牌均为 123m 456p 这样的格式，0 表示赤5，如 {"tiles":"24m 55p 34789s","melds":[{"type":"pon","tiles":"777z"}],"dora_indicators":"1m","target_tile":"3m","allow_chi":true}
+type analysisRequest struct {
+	Tiles          string        `json:"tiles"` // 手牌，也可以用 & 写上副露，记法见 parseHumanTiles
+	Melds          []*meldRecord `json:"melds"`
+	Dora           string        `json:"dora"` // 宝牌，与宝牌指示牌二选一
+	DoraIndicators string        `json:"dora_indicators"`
+	RoundWind      string        `json:"round_wind"` // 场风，如 1z、E 或 E3，默认为东
+	SelfWind       string        `json:"self_wind"`  // 自风，默认为东但按子家计算，指定自风为东时为亲家
+	IsRiichi       bool          `json:"riichi"`
+	Turn           int           `json:"turn"`     // 巡目，默认按自家舍牌的个数推算
+	Discards       string        `json:"discards"` // 自家舍牌，用于判断振听
+	Seen           string        `json:"seen"`     // 他家舍牌、副露等其余可见的牌，这些牌不在牌山中
+
+	// 他家舍牌，不为空时分析鸣牌
+	TargetTile string `json:"target_tile"`
+	AllowChi   bool   `json:"allow_chi"`
+}
+
+func parseMeldRecord(r *meldRecord) (meld model.Meld, err error) {
+	meld.MeldType = -1
+	for meldType, name := range meldRecordTypes {
+		if r.Type == name {
+			meld.MeldType = meldType
+		}
+	}
+	if meld.MeldType == -1 {
+		return meld, fmt.Errorf(tr("未知的副露类型 %s"), r.Type)
+	}
+	if meld.Tiles, err = util.StrToTiles(r.Tiles); err != nil {
+		return
+	}
+	sort.Ints(meld.Tiles)
+	meld.ContainRedFive = r.ContainRedFive || strings.Contains(r.Tiles, "0")
+
+	tiles := meld.Tiles
+	isKan := meld.IsKan()
+	isValid := false
+	switch {
+	case meld.MeldType == model.MeldTypeChi:
+		isValid = len(tiles) == 3 && tiles[0] < 27 && tiles[0]%9 <= 6 && tiles[1] == tiles[0]+1 && tiles[2] == tiles[0]+2
+	case isKan && len(tiles) == 4, !isKan && len(tiles) == 3:
+		isValid = true
+		for _, tile := range tiles {
+			isValid = isValid && tile == tiles[0]
+		}
+	}
+	if !isValid {
+		return meld, fmt.Errorf(tr("副露 %s 不是%s"), r.Tiles, r.Type)
+	}
+	if meld.ContainRedFive && (tiles[len(tiles)-1] >= 27 || tiles[0]%9 > 4 || tiles[len(tiles)-1]%9 < 4) {
+		return meld, fmt.Errorf(tr("副露 %s 中没有 %s"), r.Tiles, tr("赤5"))
+	}
+
+	meld.CalledTile = tiles[0]
+	if r.CalledTile != "" {
+		if meld.CalledTile, err = util.StrToTile34(r.CalledTile); err != nil {
 			return
 		}
+	}
+	for i, tile := range tiles {
+		if tile == meld.CalledTile {
+			meld.SelfTiles = append(append([]int{}, tiles[:i]...), tiles[i+1:]...)
+			return
+		}
+	}
+	return meld, fmt.Errorf(tr("副露 %s 中没有 %s"), r.Tiles, r.CalledTile)
+}
 
-		var melds []model.Meld
-		//melds = append(melds, model.Meld{MeldType: model.MeldTypePon, Tiles: util.MustStrToTiles("777z")})
-		playerInfo := model.NewSimplePlayerInfo(tiles34, melds)
-		playerInfo.DoraTiles = doraTiles
-		isRedFive := false
-		analysisMeld(playerInfo, targetTile34, isRedFive, true, nil)
-		return
+func (r *analysisRequest) playerInfo() (*model.PlayerInfo, error) {
+	playerInfo, err := parseHumanTiles(r.Tiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// 副露、宝牌指示牌、舍牌和其余可见的牌均不在牌山中
+	leftTiles := func(tiles []int) {
+		for _, tile := range tiles {
+			playerInfo.LeftTiles34[tile]--
+		}
+	}
+	for _, meldRecord := range r.Melds {
+		meld, err := parseMeldRecord(meldRecord)
+		if err != nil {
+			return nil, err
+		}
+		playerInfo.Melds = append(playerInfo.Melds, meld)
+		if meld.ContainRedFive {
+			playerInfo.NumRedFives[meld.Tiles[0]/9]++
+		}
+		leftTiles(meld.Tiles)
+	}
+	if r.Dora != "" {
+		if playerInfo.DoraTiles, err = util.StrToTiles(r.Dora); err != nil {
+			return nil, err
+		}
+	}
+	if r.DoraIndicators != "" {
+		doraIndicators, err := util.StrToTiles(r.DoraIndicators)
+		if err != nil {
+			return nil, err
+		}
+		playerInfo.DoraTiles = append(playerInfo.DoraTiles, model.DoraList(doraIndicators)...)
+		leftTiles(doraIndicators)
+	}
+	if r.Discards != "" {
+		if playerInfo.DiscardTiles, err = util.StrToTiles(r.Discards); err != nil {
+			return nil, err
+		}
+		leftTiles(playerInfo.DiscardTiles)
+	}
+	if r.Seen != "" {
+		seenTiles, err := util.StrToTiles(r.Seen)
+		if err != nil {
+			return nil, err
+		}
+		leftTiles(seenTiles)
+	}
+	for _, tile := range playerInfo.LeftTiles34 {
+		if tile < 0 {
+			return nil, errors.New(tr("参数错误: 某种牌超过了 4 张"))
+		}
+	}
+
+	for _, wind := range []struct {
+		str  string
+		tile *int
+	}{{r.RoundWind, &playerInfo.RoundWindTile}, {r.SelfWind, &playerInfo.SelfWindTile}} {
+		if wind.str == "" {
+			continue
+		}
+		tile, err := parseWindTile(wind.str)
+		if err != nil {
+			return nil, err
+		}
+		*wind.tile = tile
+	}
+	// 没有指定自风时按子家计算
+	playerInfo.IsParent = r.SelfWind != "" && playerInfo.SelfWindTile == 27
+
+	if r.IsRiichi && playerInfo.IsNaki() {
+		return nil, errors.New(tr("参数错误: 副露后不能立直"))
 	}
+	playerInfo.IsRiichi = r.IsRiichi
+	if r.Turn < 0 {
+		return nil, fmt.Errorf(tr("参数错误: 第 %d 巡"), r.Turn)
+	}
+	playerInfo.Turn = r.Turn
+	return playerInfo, nil
+}
 
-	tiles34, err = util.StrToTiles34(humanTiles)
+// 风牌可以写成 1z、E、东，也可以写成 E3、东3-1 这样的局名
+func parseWindTile(humanWind string) (int, error) {
+	humanWind = strings.TrimSpace(humanWind)
+	if roundNumber, _, err := parseRoundName(humanWind); err == nil {
+		return 27 + roundNumber/4, nil
+	}
+	for i, wind := range []rune("ESWN") {
+		if humanWind == string(wind) || humanWind == string([]rune("东南西北")[i]) {
+			return 27 + i, nil
+		}
+	}
+	tile, err := util.StrToTile34(humanWind)
+	if err != nil || tile < 27 || tile > 30 {
+		return -1, fmt.Errorf(tr("参数错误: %s 不是风牌"), humanWind)
+	}
+	return tile, nil
+}
+
+// POST /analysis 的响应，即 util 中完整的分析结果
+type analysisResponse struct {
+	Shanten int `json:"shanten"`
+
+	// 13 张手牌的分析结果，鸣牌分析时为不鸣牌的分析结果
+	Result13 *util.Hand13AnalysisResult `json:"result13,omitempty"`
+
+	// 14 张手牌（或鸣牌后）各个切牌的分析结果，按照推荐顺序
+	Results14           util.Hand14AnalysisResultList `json:"results14,omitempty"`
+	IncShantenResults14 util.Hand14AnalysisResultList `json:"inc_shanten_results14,omitempty"` // 向听倒退的切牌
+}
+
+// 分析请求中的手牌，鸣牌分析时若不能鸣牌，返回的 Results14 为空
+func (r *analysisRequest) analysis() (*analysisResponse, error) {
+	playerInfo, err := r.playerInfo()
 	if err != nil {
-		return
+		return nil, err
+	}
+	record, err := r.analysisPlayerInfo(playerInfo)
+	if err != nil {
+		return nil, err
 	}
+	return &analysisResponse{
+		Shanten:             record.Shanten,
+		Result13:            record.result13,
+		Results14:           record.results14,
+		IncShantenResults14: record.incShantenResults14,
+	}, nil
+}
 
-	playerInfo := model.NewSimplePlayerInfo(tiles34, nil)
-	playerInfo.DoraTiles = doraTiles
-	//playerInfo.IsTsumo = true
-	err = analysisTiles34(playerInfo, nil)
+func (r *analysisRequest) analysisPlayerInfo(playerInfo *model.PlayerInfo) (record *analysisRecord, err error) {
+	if r.TargetTile == "" {
+		return analysisTiles34(playerInfo, nil)
+	}
+
+	targetTile34, err := util.StrToTile34(r.TargetTile)
+	if err != nil {
+		return
+	}
+	if countOfTiles := util.CountOfTiles34(playerInfo.HandTiles34); countOfTiles%3 != 1 {
+		return nil, fmt.Errorf(tr("参数错误: 鸣牌分析时手牌不能为 %d 张"), countOfTiles)
+	}
+	if playerInfo.LeftTiles34[targetTile34] == 0 {
+		return nil, fmt.Errorf(tr("参数错误: %s 超过了 4 张"), r.TargetTile)
+	}
+	playerInfo.LeftTiles34[targetTile34]--
+	isRedFive := targetTile34 < 27 && strings.TrimSpace(r.TargetTile)[0] == '0'
+	if record = analysisMeld(playerInfo, targetTile34, isRedFive, r.AllowChi, true, nil); record == nil {
+		result13 := util.CalculateShantenWithImproves13(playerInfo)
+		record = &analysisRecord{Shanten: result13.Shanten, result13: result13}
+	}
 	return
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
-	"testing"
+	"bytes"
+	"github.com/EndlessCheng/mahjong-helper/util"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"strings"
+	"testing"
 )
 
 func TestAnalysis(t *testing.T) {
@@ -52,3 +55,85 @@ func TestAnalysis(t *testing.T) {
 	raw = "3456667m 34566p 5s"
 	analysisHumanTiles(model.NewSimpleHumanTilesInfo(raw))
 }
+
+func TestAnalysisHumanTilesWithSituation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r, _ := newRenderer("plain", buf)
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	playerInfo, err := analysisHumanTiles(&model.HumanTilesInfo{
+		HumanTiles:          "123m 456p 789s 34m 22z",
+		HumanDoraIndicators: "1z",
+		HumanDiscardTiles:   "2m5z",
+		HumanSeenTiles:      "22m 3z",
+		HumanRoundWind:      "S2",
+		HumanSelfWind:       "南",
+		Turn:                5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playerInfo.RoundWindTile != 28 || playerInfo.SelfWindTile != 28 || playerInfo.IsParent || playerInfo.PassedTurns() != 4 {
+		t.Fatal("局况有误", playerInfo)
+	}
+	if len(playerInfo.DoraTiles) != 1 || playerInfo.DoraTiles[0] != 28 || playerInfo.CountDora() != 2 {
+		t.Fatal("宝牌有误", playerInfo.DoraTiles)
+	}
+	if playerInfo.LeftTiles34[1] != 0 || playerInfo.LeftTiles34[27] != 3 || playerInfo.LeftTiles34[29] != 3 {
+		t.Fatal("剩余牌有误", playerInfo.LeftTiles34)
+	}
+	if !strings.Contains(buf.String(), "[振听]") {
+		t.Fatal("应当振听", buf.String())
+	}
+
+	playerInfo, err = analysisHumanTiles(&model.HumanTilesInfo{HumanTiles: "123m 456p 789s 34m 22z", HumanSelfWind: "1z", IsRiichi: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !playerInfo.IsParent || !playerInfo.IsRiichi || playerInfo.RoundWindTile != 27 {
+		t.Fatal("局况有误", playerInfo)
+	}
+
+	for _, humanTilesInfo := range []*model.HumanTilesInfo{
+		{HumanTiles: "123m 456p 789s 34m 22z", HumanSelfWind: "5z"},
+		{HumanTiles: "123m 456p 789s 34m 22z", HumanRoundWind: "X"},
+		{HumanTiles: "456p 789s 34m 22z & (123m)", IsRiichi: true},
+		{HumanTiles: "123m 456p 789s 34m 22z", HumanSeenTiles: "2222m"},
+	} {
+		if _, err := analysisHumanTiles(humanTilesInfo); err == nil {
+			t.Fatal(humanTilesInfo, "应当返回错误")
+		}
+	}
+}
+
+func TestAlertSelfRiskOfWaits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r, _ := newRenderer("plain", buf)
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	// 听 25m，他家视角下 2m 很安全、5m 很危险
+	playerInfo := model.NewSimplePlayerInfo(util.MustStrToTiles34("123m 456p 789s 34m 22z"), nil)
+	playerInfo.SelfRiskTiles34 = make([]float64, 34)
+	playerInfo.SelfRiskTiles34[1] = 0.5
+	playerInfo.SelfRiskTiles34[4] = 50
+	if _, err := analysisTiles34(playerInfo, nil); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "他家视角下待牌的铳率: 2万[0.5%] 5万[50.0%]") || !strings.Contains(out, "筋牌陷阱: 2万") || strings.Contains(out, "弃和") {
+		t.Fatal("输出有误", out)
+	}
+
+	// 待牌都很危险
+	buf.Reset()
+	playerInfo.SelfRiskTiles34[1] = 50
+	if _, err := analysisTiles34(playerInfo, nil); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if strings.Contains(out, "筋牌陷阱") || !strings.Contains(out, "立直后他家容易弃和") {
+		t.Fatal("输出有误", out)
+	}
+}
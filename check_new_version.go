@@ -42,6 +42,6 @@ func alertNewVersion(currentVersionTag string) {
 	}
 
 	if latestVersionTag > currentVersionTag {
-		color.HiGreen("检测到新版本: %s！请前往 %s 下载", latestVersionTag, latestReleasePage)
+		color.HiGreen(tr("检测到新版本: %s！请前往 %s 下载"), latestVersionTag, latestReleasePage)
 	}
 }
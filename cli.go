@@ -10,9 +10,11 @@ import (
 )
 
 func printAccountInfo(accountID int) {
-	fmt.Printf("您的账号 ID 为 ")
-	color.New(color.FgHiGreen).Printf("%d", accountID)
-	fmt.Printf("，该数字为雀魂服务器账号数据库中的 ID，该值越小表示您的注册时间越早\n")
+	text := &styledText{}
+	text.print(tr("您的账号 ID 为 "))
+	text.print(fmt.Sprint(accountID), color.FgHiGreen)
+	text.print(tr("，该数字为雀魂服务器账号数据库中的 ID，该值越小表示您的注册时间越早\n"))
+	outputRenderer.renderText("message", text)
 }
 
 //
@@ -25,12 +27,12 @@ type handsRisk struct {
 // 34 种牌的危险度
 type riskTable util.RiskTiles34
 
-func (t riskTable) printWithHands(hands []int, fixedRiskMulti float64) {
+func (t riskTable) printWithHands(w styledWriter, hands []int, fixedRiskMulti float64) {
 	// 打印铳率=0的牌（现物，或NC且剩余数=0）
 	safeCount := 0
 	for i, c := range hands {
 		if c > 0 && t[i] == 0 {
-			fmt.Printf(" " + util.MahjongZH[i])
+			w.print(" " + tileName(i))
 			safeCount++
 		}
 	}
@@ -47,11 +49,11 @@ func (t riskTable) printWithHands(hands []int, fixedRiskMulti float64) {
 	})
 	if len(handsRisks) > 0 {
 		if safeCount > 0 {
-			fmt.Print(" |")
+			w.print(" |")
 		}
 		for _, hr := range handsRisks {
 			// 颜色考虑了听牌率
-			color.New(getNumRiskColor(hr.risk * fixedRiskMulti)).Printf(" " + util.MahjongZH[hr.tile])
+			w.print(" "+tileName(hr.tile), getNumRiskColor(hr.risk*fixedRiskMulti))
 		}
 	}
 }
@@ -77,6 +79,22 @@ type riskInfo struct {
 
 type riskInfoList []riskInfo
 
+// 自家剩余无筋不超过该值时，提示自家牌河易读
+const selfReadableNoSujiLimit = 6
+
+// 他家视角下自家的牌河，剩余无筋越少，自家的两面待越容易被读出
+func (l riskInfoList) isSelfReadable() bool {
+	return len(l) > 0 && l[0].riskTable != nil && len(l[0].leftNoSujiTiles) <= selfReadableNoSujiLimit
+}
+
+func (l riskInfoList) printSelfReadability(w styledWriter) {
+	w.print(tr("自家牌河:") + " " + trf("[%d无筋]", len(l[0].leftNoSujiTiles)))
+	if l.isSelfReadable() {
+		w.print(" " + tr("牌河易读，两面待容易被看穿"), color.FgHiYellow)
+	}
+	w.print("\n")
+}
+
 func (l riskInfoList) mixedRiskTable() riskTable {
 	mixedRiskTable := make(riskTable, 34)
 	for i := range mixedRiskTable {
@@ -90,39 +108,45 @@ func (l riskInfoList) mixedRiskTable() riskTable {
 	return mixedRiskTable
 }
 
-func (l riskInfoList) printWithHands(hands []int, leftCounts []int) {
+func (l riskInfoList) printWithHands(w styledWriter, hands []int, leftCounts []int) {
 	const tenpaiRateLimit = 50.0
+
+	// 自家牌河易读时提示
+	if l.isSelfReadable() {
+		l.printSelfReadability(w)
+	}
+
 	dangerousPlayerCount := 0
 	// 打印安牌，危险牌
-	names := []string{"", "下家", "对家", "上家"}
+	names := playerNames(len(l))
 	for i := len(l) - 1; i >= 1; i-- {
 		// 听牌率超过 50% 就打印铳率
 		tenpaiRate := l[i].tenpaiRate
 		if len(l[i].riskTable) > 0 && (debugMode || tenpaiRate > tenpaiRateLimit) {
 			dangerousPlayerCount++
-			fmt.Print(names[i] + "安牌:")
+			w.print(trf("%s安牌:", names[i]))
 			//if debugMode {
 			//fmt.Printf("(%d*%2.2f%%听牌率)", int(l[i]._ronPoint), l[i].tenpaiRate)
 			//}
-			l[i].riskTable.printWithHands(hands, tenpaiRate/100)
+			l[i].riskTable.printWithHands(w, hands, tenpaiRate/100)
 
-			fmt.Print(" ")
+			w.print(" ")
 
 			// 打印无筋数量和种类
 			const badMachiLimit = 3
 			noSujiInfo := "" // util.TilesToStr(l[i].leftNoSujiTiles)
 			if len(l[i].leftNoSujiTiles) == 0 {
-				noSujiInfo = "愚形听牌/振听"
+				noSujiInfo = tr("愚形听牌/振听")
 			} else if len(l[i].leftNoSujiTiles) <= badMachiLimit {
-				noSujiInfo = "可能愚形听牌/振听"
+				noSujiInfo = tr("可能愚形听牌/振听")
 			}
 			if noSujiInfo != "" {
-				fmt.Printf("[%d无筋: %s]", len(l[i].leftNoSujiTiles), noSujiInfo)
+				w.print(trf("[%d无筋: %s]", len(l[i].leftNoSujiTiles), noSujiInfo))
 			} else {
-				fmt.Printf("[%d无筋]", len(l[i].leftNoSujiTiles))
+				w.print(trf("[%d无筋]", len(l[i].leftNoSujiTiles)))
 			}
 
-			fmt.Println()
+			w.print("\n")
 		}
 	}
 
@@ -134,10 +158,10 @@ func (l riskInfoList) printWithHands(hands []int, leftCounts []int) {
 		}
 	}
 	if dangerousPlayerCount > 0 && mixedPlayers > 1 {
-		fmt.Print("综合安牌:")
+		w.print(tr("综合安牌:"))
 		mixedRiskTable := l.mixedRiskTable()
-		mixedRiskTable.printWithHands(hands, 1)
-		fmt.Println()
+		mixedRiskTable.printWithHands(w, hands, 1)
+		w.print("\n")
 	}
 
 	// 打印因 NC OC 产生的安牌
@@ -146,18 +170,18 @@ func (l riskInfoList) printWithHands(hands []int, leftCounts []int) {
 		ncSafeTileList := util.CalcNCSafeTiles(leftCounts).FilterWithHands(hands)
 		ocSafeTileList := util.CalcOCSafeTiles(leftCounts).FilterWithHands(hands)
 		if len(ncSafeTileList) > 0 {
-			fmt.Printf("NC:")
+			w.print("NC:")
 			for _, safeTile := range ncSafeTileList {
-				fmt.Printf(" " + util.MahjongZH[safeTile.Tile34])
+				w.print(" " + tileName(safeTile.Tile34))
 			}
-			fmt.Println()
+			w.print("\n")
 		}
 		if len(ocSafeTileList) > 0 {
-			fmt.Printf("OC:")
+			w.print("OC:")
 			for _, safeTile := range ocSafeTileList {
-				fmt.Printf(" " + util.MahjongZH[safeTile.Tile34])
+				w.print(" " + tileName(safeTile.Tile34))
 			}
-			fmt.Println()
+			w.print("\n")
 		}
 
 		// 下面这个是另一种显示方式：显示壁牌
@@ -170,7 +194,7 @@ func (l riskInfoList) printWithHands(hands []int, leftCounts []int) {
 		//		printedNC = true
 		//		fmt.Printf("NC:")
 		//	}
-		//	fmt.Printf(" " + util.MahjongZH[i])
+		//	fmt.Print(" " + util.MahjongZH[i])
 		//}
 		//if printedNC {
 		//	fmt.Println()
@@ -184,12 +208,12 @@ func (l riskInfoList) printWithHands(hands []int, leftCounts []int) {
 		//		printedOC = true
 		//		fmt.Printf("OC:")
 		//	}
-		//	fmt.Printf(" " + util.MahjongZH[i])
+		//	fmt.Print(" " + util.MahjongZH[i])
 		//}
 		//if printedOC {
 		//	fmt.Println()
 		//}
-		fmt.Println()
+		w.print("\n")
 	}
 }
 
@@ -229,25 +253,25 @@ var yakuTypesToAlert = []int{
 
 */
 // 打印何切分析结果（双行）
-func printWaitsWithImproves13_twoRows(result13 *util.Hand13AnalysisResult, discardTile34 int, openTiles34 []int) {
+func printWaitsWithImproves13_twoRows(w styledWriter, result13 *util.Hand13AnalysisResult, discardTile34 int, openTiles34 []int) {
 	shanten := result13.Shanten
 	waits := result13.Waits
 
 	waitsCount, waitTiles := waits.ParseIndex()
 	c := getWaitsCountColor(shanten, float64(waitsCount))
-	color.New(c).Printf("%-6d", waitsCount)
+	w.print(fmt.Sprintf("%-6d", waitsCount), c)
 	if discardTile34 != -1 {
 		if len(openTiles34) > 0 {
 			meldType := "吃"
 			if openTiles34[0] == openTiles34[1] {
 				meldType = "碰"
 			}
-			color.New(color.FgHiWhite).Printf("%s%s", string([]rune(util.MahjongZH[openTiles34[0]])[:1]), util.MahjongZH[openTiles34[1]])
-			fmt.Printf("%s，", meldType)
+			w.print(openTilesName(openTiles34), color.FgHiWhite)
+			w.print(tr(meldType + "，"))
 		}
-		fmt.Print("切 ")
-		fmt.Print(util.MahjongZH[discardTile34])
-		fmt.Print(" ")
+		w.print(tr("切 "))
+		w.print(tileName(discardTile34))
+		w.print(" ")
 	}
 	//fmt.Print("等")
 	//if shanten <= 1 {
@@ -260,27 +284,26 @@ func printWaitsWithImproves13_twoRows(result13 *util.Hand13AnalysisResult, disca
 	//	}
 	//	fmt.Println("]")
 	//} else {
-	fmt.Println(util.TilesToStrWithBracket(waitTiles))
+	w.print(util.TilesToStrWithBracket(waitTiles) + "\n")
 	//}
 
 	if len(result13.Improves) > 0 {
-		fmt.Printf("%-6.2f[%2d 改良]", result13.AvgImproveWaitsCount, len(result13.Improves))
+		w.print(trf("%-6.2f[%2d 改良]", result13.AvgImproveWaitsCount, len(result13.Improves)))
 	} else {
-		fmt.Print(strings.Repeat(" ", 15))
+		w.print(strings.Repeat(" ", 15))
 	}
 
-	fmt.Print(" ")
+	w.print(" ")
 
 	if shanten >= 1 {
 		c := getWaitsCountColor(shanten-1, result13.AvgNextShantenWaitsCount)
-		color.New(c).Printf("%5.2f", result13.AvgNextShantenWaitsCount)
-		fmt.Printf(" %s", util.NumberToChineseShanten(shanten-1))
+		w.print(fmt.Sprintf("%5.2f", result13.AvgNextShantenWaitsCount), c)
 		if shanten >= 2 {
-			fmt.Printf("进张")
+			w.print(" " + shantenName(shanten-1) + tr("进张"))
 		} else { // shanten == 1
-			fmt.Printf("数")
+			w.print(" " + tr("听牌数"))
 			if showAgariAboveShanten1 {
-				fmt.Printf("（%.2f%% 参考和率）", result13.AvgAgariRate)
+				w.print(trf("（%.2f%% 参考和率）", result13.AvgAgariRate))
 			}
 		}
 		if showScore {
@@ -288,13 +311,13 @@ func printWaitsWithImproves13_twoRows(result13 *util.Hand13AnalysisResult, disca
 			//for i := 2; i <= shanten; i++ {
 			//	mixedScore /= 4
 			//}
-			fmt.Printf("（%.2f 综合分）", mixedScore)
+			w.print(trf("（%.2f 综合分）", mixedScore))
 		}
 	} else { // shanten == 0
-		fmt.Printf("%5.2f%% 参考和率", result13.AvgAgariRate)
+		w.print(trf("%5.2f%% 参考和率", result13.AvgAgariRate))
 	}
 
-	fmt.Println()
+	w.print("\n")
 }
 
 /*
@@ -310,21 +333,21 @@ func printWaitsWithImproves13_twoRows(result13 *util.Hand13AnalysisResult, disca
 
 */
 // 打印何切分析结果（单行）
-func printWaitsWithImproves13_oneRow(result13 *util.Hand13AnalysisResult, discardTile34 int, openTiles34 []int, mixedRiskTable riskTable) {
+func printWaitsWithImproves13_oneRow(w styledWriter, result13 *util.Hand13AnalysisResult, discardTile34 int, openTiles34 []int, mixedRiskTable riskTable) {
 	shanten := result13.Shanten
 
 	// 进张数
 	waitsCount, waitTiles := result13.Waits.ParseIndex()
 	c := getWaitsCountColor(shanten, float64(waitsCount))
-	color.New(c).Printf("%2d", waitsCount)
+	w.print(fmt.Sprintf("%2d", waitsCount), c)
 	// 改良进张均值
 	if len(result13.Improves) > 0 {
-		fmt.Printf("[%5.2f]", result13.AvgImproveWaitsCount)
+		w.print(fmt.Sprintf("[%5.2f]", result13.AvgImproveWaitsCount))
 	} else {
-		fmt.Print(strings.Repeat(" ", 7))
+		w.print(strings.Repeat(" ", 7))
 	}
 
-	fmt.Print(" ")
+	w.print(" ")
 
 	// 是否为3k+2张牌的何切分析
 	if discardTile34 != -1 {
@@ -334,12 +357,12 @@ func printWaitsWithImproves13_oneRow(result13 *util.Hand13AnalysisResult, discar
 			if openTiles34[0] == openTiles34[1] {
 				meldType = "碰"
 			}
-			color.New(color.FgHiWhite).Printf("%s%s", string([]rune(util.MahjongZH[openTiles34[0]])[:1]), util.MahjongZH[openTiles34[1]])
-			fmt.Printf("%s,", meldType)
+			w.print(openTilesName(openTiles34), color.FgHiWhite)
+			w.print(tr(meldType + ","))
 		}
 		// 舍牌
-		fmt.Print("切")
-		tileZH := util.MahjongZH[discardTile34]
+		w.print(tr("切"))
+		tileZH := tileName(discardTile34)
 		if discardTile34 >= 27 {
 			tileZH = " " + tileZH
 		}
@@ -347,63 +370,66 @@ func printWaitsWithImproves13_oneRow(result13 *util.Hand13AnalysisResult, discar
 			// 若有实际危险度，则根据实际危险度来显示舍牌危险度
 			risk := mixedRiskTable[discardTile34]
 			if risk == 0 {
-				fmt.Print(tileZH)
+				w.print(tileZH)
 			} else {
-				color.New(getNumRiskColor(risk)).Print(tileZH)
+				w.print(tileZH, getNumRiskColor(risk))
 			}
 		} else {
-			fmt.Print(tileZH)
+			w.print(tileZH)
 		}
 	}
 
-	fmt.Print(" => ")
+	w.print(" => ")
 
 	if shanten >= 1 {
 		// 前进后的进张数均值
 		incShanten := shanten - 1
 		c := getWaitsCountColor(incShanten, result13.AvgNextShantenWaitsCount)
-		color.New(c).Printf("%5.2f", result13.AvgNextShantenWaitsCount)
-		fmt.Printf("%s", util.NumberToChineseShanten(incShanten))
+		w.print(fmt.Sprintf("%5.2f", result13.AvgNextShantenWaitsCount), c)
+		if lang == langEN {
+			w.print(" ")
+		}
 		if incShanten >= 1 {
+			w.print(shantenName(incShanten))
 			//fmt.Printf("进张")
 		} else { // incShanten == 0
-			fmt.Printf("数")
+			w.print(tr("听牌数"))
 			//if showAgariAboveShanten1 {
 			//	fmt.Printf("（%.2f%% 参考和率）", result13.AvgAgariRate)
 			//}
 		}
 	} else { // shanten == 0
 		// 前进后的和率
-		fmt.Printf("%5.2f%% 参考和率", result13.AvgAgariRate)
+		w.print(trf("%5.2f%% 参考和率", result13.AvgAgariRate))
 	}
 
 	// 手牌速度，用于快速过庄
 	if result13.MixedWaitsScore > 0 && shanten >= 1 && shanten <= 2 {
-		fmt.Print(" ")
+		w.print(" ")
 		mixedScore := result13.MixedWaitsScore
-		fmt.Printf("[%5.2f速度]", mixedScore)
+		w.print(trf("[%5.2f速度]", mixedScore))
 	}
 
 	// 局收支
 	if showScore && result13.MixedRoundPoint != 0.0 {
-		fmt.Print(" ")
-		color.New(color.FgHiGreen).Printf("[局收支%4d]", int(math.Round(result13.MixedRoundPoint)))
+		w.print(" ")
+		w.print(trf("[局收支%4d]", int(math.Round(result13.MixedRoundPoint))), color.FgHiGreen)
 	}
 
 	// (默听)荣和点数
 	if result13.DamaPoint > 0 {
-		fmt.Print(" ")
-		ronType := "荣和"
+		w.print(" ")
+		ronFormat := "[荣和%d]"
 		if !result13.IsNaki {
-			ronType = "默听"
+			ronFormat = "[默听%d]"
 		}
-		color.New(color.FgHiGreen).Printf("[%s%d]", ronType, int(math.Round(result13.DamaPoint)))
+		w.print(trf(ronFormat, int(math.Round(result13.DamaPoint))), color.FgHiGreen)
 	}
 
 	// 立直点数，考虑了自摸、一发、里宝
 	if result13.RiichiPoint > 0 {
-		fmt.Print(" ")
-		color.New(color.FgHiGreen).Printf("[立直%d]", int(math.Round(result13.RiichiPoint)))
+		w.print(" ")
+		w.print(trf("[立直%d]", int(math.Round(result13.RiichiPoint))), color.FgHiGreen)
 	}
 
 	if len(result13.YakuTypes) > 0 && result13.Shanten <= 3 {
@@ -419,51 +445,51 @@ func printWaitsWithImproves13_oneRow(result13 *util.Hand13AnalysisResult, discar
 			}
 			if len(shownYakuTypes) > 0 {
 				sort.Ints(shownYakuTypes)
-				fmt.Print(" ")
-				color.New(color.FgHiGreen).Printf(util.YakuTypesToStr(shownYakuTypes))
+				w.print(" ")
+				w.print(yakuTypesToStr(shownYakuTypes), color.FgHiGreen)
 			}
 		} else {
-			fmt.Print(" ")
-			color.New(color.FgHiGreen).Printf(util.YakuTypesWithDoraToStr(result13.YakuTypes, result13.DoraCount))
+			w.print(" ")
+			w.print(yakuTypesWithDoraToStr(result13.YakuTypes, result13.DoraCount), color.FgHiGreen)
 		}
 	} else if shanten >= 0 && shanten <= 1 && result13.IsNaki {
 		// 鸣牌时的无役提示
-		fmt.Print(" ")
-		color.New(color.FgHiRed).Printf("[无役]")
+		w.print(" ")
+		w.print(tr("[无役]"), color.FgHiRed)
 	}
 
 	// 振听提示
 	if result13.FuritenRate > 0 {
-		fmt.Print(" ")
+		w.print(" ")
 		if result13.FuritenRate < 1 {
-			color.New(color.FgHiYellow).Printf("[可能振听]")
+			w.print(tr("[可能振听]"), color.FgHiYellow)
 		} else {
-			color.New(color.FgHiRed).Printf("[振听]")
+			w.print(tr("[振听]"), color.FgHiRed)
 		}
 	}
 
 	// 改良数
 	if showScore {
-		fmt.Print(" ")
+		w.print(" ")
 		if len(result13.Improves) > 0 {
-			fmt.Printf("[%2d改良]", len(result13.Improves))
+			w.print(trf("[%2d改良]", len(result13.Improves)))
 		} else {
-			fmt.Print(strings.Repeat(" ", 4))
-			fmt.Print(strings.Repeat("　", 2)) // 全角空格
+			w.print(strings.Repeat(" ", 4))
+			w.print(strings.Repeat("　", 2)) // 全角空格
 		}
 	}
 
 	// 进张类型
-	fmt.Print(" ")
-	fmt.Print(util.TilesToStrWithBracket(waitTiles))
+	w.print(" ")
+	w.print(util.TilesToStrWithBracket(waitTiles))
 
 	//
 
-	fmt.Println()
+	w.print("\n")
 
 	if showImproveDetail {
 		for tile, waits := range result13.Improves {
-			fmt.Printf("摸 %s 改良成 %s\n", util.Mahjong[tile], waits.String())
+			w.print(trf("摸 %s 改良成 %s\n", util.Mahjong[tile], waits.String()))
 		}
 	}
 }
@@ -13,6 +13,12 @@ const (
 
 type gameConfig struct {
 	MajsoulAccountID int `json:"majsoul_account_id"`
+
+	// 分析结果的输出格式，见 rendererNames，可以被 -render 参数覆盖
+	Renderer string `json:"renderer"`
+
+	// 界面语言，见 langNames，可以被 -lang 参数覆盖
+	Lang string `json:"lang"`
 }
 
 var gameConf = &gameConfig{
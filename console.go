@@ -24,6 +24,19 @@ func init() {
 }
 
 func clearConsole() {
+	switch r := outputRenderer.(type) {
+	case *tuiRenderer:
+		// 全屏界面原地更新，不需要清屏
+		r.clear()
+		return
+	case *textRenderer:
+		if _, ok := r.w.(terminalWriter); !ok {
+			// 纯文本和 HTML 输出不清屏，以免混入 ANSI 转义序列
+			return
+		}
+	case *jsonRenderer:
+		return
+	}
 	clearFunc, ok := clearFuncMap[runtime.GOOS] //runtime.GOOS -> linux, windows, darwin etc.
 	if ok { //if we defined a clear func for that platform:
 		clearFunc() //we execute it
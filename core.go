@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"github.com/fatih/color"
 	"github.com/EndlessCheng/mahjong-helper/util"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
@@ -12,8 +13,11 @@ var debugMode = false
 const (
 	dataSourceTypeTenhou = iota
 	dataSourceTypeMajsoul
+	dataSourceTypeMjai
 )
 
+var dataSourceNames = []string{"tenhou", "majsoul", "mjai"}
+
 type DataParser interface {
 	// 数据来源是天凤还是雀魂
 	GetDataSourceType() int
@@ -29,55 +33,9 @@ type DataParser interface {
 	//IsLogin() bool
 	//HandleLogin()
 
-	// round 开始/重连
-	// roundNumber: 场数（如东1为0，东2为1，...，南1为4，...，南4为7，...）
-	// dealer: 庄家 0-3
-	// doraIndicator: 宝牌指示牌
-	// handTiles: 手牌
-	// numRedFives: 按照 mps 的顺序，赤5个数
-	IsInit() bool
-	ParseInit() (roundNumber int, dealer int, doraIndicator int, handTiles []int, numRedFives []int)
-
-	// 自家摸牌
-	// tile: 0-33
-	// isRedFive: 是否为赤5
-	// kanDoraIndicator: 摸牌时，若为暗杠摸的岭上牌，则可以翻出杠宝牌指示牌，否则返回 -1（目前恒为 -1，见 IsNewDora）
-	IsSelfDraw() bool
-	ParseSelfDraw() (tile int, isRedFive bool, kanDoraIndicator int)
-
-	// 舍牌
-	// who: 0=自家, 1=下家, 2=对家, 3=上家
-	// isTsumogiri: 是否为摸切（who=0 时忽略该值）
-	// isReach: 是否为立直宣言（isReach 对于天凤来说恒为 false，见 IsReach）
-	// canBeMeld: 是否可以鸣牌（who=0 时忽略该值）
-	// kanDoraIndicator: 大明杠/加杠的杠宝牌指示牌，在切牌后出现，没有则返回 -1（天凤恒为-1，见 IsNewDora）
-	IsDiscard() bool
-	ParseDiscard() (who int, discardTile int, isRedFive bool, isTsumogiri bool, isReach bool, canBeMeld bool, kanDoraIndicator int)
-
-	// 鸣牌（含暗杠、加杠）
-	// kanDoraIndicator: 暗杠的杠宝牌指示牌，在他家暗杠时出现，没有则返回 -1（天凤恒为-1，见 IsNewDora）
-	IsOpen() bool
-	ParseOpen() (who int, meld *model.Meld, kanDoraIndicator int)
-
-	// 立直声明（IsReach 对于雀魂来说恒为 false，见 ParseDiscard）
-	IsReach() bool
-	ParseReach() (who int)
-
-	// 振听
-	IsFuriten() bool
-
-	// 本局是否和牌
-	IsRoundWin() bool
-	ParseRoundWin() (whos []int, points []int)
-
-	// 是否流局
-	//IsRyuukyoku() bool
-
-	// 这一项放在末尾处理
-	// 杠宝牌（雀魂在暗杠后的摸牌时出现）
-	// kanDoraIndicator: 0-33
-	IsNewDora() bool
-	ParseNewDora() (kanDoraIndicator int)
+	// 将当前消息翻译成事件，每次调用返回下一个事件，没有更多事件时返回 nil
+	// 事件的类型见 event.go
+	Next() (Event, error)
 }
 
 //
@@ -96,6 +54,45 @@ func normalDiscardTiles(discardTiles []int) []int {
 
 //
 
+const (
+	ryuukyokuTypeExhaustive     = iota // 荒牌流局
+	ryuukyokuTypeNagashiMangan         // 流局满贯
+	ryuukyokuTypeKyuushuKyuuhai        // 九种九牌
+	ryuukyokuTypeSuufonRenda           // 四风连打
+	ryuukyokuTypeSuuchaRiichi          // 四家立直
+	ryuukyokuTypeSuukanSanra           // 四杠散了
+	ryuukyokuTypeSanchahou             // 三家和了
+)
+
+var ryuukyokuTypeNames = []string{
+	"荒牌流局",
+	"流局满贯",
+	"九种九牌",
+	"四风连打",
+	"四家立直",
+	"四杠散了",
+	"三家和了",
+}
+
+//
+
+// 重连时某个玩家的局面信息
+type playerReinitInfo struct {
+	// 牌河，负数表示摸切(^)
+	discardTiles []int
+
+	// 立直宣言牌在 discardTiles 中的下标，未立直为 -1
+	reachTileAt int
+
+	// 副露
+	melds []*model.Meld
+
+	// 拔北宝牌数（三人麻将）
+	nukiDoraCount int
+}
+
+//
+
 const (
 	meldTypeChi    = iota // 吃
 	meldTypePon           // 碰
@@ -126,6 +123,10 @@ type playerInfo struct {
 
 	reachTileAtGlobal int // 立直宣言牌在 globalDiscardTiles 中的下标，初始为 -1
 	reachTileAt       int // 立直宣言牌在 discardTiles 中的下标，初始为 -1
+
+	nukiDoraCount int // 拔北宝牌数（三人麻将）
+
+	isRiichiFuriten bool // 立直后见逃了和了牌，直到本局结束都是振听（仅自家）
 }
 
 func newPlayerInfo(name string, selfWindTile int) *playerInfo {
@@ -138,7 +139,7 @@ func newPlayerInfo(name string, selfWindTile int) *playerInfo {
 	}
 }
 
-func (p *playerInfo) printDiscards() {
+func (p *playerInfo) printDiscards(w styledWriter) {
 	// TODO: 高亮不合理的舍牌或危险舍牌，如
 	// - 一开始就切中张
 	// - 开始切中张后，手切了幺九牌（也有可能是有人碰了牌，比如 133m 有人碰了 2m）
@@ -153,33 +154,39 @@ func (p *playerInfo) printDiscards() {
 	// https://tieba.baidu.com/p/3372239806
 	//      吃牌时候打出来的牌的颜色是危险的；碰之后全部的牌都是危险的
 
-	fmt.Printf(p.name + ":")
-	for i, disTile := range p.discardTiles {
-		fmt.Printf(" ")
-		// TODO: 显示 dora, 赤宝牌
-		bgColor := color.BgBlack
-		fgColor := color.FgWhite
-		var tile string
-		if disTile >= 0 { // 手切
-			tile = util.Mahjong[disTile]
-			if disTile >= 27 {
-				tile = util.MahjongU[disTile] // 关注字牌的手切
-			}
-			if p.isNaki { // 副露
-				fgColor = getOtherDiscardAlertColor(disTile) // 高亮中张手切
-				if util.InInts(i, p.meldDiscardsAt) {
-					bgColor = color.BgWhite // 鸣牌时切的那张牌要背景高亮
-					fgColor = color.FgBlack
-				}
+	w.print(p.name + ":")
+	for i := range p.discardTiles {
+		w.print(" ")
+		tile, bgColor, fgColor := p.discardTileStyle(i)
+		w.print(tile, bgColor, fgColor)
+	}
+	w.print("\n")
+}
+
+// 牌河中第 i 张舍牌的写法和颜色
+func (p *playerInfo) discardTileStyle(i int) (tile string, bgColor color.Attribute, fgColor color.Attribute) {
+	// TODO: 显示 dora, 赤宝牌
+	bgColor = color.BgBlack
+	fgColor = color.FgWhite
+	disTile := p.discardTiles[i]
+	if disTile >= 0 { // 手切
+		tile = util.Mahjong[disTile]
+		if disTile >= 27 {
+			tile = util.MahjongU[disTile] // 关注字牌的手切
+		}
+		if p.isNaki { // 副露
+			fgColor = getOtherDiscardAlertColor(disTile) // 高亮中张手切
+			if util.InInts(i, p.meldDiscardsAt) {
+				bgColor = color.BgWhite // 鸣牌时切的那张牌要背景高亮
+				fgColor = color.FgBlack
 			}
-		} else { // 摸切
-			disTile = ^disTile
-			tile = util.Mahjong[disTile]
-			fgColor = color.FgHiBlack // 暗色显示
 		}
-		color.New(bgColor, fgColor).Print(tile)
+	} else { // 摸切
+		disTile = ^disTile
+		tile = util.Mahjong[disTile]
+		fgColor = color.FgHiBlack // 暗色显示
 	}
-	fmt.Println()
+	return
 }
 
 //
@@ -187,6 +194,12 @@ func (p *playerInfo) printDiscards() {
 type roundData struct {
 	parser DataParser
 
+	// 整场游戏的数据（点数、本场数、立直棒等）
+	game *gameData
+
+	// 重放重连数据时不输出任何信息
+	skipOutput bool
+
 	// 场数（如东1为0，东2为1，...，南1为4，...）
 	roundNumber int
 
@@ -215,58 +228,97 @@ type roundData struct {
 	globalDiscardTiles []int
 
 	// 0=自家, 1=下家, 2=对家, 3=上家
+	// 三人麻将时为 0=自家, 1=下家, 2=上家
 	players []*playerInfo
+
+	// 牌谱记录，在各个 round 之间保留
+	recorder *gameRecorder
+
+	// 自家立直后，他家放出了自家的和了牌
+	// 若之后没有和牌，则为立直振听
+	selfSkippedWinTile bool
+
+	// 最近一条消息中处理了的事件，离线重放时据此划分每一步
+	handledEvents []Event
 }
 
 func newRoundData(parser DataParser, roundNumber int, dealer int) *roundData {
-	const playerNumber = 4
-	roundWindTile := 27 + roundNumber/playerNumber
+	return newRoundDataWithGame(parser, newGameData(gameLengthHanchan, 4), roundNumber, dealer)
+}
+
+func newRoundDataWithGame(parser DataParser, game *gameData, roundNumber int, dealer int) *roundData {
+	playerNumber := len(game.scores)
+	roundWindTile := 27 + roundNumber/4
 	playerWindTile := make([]int, playerNumber)
 	for i := 0; i < playerNumber; i++ {
 		playerWindTile[i] = 27 + (playerNumber-dealer+i)%playerNumber
 	}
+
+	leftCounts := util.InitLeftTiles34()
+	if playerNumber == 3 {
+		leftCounts = util.InitSanmaLeftTiles34()
+	}
+	names := playerNames(playerNumber)
+	players := make([]*playerInfo, playerNumber)
+	for i := range players {
+		players[i] = newPlayerInfo(names[i], playerWindTile[i])
+	}
+
 	return &roundData{
 		parser:             parser,
+		game:               game,
 		roundNumber:        roundNumber,
 		roundWindTile:      roundWindTile,
 		dealer:             dealer,
 		counts:             make([]int, 34),
-		leftCounts:         util.InitLeftTiles34(),
+		leftCounts:         leftCounts,
 		globalDiscardTiles: []int{},
-		players: []*playerInfo{
-			newPlayerInfo("自家", playerWindTile[0]),
-			newPlayerInfo("下家", playerWindTile[1]),
-			newPlayerInfo("对家", playerWindTile[2]),
-			newPlayerInfo("上家", playerWindTile[3]),
-		},
+		players:            players,
 	}
 }
 
 func (d *roundData) reset(roundNumber int, dealer int) {
-	newData := newRoundData(d.parser, roundNumber, dealer)
+	newData := newRoundDataWithGame(d.parser, d.game, roundNumber, dealer)
+	newData.skipOutput = d.skipOutput
+	newData.recorder = d.recorder
 	*d = *newData
 }
 
+// 是否为三人麻将
+func (d *roundData) isSanma() bool {
+	return len(d.players) == 3
+}
+
+// 上家，即可以吃其舍牌的玩家（三人麻将不能吃）
+func (d *roundData) kamicha() int {
+	return len(d.players) - 1
+}
+
 func (d *roundData) descLeftCounts(tile int) {
 	d.leftCounts[tile]--
 	if d.leftCounts[tile] < 0 {
-		info := fmt.Sprintf("数据异常: %s 数量为 %d", util.MahjongZH[tile], d.leftCounts[tile])
+		info := trf("数据异常: %s 数量为 %d", tileName(tile), d.leftCounts[tile])
 		if debugMode {
 			panic(info)
 		} else {
-			fmt.Println(info)
+			outputRenderer.renderMessage(info)
 		}
 	}
 }
 
 func (d *roundData) newDora(kanDoraIndicator int) {
-	color.Yellow("杠宝牌指示牌是 %s", util.MahjongZH[kanDoraIndicator])
+	if !d.skipOutput {
+		outputRenderer.renderMessage(trf("杠宝牌指示牌是 %s", tileName(kanDoraIndicator)), color.FgYellow)
+	}
 	d.doraIndicators = append(d.doraIndicators, kanDoraIndicator)
 	d.descLeftCounts(kanDoraIndicator)
 }
 
 // 根据宝牌指示牌计算出宝牌
 func (d *roundData) doraList() (dl []int) {
+	if d.isSanma() {
+		return model.SanmaDoraList(d.doraIndicators)
+	}
 	return model.DoraList(d.doraIndicators)
 }
 
@@ -281,8 +333,15 @@ func (d *roundData) numDoraOfTile(tile int) (cnt int) {
 }
 
 func (d *roundData) printDiscards() {
-	for i := len(d.players) - 1; i >= 1; i-- {
-		d.players[i].printDiscards()
+	outputRenderer.renderDiscards(d.players)
+}
+
+func (d *roundData) renderBoard() {
+	if d.skipOutput {
+		return
+	}
+	if r, ok := outputRenderer.(boardRenderer); ok {
+		r.renderBoard(d)
 	}
 }
 
@@ -296,11 +355,6 @@ func (d *roundData) analysisTilesRisk() (riList riskInfoList) {
 
 	// 先利用振听规则收集各家安牌
 	for who, player := range d.players {
-		if who == 0 {
-			// TODO: 暂时不计算自家的
-			continue
-		}
-
 		// 舍牌振听产生的安牌
 		for _, tile := range normalDiscardTiles(player.discardTiles) {
 			riList[who].safeTiles34[tile] = true
@@ -322,7 +376,8 @@ func (d *roundData) analysisTilesRisk() (riList riskInfoList) {
 	// 计算各种数据
 	for who, player := range d.players {
 		if who == 0 {
-			// TODO: 暂时不计算自家的
+			// 自家的数据单独计算
+			d.analysisSelfRisk(&riList[0])
 			continue
 		}
 
@@ -367,8 +422,13 @@ func (d *roundData) analysisTilesRisk() (riList riskInfoList) {
 			ronPoint = util.RonPointRiichiHiIppatsu
 		case player.isNaki:
 			// 副露时的荣和点数（非常粗略地估计）
-			doraCount := 0
+			doraCount := player.nukiDoraCount
 			doraList := d.doraList()
+			for _, dora := range doraList {
+				if dora == 30 {
+					doraCount += player.nukiDoraCount
+				}
+			}
 			for _, meld := range player.melds {
 				for _, tile := range meld.Tiles {
 					for _, dora := range doraList {
@@ -390,6 +450,8 @@ func (d *roundData) analysisTilesRisk() (riList riskInfoList) {
 		if who == d.dealer {
 			ronPoint *= 1.5
 		}
+		// 放铳时还要支付本场棒
+		ronPoint += float64(300 * d.game.honba)
 		riList[who]._ronPoint = ronPoint
 
 		// 根据该玩家的巡目、现物、立直后通过的牌、NC、Dora、早外、荣和点数来计算每张牌的危险度
@@ -405,6 +467,30 @@ func (d *roundData) analysisTilesRisk() (riList riskInfoList) {
 	return riList
 }
 
+// 他家视角下自家的铳率
+// 可以用来判断自家的牌河是否容易被读、待牌是否为筋牌陷阱，以及立直后他家是否容易弃和
+// 对他家而言，自家的手牌是未知的，所以剩余枚数要加上自家的手牌
+// 宝牌对和率的影响已在 util.CalculateAgariRateOfEachTile 中考虑，这里不做调整
+func (d *roundData) analysisSelfRisk(ri *riskInfo) {
+	const self = 0
+	player := d.players[self]
+
+	turns := util.MinInt(len(player.discardTiles), util.MaxTurns)
+	if turns == 0 {
+		turns = 1
+	}
+
+	leftCounts := make([]int, 34)
+	for i, c := range d.leftCounts {
+		leftCounts[i] = c + d.counts[i]
+	}
+
+	risk34 := util.CalculateRiskTiles34(turns, ri.safeTiles34, leftCounts, nil, d.roundWindTile, player.selfWindTile).
+		FixWithEarlyOutside(player.earlyOutsideTiles)
+	ri.riskTable = riskTable(risk34)
+	ri.leftNoSujiTiles = util.CalculateLeftNoSujiTiles(ri.safeTiles34, leftCounts)
+}
+
 func (d *roundData) isPlayerDaburii(who int) bool {
 	// w立直成立的前提是没有任何玩家副露
 	for _, p := range d.players {
@@ -415,7 +501,8 @@ func (d *roundData) isPlayerDaburii(who int) bool {
 	return d.players[who].reachTileAt == 0
 }
 
-func (d *roundData) newModelPlayerInfo() *model.PlayerInfo {
+// riskTables 为 analysisTilesRisk 的结果，用于修正和率，为 nil 时不考虑自家的铳率
+func (d *roundData) newModelPlayerInfo(riskTables riskInfoList) *model.PlayerInfo {
 	melds := []model.Meld{}
 	for _, m := range d.players[0].melds {
 		melds = append(melds, *m)
@@ -424,7 +511,7 @@ func (d *roundData) newModelPlayerInfo() *model.PlayerInfo {
 	const self = 0
 	selfPlayer := d.players[self]
 
-	return &model.PlayerInfo{
+	playerInfo := &model.PlayerInfo{
 		HandTiles34: d.counts,
 		Melds:       melds,
 		DoraTiles:   d.doraList(),
@@ -435,17 +522,144 @@ func (d *roundData) newModelPlayerInfo() *model.PlayerInfo {
 		IsParent:      d.dealer == self,
 		IsDaburii:     d.isPlayerDaburii(self),
 		IsRiichi:      selfPlayer.isReached,
+		IsSanma:       d.isSanma(),
+		NukiDoraCount: selfPlayer.nukiDoraCount,
+
+		DiscardTiles:    normalDiscardTiles(selfPlayer.discardTiles),
+		IsRiichiFuriten: selfPlayer.isRiichiFuriten,
+		LeftTiles34:     d.leftCounts,
+	}
+	if riskTables != nil {
+		playerInfo.SelfRiskTiles34 = riskTables[self].riskTable
+	}
+	return playerInfo
+}
+
+// 根据重连数据恢复各家的牌河、副露和立直信息
+// 重连数据中没有各家舍牌的先后顺序，这里从庄家开始轮流排列各家的舍牌，以此来近似 globalDiscardTiles
+func (d *roundData) restorePlayers(reinitPlayers []*playerReinitInfo) {
+	// 三人麻将时天凤的重连数据仍有 4 家，忽略多余的
+	if len(reinitPlayers) > len(d.players) {
+		reinitPlayers = reinitPlayers[:len(d.players)]
+	}
+
+	maxDiscardCount := 0
+	for who, info := range reinitPlayers {
+		player := d.players[who]
+		const kitaTile = 30
+		// 重连时的手牌已不含拔出的北
+		for i := 0; i < info.nukiDoraCount; i++ {
+			player.nukiDoraCount++
+			d.descLeftCounts(kitaTile)
+		}
+		for _, meld := range info.melds {
+			player.melds = append(player.melds, meld)
+			if meld.MeldType != meldTypeAnkan {
+				player.isNaki = true
+			}
+			// 副露中的牌都是可见的（被鸣的牌不在牌河中）
+			for _, tile := range meld.Tiles {
+				d.descLeftCounts(tile)
+			}
+			if who == 0 && meld.ContainRedFive {
+				d.numRedFives[meld.Tiles[0]/9]++
+			}
+		}
+		maxDiscardCount = util.MaxInt(maxDiscardCount, len(info.discardTiles))
+	}
+
+	playerNumber := len(reinitPlayers)
+	discardsAtGlobal := make([][]int, playerNumber)
+	for turn := 0; turn < maxDiscardCount; turn++ {
+		for i := 0; i < playerNumber; i++ {
+			who := (d.dealer + i) % playerNumber
+			info := reinitPlayers[who]
+			if turn >= len(info.discardTiles) {
+				continue
+			}
+
+			disTile := info.discardTiles[turn]
+			tile := disTile
+			if tile < 0 {
+				tile = ^tile
+			}
+			d.descLeftCounts(tile)
+
+			player := d.players[who]
+			d.globalDiscardTiles = append(d.globalDiscardTiles, disTile)
+			player.discardTiles = append(player.discardTiles, disTile)
+			player.latestDiscardAtGlobal = len(d.globalDiscardTiles) - 1
+			discardsAtGlobal[who] = append(discardsAtGlobal[who], player.latestDiscardAtGlobal)
 
-		DiscardTiles: normalDiscardTiles(selfPlayer.discardTiles),
-		LeftTiles34:  d.leftCounts,
+			if turn == info.reachTileAt {
+				player.isReached = true
+				player.reachTileAtGlobal = len(d.globalDiscardTiles) - 1
+				player.reachTileAt = turn
+			}
+
+			// 标记外侧牌
+			if !player.isReached && len(player.discardTiles) <= 5 {
+				player.earlyOutsideTiles = append(player.earlyOutsideTiles, util.OutsideTiles(tile)...)
+			}
+		}
+	}
+
+	// 标记鸣牌的舍牌
+	// 重连数据中没有鸣牌时的舍牌位置，这里保守地视作尽早鸣牌（第 i 个副露对应第 i 张舍牌），以免低估听牌率
+	for who, player := range d.players {
+		for i := range player.melds {
+			discardAt := util.MinInt(i, len(player.discardTiles)-1)
+			player.meldDiscardsAt = append(player.meldDiscardsAt, discardAt)
+			discardAtGlobal := -1
+			if discardAt != -1 {
+				discardAtGlobal = discardsAtGlobal[who][discardAt]
+			}
+			player.meldDiscardsAtGlobal = append(player.meldDiscardsAtGlobal, discardAtGlobal)
+		}
 	}
 }
 
-func (d *roundData) analysis() error {
+// 打印游戏提供给自家的操作选项
+func (d *roundData) printActionOptions(options *ActionOptions) {
+	if names := options.names(); len(names) > 0 {
+		outputRenderer.renderMessage(trf("可以%s", strings.Join(names, tr("、"))), color.FgHiCyan)
+	}
+}
+
+// 打印本局结束时各家的点数变化
+func (d *roundData) printDeltaPoints(w styledWriter, deltaPoints []int) {
+	for who, delta := range deltaPoints {
+		if delta == 0 {
+			continue
+		}
+		c := color.FgHiGreen
+		if delta < 0 {
+			c = color.FgHiRed
+		}
+		w.print(d.players[who].name + " ")
+		w.print(fmt.Sprintf("%+d", delta), c)
+		w.print("\n")
+	}
+}
+
+// 重连后打印恢复的局面
+func (d *roundData) printRestoredRound() (record *analysisRecord, err error) {
+	d.printDiscards()
+
+	riskTables := d.analysisTilesRisk()
+	outputRenderer.renderRisks(riskTables, d.counts, d.leftCounts)
+
+	if util.CountOfTiles34(d.counts)%3 == 0 {
+		return nil, nil
+	}
+	return analysisTiles34(d.newModelPlayerInfo(riskTables), riskTables.mixedRiskTable())
+}
+
+func (d *roundData) analysis() (err error) {
 	if !debugMode {
 		defer func() {
-			if err := recover(); err != nil {
-				fmt.Println("内部错误：", err)
+			if r := recover(); r != nil {
+				err = fmt.Errorf(tr("内部错误：%v"), r)
 			}
 		}()
 	}
@@ -454,67 +668,124 @@ func (d *roundData) analysis() error {
 		fmt.Println("收到", d.parser.GetMessage())
 	}
 
+	handledEvents := []Event{}
+	defer func() { d.handledEvents = handledEvents }()
+
 	if !d.parser.CheckMessage() {
 		return nil
 	}
 
-	// 若自家立直，则进入看戏模式
-	// TODO: 见逃判断
-	if !d.parser.IsInit() && !d.parser.IsRoundWin() && d.players[0].isReached {
-		return nil
+	for {
+		event, err := d.parser.Next()
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+		handledEvents = append(handledEvents, event)
+		if err := d.handleEvent(event); err != nil {
+			return err
+		}
 	}
+}
 
-	switch {
-	case d.parser.IsInit():
+func (d *roundData) handleEvent(event Event) error {
+	// 自家立直后见逃了和了牌
+	if d.selfSkippedWinTile {
+		if _, isWin := event.(*WinEvent); !isWin {
+			d.selfSkippedWinTile = false
+			d.markRiichiFuriten()
+		}
+	}
+
+	// 全屏界面在局面更新后刷新
+	defer d.renderBoard()
+
+	// 记录牌谱，在局面更新后写入
+	var record *recordEvent
+	defer func() {
+		if record != nil {
+			d.recordEvent(record)
+		}
+	}()
+
+	switch e := event.(type) {
+	case *GameStartEvent:
+		d.game = newGameData(e.GameLength, e.PlayerNumber)
+		d.reset(0, 0)
+		d.closeRecorder()
+	case *SeatEvent:
+		d.dealer = e.Dealer
+		d.roundNumber = 0
+
+		if d.skipOutput {
+			return nil
+		}
+		playerNumber := len(d.players)
+		windTile := 27 + (playerNumber-e.Dealer)%playerNumber
+		text := &styledText{}
+		text.print(tr("游戏即将开始，您分配到的座位是："))
+		text.print(tileName(windTile)+"\n", color.FgHiGreen)
+		outputRenderer.renderText("round", text)
+	case *InitEvent:
 		// round 开始/重连
-		if !debugMode {
+		if !debugMode && !d.skipOutput {
 			clearConsole()
 		}
 
-		roundNumber, dealer, doraIndicator, hands, numRedFives := d.parser.ParseInit()
-		switch d.parser.GetDataSourceType() {
-		case dataSourceTypeTenhou:
-			d.reset(roundNumber, dealer)
-		case dataSourceTypeMajsoul:
-			playerNumber := len(d.players)
-			if dealer != -1 {
-				d.dealer = dealer
+		d.reset(e.RoundNumber, e.Dealer)
+		d.game.newRound(d.dealer, e.Honba, e.RiichiSticks, e.Scores)
 
-				fmt.Printf("游戏即将开始，您分配到的座位是：")
-				windTile := 27 + (playerNumber-dealer)%playerNumber
-				color.HiGreen(util.MahjongZH[windTile])
+		reinitPlayers := e.ReinitPlayers
+		doraIndicators := e.DoraIndicators
+		hands := e.HandTiles
 
-				return nil
-			} else {
-				dealer = d.dealer
-				if roundNumber > 0 && roundNumber != d.roundNumber {
-					dealer = (dealer + 1) % playerNumber
-				}
-				d.reset(roundNumber, dealer)
+		if !d.skipOutput {
+			text := &styledText{}
+			if reinitPlayers != nil {
+				text.print(tr("重连成功，已恢复本局数据")+"\n", color.FgHiGreen)
 			}
-		default:
-			panic("not impl!")
+			text.print(trf("%s%d局开始，自风为%s\n", tileName(d.roundWindTile), e.RoundNumber%4+1, tileName(d.players[0].selfWindTile)))
+			d.printScores(text)
+			for _, indicator := range doraIndicators {
+				text.print(trf("宝牌指示牌是 %s", tileName(indicator))+"\n", color.FgHiYellow)
+			}
+			outputRenderer.renderText("round", text)
+		}
+		d.doraIndicators = doraIndicators
+		for _, indicator := range doraIndicators {
+			d.descLeftCounts(indicator)
 		}
-
-		fmt.Printf("%s%d局开始，自风为%s\n", util.MahjongZH[d.roundWindTile], roundNumber%4+1, util.MahjongZH[d.players[0].selfWindTile])
-
-		color.HiYellow("宝牌指示牌是 %s", util.MahjongZH[doraIndicator])
-		d.doraIndicators = []int{doraIndicator}
-		d.descLeftCounts(doraIndicator)
 
 		for _, tile := range hands {
 			d.counts[tile]++
 			d.descLeftCounts(tile)
 		}
 
-		d.numRedFives = numRedFives
+		d.numRedFives = e.NumRedFives
 
-		if len(hands) == 14 {
-			return analysisTiles34(d.newModelPlayerInfo(), nil)
+		record = &recordEvent{Type: recordEventTypeInit, Who: d.dealer}
+
+		if reinitPlayers != nil {
+			d.restorePlayers(reinitPlayers)
+			if d.skipOutput {
+				return nil
+			}
+			var err error
+			record.Analysis, err = d.printRestoredRound()
+			return err
 		}
-	case d.parser.IsOpen():
+
+		if len(hands) == 14 && !d.skipOutput {
+			var err error
+			record.Analysis, err = analysisTiles34(d.newModelPlayerInfo(d.analysisTilesRisk()), nil)
+			return err
+		}
+	case *CallEvent:
 		// 某家鸣牌（含暗杠、加杠）
-		who, meld, kanDoraIndicator := d.parser.ParseOpen()
+		who, meld := e.Who, e.Meld
+		record = &recordEvent{Type: recordEventTypeCall, Who: who, Meld: newMeldRecord(meld)}
 		meldType := meld.MeldType
 		meldTiles := meld.Tiles
 		calledTile := meld.CalledTile
@@ -524,11 +795,6 @@ func (d *roundData) analysis() error {
 			player.canIppatsu = false
 		}
 
-		// 杠宝牌指示牌
-		if kanDoraIndicator != -1 {
-			d.newDora(kanDoraIndicator)
-		}
-
 		player := d.players[who]
 
 		// 不是暗杠则标记该玩家鸣牌了
@@ -586,12 +852,27 @@ func (d *roundData) analysis() error {
 				}
 			}
 		}
-	case d.parser.IsReach():
+	case *KitaEvent:
+		// 拔北（三人麻将）
+		// 拔北后从岭上摸牌，不会翻出杠宝牌
+		who := e.Who
+		record = &recordEvent{Type: recordEventTypeKita, Who: who}
+		const kitaTile = 30
+		d.players[who].nukiDoraCount++
+		if who == 0 {
+			d.counts[kitaTile]--
+		} else {
+			d.descLeftCounts(kitaTile)
+		}
+	case *RiichiEvent:
+		if e.IsAccepted {
+			d.game.reachSuccess(e.Who)
+			break
+		}
 		// 立直宣告
 		// 如果是他家立直，进入攻守判断模式
-		who := d.parser.ParseReach()
-		d.players[who].isReached = true
-		d.players[who].canIppatsu = true
+		d.players[e.Who].isReached = true
+		d.players[e.Who].canIppatsu = true
 		//case "AGARI", "RYUUKYOKU":
 		//	// 某人和牌或流局，round 结束
 		//case "PROF":
@@ -600,50 +881,62 @@ func (d *roundData) analysis() error {
 		//	// 某人退出
 		//case "REJOIN", "GO":
 		//	// 重连
-	case d.parser.IsFuriten():
+	case *FuritenEvent:
 		// 振听
-		color.HiYellow("振听")
+		if !d.skipOutput {
+			outputRenderer.renderMessage(tr("振听"), color.FgHiYellow)
+		}
 		//case "U", "V", "W":
 		//	//（下家,对家,上家 不要其上家的牌）摸牌
 		//case "HELO", "RANKING", "TAIKYOKU", "UN", "LN", "SAIKAI":
 		//	// 其他
-	case d.parser.IsSelfDraw():
-		if !debugMode {
+	case *DrawEvent:
+		if !debugMode && !d.skipOutput {
 			clearConsole()
 		}
 		// 自家（从牌山 d.leftCounts）摸牌（至手牌 d.counts）
-		tile, isRedFive, kanDoraIndicator := d.parser.ParseSelfDraw()
+		tile, isRedFive := e.Tile, e.IsRedFive
+		record = &recordEvent{Type: recordEventTypeDraw, Tile: util.Mahjong[tile], IsRedFive: isRedFive}
 		d.descLeftCounts(tile)
 		d.counts[tile]++
 		if isRedFive {
 			d.numRedFives[tile/9]++
 		}
-		if kanDoraIndicator != -1 {
-			d.newDora(kanDoraIndicator)
+
+		if d.skipOutput {
+			return nil
 		}
 
 		// 打印他家舍牌信息
 		d.printDiscards()
-		fmt.Println()
+		d.printActionOptions(e.Options)
+
+		// 自家立直后只需判断自摸、暗杠
+		if d.players[0].isReached {
+			d.analysisRiichiDraw(tile)
+			return nil
+		}
 
 		// 安全度分析
 		riskTables := d.analysisTilesRisk()
-		riskTables.printWithHands(d.counts, d.leftCounts)
+		outputRenderer.renderRisks(riskTables, d.counts, d.leftCounts)
+		if isPushingAnalysis() {
+			record.Risks = d.newRiskRecords(riskTables)
+		}
 
 		mixedRiskTable := riskTables.mixedRiskTable()
 
 		// 何切
 		// TODO: 根据是否听牌/一向听、打点、巡目、和率等进行攻守判断
-		return analysisTiles34(d.newModelPlayerInfo(), mixedRiskTable)
-	case d.parser.IsDiscard():
-		who, discardTile, isRedFive, isTsumogiri, isReach, canBeMeld, kanDoraIndicator := d.parser.ParseDiscard()
-
-		if kanDoraIndicator != -1 {
-			d.newDora(kanDoraIndicator)
-		}
+		var err error
+		record.Analysis, err = analysisTiles34(d.newModelPlayerInfo(riskTables), mixedRiskTable)
+		return err
+	case *DiscardEvent:
+		who, discardTile, isRedFive, isTsumogiri, options := e.Who, e.Tile, e.IsRedFive, e.IsTsumogiri, e.Options
+		record = &recordEvent{Type: recordEventTypeDiscard, Who: who, Tile: util.Mahjong[discardTile], IsRedFive: isRedFive, IsTsumogiri: isTsumogiri}
 
 		player := d.players[who]
-		if isReach {
+		if e.IsReach {
 			player.isReached = true
 			player.canIppatsu = true
 		}
@@ -660,17 +953,30 @@ func (d *roundData) analysis() error {
 				d.numRedFives[discardTile/9]--
 			}
 
+			// 标记外侧牌，用于计算他家视角下自家的铳率
+			if !player.isReached && len(player.discardTiles) <= 5 {
+				player.earlyOutsideTiles = append(player.earlyOutsideTiles, util.OutsideTiles(discardTile)...)
+			}
+
+			if player.isReached && player.reachTileAtGlobal == -1 {
+				// 标记立直宣言牌
+				player.reachTileAtGlobal = len(d.globalDiscardTiles) - 1
+				player.reachTileAt = len(player.discardTiles) - 1
+			} else if player.isReached {
+				// 立直后摸切了和了牌
+				if _, ok := d.selfWaits()[discardTile]; ok {
+					d.markRiichiFuriten()
+				}
+			}
+
 			return nil
 		}
 
 		// 他家舍牌
 		d.descLeftCounts(discardTile)
 
-		// 天凤fix：为防止先收到自家摸牌，然后收到上家摸牌，上家舍牌时不刷新
-		if d.parser.GetDataSourceType() != dataSourceTypeTenhou || who != 3 {
-			if !debugMode {
-				clearConsole()
-			}
+		if !e.IsBeforeSelfDraw && !debugMode && !d.skipOutput {
+			clearConsole()
 		}
 
 		_disTile := discardTile
@@ -692,14 +998,14 @@ func (d *roundData) analysis() error {
 			player.reachTileAt = len(player.discardTiles) - 1
 
 			// 若该玩家摸切立直，打印提示信息
-			if isTsumogiri {
-				color.HiYellow("%s 摸切立直！", d.players[who].name)
+			if isTsumogiri && !d.skipOutput {
+				outputRenderer.renderMessage(trf("%s 摸切立直！", d.players[who].name), color.FgHiYellow)
 			}
 		} else if len(player.meldDiscardsAt) != len(player.melds) {
 			// 标记鸣牌的舍牌
 			// 注意这里会标记到暗杠后的舍牌上
 			if len(player.meldDiscardsAt)+1 != len(player.melds) {
-				fmt.Printf("玩家数据异常 %#v", *player)
+				fmt.Printf(tr("玩家数据异常 %#v"), *player)
 			}
 			player.meldDiscardsAt = append(player.meldDiscardsAt, len(player.discardTiles)-1)
 			player.meldDiscardsAtGlobal = append(player.meldDiscardsAtGlobal, len(d.globalDiscardTiles)-1)
@@ -710,45 +1016,117 @@ func (d *roundData) analysis() error {
 			player.canIppatsu = false
 		}
 
+		// 自家立直后，他家放出了自家的和了牌
+		if d.players[0].isReached {
+			if _, ok := d.selfWaits()[discardTile]; ok {
+				d.selfSkippedWinTile = true
+			}
+		}
+
+		if d.skipOutput {
+			return nil
+		}
+
+		// 自家立直后只需判断荣和还是见逃
+		if d.players[0].isReached {
+			if !debugMode {
+				clearConsole()
+			}
+			d.printDiscards()
+			d.analysisRiichiRon(who, discardTile)
+			return nil
+		}
+
 		// 安全度分析
 		riskTables := d.analysisTilesRisk()
+		if isPushingAnalysis() {
+			record.Risks = d.newRiskRecords(riskTables)
+		}
 
-		if d.parser.GetDataSourceType() != dataSourceTypeTenhou || who != 3 {
+		if !e.IsBeforeSelfDraw {
 			// 打印他家舍牌信息
 			d.printDiscards()
-			fmt.Println()
-			riskTables.printWithHands(d.counts, d.leftCounts)
+			outputRenderer.renderRisks(riskTables, d.counts, d.leftCounts)
 		}
+		d.printActionOptions(options)
 
 		// 若能副露，计算何切
-		if canBeMeld {
+		if options.canCall() {
 			// TODO: 提醒: 消除海底/避免河底/型听
-			allowChi := who == 3 // 上家舍牌允许吃
+			allowChi := options.Chi && who == d.kamicha() && !d.isSanma() // 上家舍牌允许吃（三人麻将不能吃）
+			allowPon := options.Pon || options.Minkan                     // 能大明杠时也能碰，大明杠不单独分析
 			mixedRiskTable := riskTables.mixedRiskTable()
-			analysisMeld(d.newModelPlayerInfo(), discardTile, isRedFive, allowChi, mixedRiskTable)
+			record.Analysis = analysisMeld(d.newModelPlayerInfo(riskTables), discardTile, isRedFive, allowChi, allowPon, mixedRiskTable)
+		}
+	case *WinEvent:
+		whos, points, deltaPoints := e.Whos, e.Points, e.DeltaPoints
+		record = &recordEvent{Type: recordEventTypeWin, Whos: whos, Points: points, DeltaPoints: deltaPoints}
+		for _, result := range e.Results {
+			record.Wins = append(record.Wins, newWinRecord(result))
+		}
+		d.game.applyDeltaPoints(deltaPoints, true)
+		if d.skipOutput {
+			return nil
 		}
-	case d.parser.IsRoundWin():
 		if !debugMode {
 			clearConsole()
 		}
-		fmt.Println("和牌，本局结束")
-		whos, points := d.parser.ParseRoundWin()
+		text := &styledText{}
+		text.print(tr("和牌，本局结束") + "\n")
 		if len(whos) == 3 {
-			color.HiYellow("凤 凰 级 避 铳")
+			text.print(tr("凤 凰 级 避 铳")+"\n", color.FgHiYellow)
 			if d.parser.GetDataSourceType() == dataSourceTypeMajsoul {
-				color.HiYellow("（快醒醒，这是雀魂）")
+				text.print(tr("（快醒醒，这是雀魂）")+"\n", color.FgHiYellow)
 			}
 		}
-		for i, who := range whos {
-			fmt.Println(d.players[who].name, points[i])
+		if e.Results != nil {
+			for _, result := range e.Results {
+				text.print("\n")
+				d.printWinResult(text, result)
+			}
+			text.print("\n")
+		} else {
+			for i, who := range whos {
+				text.print(fmt.Sprintln(d.players[who].name, points[i]))
+			}
+		}
+		d.printDeltaPoints(text, deltaPoints)
+		d.printScores(text)
+		outputRenderer.renderText("win", text)
+	case *DrawGameEvent:
+		ryuukyokuType, tenpaiWhos, deltaPoints := e.Type, e.TenpaiWhos, e.DeltaPoints
+		record = &recordEvent{Type: recordEventTypeRyuukyoku, RyuukyokuType: ryuukyokuTypeNames[ryuukyokuType], Whos: tenpaiWhos, DeltaPoints: deltaPoints}
+		d.game.applyDeltaPoints(deltaPoints, false)
+		if d.skipOutput {
+			return nil
+		}
+		if !debugMode {
+			clearConsole()
+		}
+		text := &styledText{}
+		text.print(trf("%s，本局结束\n", tr(ryuukyokuTypeNames[ryuukyokuType])))
+		if ryuukyokuType == ryuukyokuTypeExhaustive || ryuukyokuType == ryuukyokuTypeNagashiMangan {
+			if len(tenpaiWhos) == 0 {
+				text.print(tr("全员未听") + "\n")
+			} else {
+				text.print(tr("听牌:"))
+				for _, who := range tenpaiWhos {
+					text.print(" " + d.players[who].name)
+				}
+				text.print("\n")
+			}
 		}
-	case d.parser.IsNewDora():
+		d.printDeltaPoints(text, deltaPoints)
+		d.printScores(text)
+		outputRenderer.renderText("draw", text)
+	case *DoraEvent:
 		// 杠宝牌
 		// 1. 剩余牌减少
 		// 2. 打点提高
-		kanDoraIndicator := d.parser.ParseNewDora()
-		d.newDora(kanDoraIndicator)
+		record = &recordEvent{Type: recordEventTypeDora, Tile: util.Mahjong[e.Indicator]}
+		d.newDora(e.Indicator)
 	default:
+		panic(fmt.Sprintf("未知的事件 %T", event))
 	}
 
 	return nil
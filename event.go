@@ -0,0 +1,201 @@
+package main
+
+import "github.com/EndlessCheng/mahjong-helper/util/model"
+
+// 解析器将一条消息翻译成若干事件，由 roundData 按顺序处理
+// 事件中的 who 均为 0=自家, 1=下家, 2=对家, 3=上家（三人麻将为 0=自家, 1=下家, 2=上家）
+// 牌均为 0-33
+type Event interface {
+	isEvent()
+}
+
+// 游戏开始（早于第一个 round 开始）
+type GameStartEvent struct {
+	GameLength   int // 游戏长度（东风战/半庄战）
+	PlayerNumber int // 玩家人数（四人麻将为 4，三人麻将为 3）
+}
+
+// 分配座位（雀魂在游戏开始时告知座位，早于第一个 round 开始）
+type SeatEvent struct {
+	Dealer int // 第一局的庄家
+}
+
+// round 开始/重连
+type InitEvent struct {
+	RoundNumber    int   // 场数（如东1为0，东2为1，...，南1为4，...，南4为7，...），三人麻将同样按照这一规则（没有东4、南4）
+	Dealer         int   // 庄家
+	DoraIndicators []int // 宝牌指示牌，重连时为所有已翻出的宝牌指示牌
+	HandTiles      []int // 手牌
+	NumRedFives    []int // 按照 mps 的顺序，赤5个数
+
+	Honba        int   // 本场数
+	RiichiSticks int   // 场上的立直棒数
+	Scores       []int // 各家点数，没有点数信息时为 nil
+
+	// 重连时各家的牌河、副露和立直信息，非重连时为 nil
+	// 雀魂的重连数据是逐条重放的（见 majsoulRoundData.CheckMessage），因此恒为 nil
+	ReinitPlayers []*playerReinitInfo
+}
+
+// 自家摸牌
+type DrawEvent struct {
+	Tile      int
+	IsRedFive bool
+
+	// 自家可以进行的操作（自摸、立直、暗杠/加杠等），数据源没有提供时为 nil
+	Options *ActionOptions
+}
+
+// 舍牌
+type DiscardEvent struct {
+	Who         int
+	Tile        int
+	IsRedFive   bool
+	IsTsumogiri bool // 是否为摸切（Who=0 时忽略该值）
+	IsReach     bool // 是否为立直宣言（天凤的立直宣言见 RiichiEvent）
+
+	// 自家可以进行的操作（吃、碰、杠、荣和），没有可以进行的操作时为 nil（Who=0 时忽略该值）
+	Options *ActionOptions
+
+	// 之后会立即收到自家摸牌，此时不刷新屏幕，以免覆盖摸牌的分析
+	// 天凤上家舍牌时可能会先收到自家摸牌
+	IsBeforeSelfDraw bool
+}
+
+// 游戏提供给自家的操作选项
+// 天凤为摸牌/舍牌消息中的 t，雀魂为 operation.operation_list
+type ActionOptions struct {
+	// 他家舍牌时
+	Chi    bool
+	Pon    bool
+	Minkan bool // 大明杠
+	Ron    bool
+
+	// 自家摸牌时
+	Tsumo          bool
+	Riichi         bool
+	SelfKan        bool // 暗杠/加杠
+	KyuushuKyuuhai bool // 九种九牌
+	Kita           bool // 拔北（三人麻将）
+
+	// 数据源没有告知实际可以进行的操作（如 mjai），上面的选项只是用来分析的猜测，不提示给玩家
+	IsGuess bool
+}
+
+// 是否可以吃、碰或大明杠
+func (o *ActionOptions) canCall() bool {
+	return o != nil && (o.Chi || o.Pon || o.Minkan)
+}
+
+// 可以进行的操作的名称
+func (o *ActionOptions) names() (names []string) {
+	if o == nil || o.IsGuess {
+		return
+	}
+	for _, option := range []struct {
+		ok   bool
+		name string
+	}{
+		{o.Chi, "吃"},
+		{o.Pon, "碰"},
+		{o.Minkan, "杠"},
+		{o.Ron, "荣和"},
+		{o.Tsumo, "自摸"},
+		{o.Riichi, "立直"},
+		{o.SelfKan, "杠"},
+		{o.KyuushuKyuuhai, "九种九牌"},
+		{o.Kita, "拔北"},
+	} {
+		if option.ok {
+			names = append(names, tr(option.name))
+		}
+	}
+	return
+}
+
+// 鸣牌（含暗杠、加杠）
+type CallEvent struct {
+	Who  int
+	Meld *model.Meld
+}
+
+// 拔北（三人麻将）
+// 北作为拔北宝牌放到一边，之后从岭上摸牌
+type KitaEvent struct {
+	Who int
+}
+
+// 翻出杠宝牌指示牌
+// 杠宝牌会在对应的摸牌、舍牌或鸣牌之前处理
+type DoraEvent struct {
+	Indicator int
+}
+
+// 立直
+type RiichiEvent struct {
+	Who int
+
+	// false: 立直声明
+	// true: 立直成功（立直宣言牌没有被荣和），扣 1000 点
+	IsAccepted bool
+}
+
+// 振听
+type FuritenEvent struct{}
+
+// 和牌，本局结束
+type WinEvent struct {
+	Whos        []int
+	Points      []int
+	DeltaPoints []int // 各家的点数变化（含本场棒和立直棒）
+
+	// 各家的和牌详情，与 Whos 一一对应，数据源没有提供时为 nil，见 win.go
+	Results []*WinResult
+}
+
+// 流局，本局结束
+type DrawGameEvent struct {
+	Type        int   // 流局类型（荒牌流局、流局满贯、九种九牌等）
+	TenpaiWhos  []int // 荒牌流局/流局满贯时听牌的玩家，途中流局时为 nil
+	DeltaPoints []int // 各家的点数变化，途中流局时为 nil
+}
+
+func (*GameStartEvent) isEvent() {}
+func (*SeatEvent) isEvent()      {}
+func (*InitEvent) isEvent()      {}
+func (*DrawEvent) isEvent()      {}
+func (*DiscardEvent) isEvent()   {}
+func (*CallEvent) isEvent()      {}
+func (*KitaEvent) isEvent()      {}
+func (*DoraEvent) isEvent()      {}
+func (*RiichiEvent) isEvent()    {}
+func (*FuritenEvent) isEvent()   {}
+func (*WinEvent) isEvent()       {}
+func (*DrawGameEvent) isEvent()  {}
+
+//
+
+// 一条消息翻译出的事件，供 DataParser.Next 逐个返回
+type eventQueue struct {
+	msg    interface{}
+	events []Event
+}
+
+// msg 为当前消息，与上次不同时调用 translate 重新翻译
+func (q *eventQueue) next(msg interface{}, translate func() ([]Event, error)) (Event, error) {
+	if q.msg != msg {
+		q.msg = msg
+		events, err := translate()
+		q.events = events
+		if err != nil {
+			q.events = nil
+			return nil, err
+		}
+	}
+	if len(q.events) == 0 {
+		return nil, nil
+	}
+	event := q.events[0]
+	q.events = q.events[1:]
+	return event, nil
+}
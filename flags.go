@@ -1,5 +1,10 @@
 package main
 
+import (
+	"fmt"
+	"strconv"
+)
+
 type flagKV map[string]string
 
 func parseArgs(args []string) (flags flagKV, restArgs []string) {
@@ -41,3 +46,17 @@ func (f flagKV) String(flagNames ...string) string {
 	}
 	return ""
 }
+
+// 整数参数，未设置时为 0
+func (f flagKV) Int(flagNames ...string) (int, error) {
+	for _, name := range flagNames {
+		if val, ok := f[name]; ok {
+			i, err := strconv.Atoi(val)
+			if err != nil {
+				return 0, fmt.Errorf(tr("参数错误: -%s=%s 不是整数"), name, val)
+			}
+			return i, nil
+		}
+	}
+	return 0, nil
+}
@@ -14,3 +14,16 @@ func TestFlagKV_Bool(t *testing.T) {
 	t.Log(flags.Bool("v") == true)
 	t.Log(flags.Bool("v", "ccc") == true)
 }
+
+func TestFlagKV_Int(t *testing.T) {
+	flags, _ := parseArgs([]string{"-seat=2", "-turn=x"})
+	if seat, err := flags.Int("seat"); err != nil || seat != 2 {
+		t.Fatal("应当为 2", seat, err)
+	}
+	if _, err := flags.Int("turn"); err == nil {
+		t.Fatal("应当返回错误")
+	}
+	if n, err := flags.Int("none"); err != nil || n != 0 {
+		t.Fatal("未设置时应当为 0", n, err)
+	}
+}
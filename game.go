@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+)
+
+const (
+	gameLengthTonpuu  = 1 // 东风战
+	gameLengthHanchan = 2 // 半庄战
+)
+
+// 整场游戏的数据，在各个 round 之间保留
+type gameData struct {
+	// 游戏长度（东风战/半庄战），即场风的数量
+	gameLength int
+
+	// 各家点数，0=自家, 1=下家, 2=对家, 3=上家（三人麻将为 0=自家, 1=下家, 2=上家）
+	// 其长度即为玩家人数
+	scores []int
+
+	// 本场数
+	honba int
+
+	// 场上的立直棒数
+	riichiSticks int
+
+	// 当前庄家 0=自家, 1=下家, 2=对家, 3=上家
+	dealer int
+}
+
+func newGameData(gameLength int, playerNumber int) *gameData {
+	// 三人麻将的起始点数为 35000
+	initScore := 25000
+	if playerNumber == 3 {
+		initScore = 35000
+	}
+	scores := make([]int, playerNumber)
+	for i := range scores {
+		scores[i] = initScore
+	}
+	return &gameData{
+		gameLength: gameLength,
+		scores:     scores,
+	}
+}
+
+// round 开始时，用该 round 的数据覆盖原有数据
+func (g *gameData) newRound(dealer int, honba int, riichiSticks int, scores []int) {
+	g.dealer = dealer
+	g.honba = honba
+	g.riichiSticks = riichiSticks
+	// 天凤的三人麻将仍会发送 4 家的点数（最后一家为 0），多余的忽略
+	if len(scores) >= len(g.scores) {
+		copy(g.scores, scores)
+	}
+}
+
+// 立直成功，扣 1000 点，场上的立直棒 +1
+func (g *gameData) reachSuccess(who int) {
+	g.scores[who] -= 1000
+	g.riichiSticks++
+}
+
+// 根据和牌或流局时的点数变化更新各家点数
+// 和牌时场上的立直棒归和牌者所有（已包含在 deltaPoints 中）
+func (g *gameData) applyDeltaPoints(deltaPoints []int, clearRiichiSticks bool) {
+	for who, delta := range deltaPoints {
+		if who < len(g.scores) {
+			g.scores[who] += delta
+		}
+	}
+	if clearRiichiSticks {
+		g.riichiSticks = 0
+	}
+}
+
+// 是否为最后一局（All Last）
+// roundNumber: 场数（如东1为0，东2为1，...，南1为4，...）
+// 三人麻将没有东4、南4，最后一局为东3或南3
+func (g *gameData) isAllLast(roundNumber int) bool {
+	return roundNumber >= 4*(g.gameLength-1)+len(g.scores)-1
+}
+
+// 某家与其他玩家的点差，正数表示领先
+func (g *gameData) scoreDiff(who int, other int) int {
+	return g.scores[who] - g.scores[other]
+}
+
+// 某家的顺位 1-4（三人麻将为 1-3）（同分时按照座位顺序，即离起家越近的越靠前，这里简化为 who 小的靠前）
+func (g *gameData) rank(who int) int {
+	rank := 1
+	for other, score := range g.scores {
+		if other == who {
+			continue
+		}
+		if score > g.scores[who] || score == g.scores[who] && other < who {
+			rank++
+		}
+	}
+	return rank
+}
+
+// 假设各家的点数变化为 deltaPoints，计算此时某家的顺位
+func (g *gameData) rankAfter(who int, deltaPoints []int) int {
+	_g := &gameData{scores: make([]int, len(g.scores))}
+	copy(_g.scores, g.scores)
+	_g.applyDeltaPoints(deltaPoints, false)
+	return _g.rank(who)
+}
+
+func (d *roundData) printScores(w styledWriter) {
+	w.print(trf("%d本场 供托%d |", d.game.honba, d.game.riichiSticks))
+	for who, player := range d.players {
+		w.print(" " + player.name)
+		c := color.FgWhite
+		if who == d.dealer {
+			c = color.FgHiYellow // 亲家高亮
+		}
+		w.print(fmt.Sprint(d.game.scores[who]), c)
+	}
+	w.print("\n")
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"sort"
+	"strings"
+)
+
+// 界面语言
+// 代码中的文字均为中文原文，输出时用 tr/trf 按原文在 messageCatalog 中查找当前语言的翻译，找不到时输出原文
+// 牌名、役名和向听数另有 tileName、yakuName、shantenName
+// 可以通过 -lang=zh/en/ja 或配置文件中的 lang 选择语言，默认为中文
+
+const (
+	langZH = "zh"
+	langEN = "en"
+	langJA = "ja"
+)
+
+var langNames = []string{langZH, langEN, langJA}
+
+// 由 main 根据参数和配置设置
+var lang = langZH
+
+func setLang(name string) error {
+	if name == "" {
+		name = langZH
+	}
+	for _, l := range langNames {
+		if name == l {
+			lang = name
+			return nil
+		}
+	}
+	return fmt.Errorf(tr("未知的语言 %s，可选 %s"), name, strings.Join(langNames, "/"))
+}
+
+func tr(msg string) string {
+	if lang == langZH {
+		return msg
+	}
+	t, ok := messageCatalog[msg]
+	if !ok {
+		return msg
+	}
+	if lang == langEN {
+		return t.en
+	}
+	return t.ja
+}
+
+func trf(format string, a ...interface{}) string {
+	return fmt.Sprintf(tr(format), a...)
+}
+
+//
+
+var (
+	tileNamesEN = [...]string{
+		"1m", "2m", "3m", "4m", "5m", "6m", "7m", "8m", "9m",
+		"1p", "2p", "3p", "4p", "5p", "6p", "7p", "8p", "9p",
+		"1s", "2s", "3s", "4s", "5s", "6s", "7s", "8s", "9s",
+		"East", "South", "West", "North", "White", "Green", "Red",
+	}
+	tileNamesJA = [...]string{
+		"1萬", "2萬", "3萬", "4萬", "5萬", "6萬", "7萬", "8萬", "9萬",
+		"1筒", "2筒", "3筒", "4筒", "5筒", "6筒", "7筒", "8筒", "9筒",
+		"1索", "2索", "3索", "4索", "5索", "6索", "7索", "8索", "9索",
+		"東", "南", "西", "北", "白", "發", "中",
+	}
+)
+
+// 牌名，如 1万、东
+func tileName(tile int) string {
+	switch lang {
+	case langEN:
+		return tileNamesEN[tile]
+	case langJA:
+		return tileNamesJA[tile]
+	}
+	return util.MahjongZH[tile]
+}
+
+// 吃碰时用的两张牌，如 57万、东东
+func openTilesName(tiles []int) string {
+	if tiles[0] < 27 {
+		return string([]rune(tileName(tiles[0]))[:1]) + tileName(tiles[1])
+	}
+	return tileName(tiles[0]) + tileName(tiles[1])
+}
+
+var (
+	yakuNamesEN = map[int]string{
+		util.YakuRiichi:         "Riichi",
+		util.YakuChiitoi:        "Chiitoi",
+		util.YakuTsumo:          "Tsumo",
+		util.YakuIppatsu:        "Ippatsu",
+		util.YakuHaitei:         "Haitei",
+		util.YakuHoutei:         "Houtei",
+		util.YakuRinshan:        "Rinshan",
+		util.YakuChankan:        "Chankan",
+		util.YakuDaburii:        "Double riichi",
+		util.YakuPinfu:          "Pinfu",
+		util.YakuRyanpeikou:     "Ryanpeikou",
+		util.YakuIipeikou:       "Iipeikou",
+		util.YakuSanshokuDoujun: "Sanshoku",
+		util.YakuIttsuu:         "Ittsuu",
+		util.YakuToitoi:         "Toitoi",
+		util.YakuSanAnkou:       "Sanankou",
+		util.YakuSanshokuDoukou: "Sanshoku doukou",
+		util.YakuSanKantsu:      "Sankantsu",
+		util.YakuTanyao:         "Tanyao",
+		util.YakuYakuhai:        "Yakuhai",
+		util.YakuChanta:         "Chanta",
+		util.YakuJunchan:        "Junchan",
+		util.YakuHonroutou:      "Honroutou",
+		util.YakuShousangen:     "Shousangen",
+		util.YakuHonitsu:        "Honitsu",
+		util.YakuChinitsu:       "Chinitsu",
+	}
+	yakuNamesJA = map[int]string{
+		util.YakuRiichi:         "立直",
+		util.YakuChiitoi:        "七対子",
+		util.YakuTsumo:          "ツモ",
+		util.YakuIppatsu:        "一発",
+		util.YakuHaitei:         "海底",
+		util.YakuHoutei:         "河底",
+		util.YakuRinshan:        "嶺上",
+		util.YakuChankan:        "槍槓",
+		util.YakuDaburii:        "ダブリー",
+		util.YakuPinfu:          "平和",
+		util.YakuRyanpeikou:     "二盃口",
+		util.YakuIipeikou:       "一盃口",
+		util.YakuSanshokuDoujun: "三色",
+		util.YakuIttsuu:         "一通",
+		util.YakuToitoi:         "対々",
+		util.YakuSanAnkou:       "三暗刻",
+		util.YakuSanshokuDoukou: "三色同刻",
+		util.YakuSanKantsu:      "三槓子",
+		util.YakuTanyao:         "断么",
+		util.YakuYakuhai:        "役牌",
+		util.YakuChanta:         "チャンタ",
+		util.YakuJunchan:        "純チャン",
+		util.YakuHonroutou:      "混老頭",
+		util.YakuShousangen:     "小三元",
+		util.YakuHonitsu:        "混一色",
+		util.YakuChinitsu:       "清一色",
+	}
+)
+
+func yakuName(yakuType int) string {
+	switch lang {
+	case langEN:
+		return yakuNamesEN[yakuType]
+	case langJA:
+		return yakuNamesJA[yakuType]
+	}
+	return util.YakuNameMap[yakuType]
+}
+
+// 同 util.YakuTypesToStr
+func yakuTypesToStr(yakuTypes []int) string {
+	if len(yakuTypes) == 0 {
+		return tr("[无役]")
+	}
+	names := []string{}
+	for _, t := range yakuTypes {
+		names = append(names, yakuName(t))
+	}
+	return fmt.Sprint(names)
+}
+
+// 同 util.YakuTypesWithDoraToStr
+func yakuTypesWithDoraToStr(yakuTypes map[int]struct{}, numDora int) string {
+	if len(yakuTypes) == 0 {
+		return tr("[无役]")
+	}
+	yt := []int{}
+	for t := range yakuTypes {
+		yt = append(yt, t)
+	}
+	sort.Ints(yt)
+	names := []string{}
+	for _, t := range yt {
+		names = append(names, yakuName(t))
+	}
+	if numDora > 0 {
+		names = append(names, trf("宝牌%d", numDora))
+	}
+	return fmt.Sprint(names)
+}
+
+var shantenNamesJA = []string{"和了", "聴牌", "一向聴", "二向聴", "三向聴", "四向聴", "五向聴", "六向聴", "七向聴", "八向聴"}
+
+// -1=和了，0=听牌，1=一向听，……
+func shantenName(shanten int) string {
+	switch lang {
+	case langEN:
+		switch shanten {
+		case -1:
+			return "Agari"
+		case 0:
+			return "Tenpai"
+		}
+		return fmt.Sprintf("%d-shanten", shanten)
+	case langJA:
+		return shantenNamesJA[shanten+1]
+	}
+	return util.NumberToChineseShanten(shanten)
+}
+
+// 自家、下家、对家、上家
+func playerNames(playerNumber int) []string {
+	names := []string{"自家", "下家", "对家", "上家"}
+	if playerNumber == 3 {
+		// 三人麻将
+		names = []string{"自家", "下家", "上家"}
+	}
+	for i, name := range names {
+		names[i] = tr(name)
+	}
+	return names
+}
+
+//
+
+// 各条文字的英文和日文翻译，以中文原文为键
+var messageCatalog = map[string]struct{ en, ja string }{
+	// 玩家
+	"自家": {"Self", "自家"},
+	"下家": {"Right", "下家"},
+	"对家": {"Across", "対面"},
+	"上家": {"Left", "上家"},
+
+	// 启动
+	"日本麻将助手 %s (by EndlessCheng)": {"Japanese Mahjong Helper %s (by EndlessCheng)", "日本麻雀アシスタント %s (by EndlessCheng)"},
+	"使用说明：":                       {"Usage: ", "使い方："},
+	"问题反馈：":                       {"Issues: ", "不具合報告："},
+	"吐槽群：":                        {"QQ group: ", "QQグループ："},
+	"请输入数字，以选择对应的平台：":             {"Enter a number to choose the platform:", "数字を入力してプラットフォームを選択してください："},
+	"天凤":       {"Tenhou", "天鳳"},
+	"雀魂":       {"Mahjong Soul", "雀魂"},
+	"（水晶杠杠版）":  {" (Crystal Kan edition)", "（水晶カンカン版）"},
+	"已选择 - %s": {"Selected - %s", "選択済み - %s"},
+	"提醒：若您已登录游戏，请刷新网页，或者开启一局人机对战\n该步骤用于获取您的账号 ID，便于在游戏开始时分析自风，否则程序将无法解析后续数据": {
+		"Note: if you are already logged in, refresh the page or start a game against bots\nThis step gets your account ID so that your seat wind can be determined at game start; otherwise later data cannot be parsed",
+		"注意：ログイン済みの場合は、ページを再読み込みするか、AI との対戦を開始してください\nこの手順でアカウント ID を取得し、対局開始時に自風を判定します。取得できないと以降のデータを解析できません",
+	},
+	"您的账号 ID 为 ": {"Your account ID is ", "あなたのアカウント ID は "},
+	"，该数字为雀魂服务器账号数据库中的 ID，该值越小表示您的注册时间越早\n": {
+		", the ID in the Mahjong Soul account database; a smaller value means an earlier registration\n",
+		"。雀魂サーバーのアカウントデータベース上の ID で、小さいほど登録が早いことを示します\n",
+	},
+	"检测到新版本: %s！请前往 %s 下载": {"New version %s available! Download it from %s", "新しいバージョン %s があります！%s からダウンロードしてください"},
+	"按任意键退出...":            {"Press any key to exit...", "任意のキーを押して終了..."},
+	"未知的输出格式 %s，可选 %s":     {"Unknown output format %s, choose from %s", "不明な出力形式 %s、%s から選択してください"},
+	"未知的语言 %s，可选 %s":       {"Unknown language %s, choose from %s", "不明な言語 %s、%s から選択してください"},
+
+	// 服务器
+	"错误：":       {"Error:", "エラー："},
+	"%s 登录成功\n": {"%s logged in\n", "%s ログイン成功\n"},
+	"好友账号ID   好友上次登录时间        好友上次登出时间       好友昵称": {"Friend ID   Last login              Last logout             Nickname", "フレンドID   最終ログイン            最終ログアウト          ニックネーム"},
+	"[提醒] 从配置中读取出雀魂账号 %d":                          {"[Note] Mahjong Soul account %d loaded from config", "[お知らせ] 設定から雀魂アカウント %d を読み込みました"},
+	"%s 端口已被占用，程序无法启动（是否已经开启了本程序？）":                {"Port %s is in use, cannot start (is another instance already running?)", "%s ポートは使用中のため起動できません（既に起動していませんか？）"},
+
+	// 对局
+	"游戏即将开始，您分配到的座位是：": {"The game is about to start. Your seat: ", "まもなく対局開始、あなたの席は："},
+	"重连成功，已恢复本局数据":     {"Reconnected, round state restored", "再接続しました。この局のデータを復元しました"},
+	"%s%d局开始，自风为%s\n":  {"%s %d started, seat wind: %s\n", "%s%d局開始、自風は%s\n"},
+	"%s%d局，自风为%s\n":    {"%s %d, seat wind: %s\n", "%s%d局、自風は%s\n"},
+	"%s%d局 %d本场":       {"%s %d, honba %d", "%s%d局 %d本場"},
+	"%s%d局 %d本场 第%d巡":  {"%s %d, honba %d, turn %d", "%s%d局 %d本場 %d巡目"},
+	"%d本场 供托%d |":      {"Honba %d, riichi sticks %d |", "%d本場 供託%d |"},
+	"宝牌指示牌是 %s":        {"Dora indicator: %s", "ドラ表示牌は %s"},
+	"杠宝牌指示牌是 %s":       {"Kan dora indicator: %s", "槓ドラ表示牌は %s"},
+	"数据异常: %s 数量为 %d":  {"Invalid data: count of %s is %d", "データ異常: %s の枚数が %d"},
+	"玩家数据异常 %#v":       {"Invalid player data %#v", "プレイヤーデータ異常 %#v"},
+	"内部错误：%v":          {"Internal error: %v", "内部エラー：%v"},
+	"可以%s":             {"Can %s", "%s可能"},
+	"、":                {", ", "、"},
+	"振听":               {"Furiten", "フリテン"},
+	"%s 摸切立直！":         {"%s declared riichi with tsumogiri!", "%s ツモ切りリーチ！"},
+	"和牌，本局结束":          {"Win, round over", "和了、この局は終了"},
+	"凤 凰 级 避 铳":        {"Phoenix-level deal-in avoidance", "鳳 凰 級 の 回 避"},
+	"（快醒醒，这是雀魂）":       {"(wake up, this is Mahjong Soul)", "（目を覚まして、これは雀魂です）"},
+	"%s，本局结束\n":        {"%s, round over\n", "%s、この局は終了\n"},
+	"全员未听":             {"No one tenpai", "全員ノーテン"},
+	"听牌:":              {"Tenpai:", "聴牌:"},
+
+	// 操作和流局
+	"吃":    {"Chi", "チー"},
+	"碰":    {"Pon", "ポン"},
+	"杠":    {"Kan", "カン"},
+	"荣和":   {"Ron", "ロン"},
+	"自摸":   {"Tsumo", "ツモ"},
+	"立直":   {"Riichi", "リーチ"},
+	"拔北":   {"Kita", "抜き北"},
+	"九种九牌": {"Nine terminals", "九種九牌"},
+	"荒牌流局": {"Exhaustive draw", "荒牌流局"},
+	"流局满贯": {"Nagashi mangan", "流し満貫"},
+	"四风连打": {"Four winds", "四風連打"},
+	"四家立直": {"Four riichi", "四家立直"},
+	"四杠散了": {"Four kans", "四槓散了"},
+	"三家和了": {"Triple ron", "三家和"},
+
+	// 立直后
+	"%s 放出了和了牌 %s，但是已经立直振听": {"%s discarded your winning tile %s, but you are in riichi furiten", "%s が和了牌 %s を捨てたが、立直後のフリテン"},
+	"%s 放出了和了牌 %s，荣和 %d 点":  {"%s discarded your winning tile %s, ron for %d", "%s が和了牌 %s を捨てた、ロン %d 点"},
+	"（河底）": {" (houtei)", "（河底）"},
+	"（海底）": {" (haitei)", "（海底）"},
+	"已没有自摸的机会，建议荣和": {"No tsumo chance left, ron recommended", "ツモの機会はもうない、ロン推奨"},
+	"若见逃，自摸 %d 点":   {"If skipped, tsumo for %d", "見逃した場合、ツモ %d 点"},
+	"，期望里宝 %.1f 枚":  {", expected ura dora %.1f", "、裏ドラ期待値 %.1f 枚"},
+	"建议荣和":          {"Ron recommended", "ロン推奨"},
+	"All Last: 计入里宝后，荣和平均为第 %.2f 位，自摸平均为第 %.2f 位": {"All Last: with ura dora, average rank %.2f after ron, %.2f after tsumo", "オーラス：裏ドラ込みでロンは平均 %.2f 位、ツモは平均 %.2f 位"},
+	"自摸可以提升顺位，可以考虑见逃（见逃后立直振听，只能自摸）":               {"Tsumo improves your rank, consider skipping (riichi furiten after skipping, tsumo only)", "ツモなら順位が上がる、見逃しも検討（見逃し後は立直フリテン、ツモのみ）"},
+	"自摸 %s，和了！":        {"Tsumo %s, agari!", "ツモ %s、和了！"},
+	"可以暗杠 %s（不改变听牌）":   {"Can ankan %s (waits unchanged)", "%s を暗槓できます（待ち不変）"},
+	"暗杠 %s 会改变听牌，不能暗杠": {"Ankan %s would change waits, not allowed", "%s の暗槓は待ちが変わるため不可"},
+	"摸切 %s":   {"Tsumogiri %s", "ツモ切り %s"},
+	"（立直振听）":  {" (riichi furiten)", "（立直フリテン）"},
+	"见逃，立直振听": {"Skipped, riichi furiten", "見逃し、立直フリテン"},
+
+	// 和牌
+	"役满":           {"Yakuman", "役満"},
+	"%d倍役满":        {"%dx yakuman", "%d倍役満"},
+	"累计役满":         {"Kazoe yakuman", "数え役満"},
+	"三倍满":          {"Sanbaiman", "三倍満"},
+	"倍满":           {"Baiman", "倍満"},
+	"跳满":           {"Haneman", "跳満"},
+	"满贯":           {"Mangan", "満貫"},
+	"%s %d番":       {"%s %d han", "%s %d翻"},
+	"宝牌":           {"Dora", "ドラ"},
+	"赤宝牌":          {"Aka dora", "赤ドラ"},
+	"里宝牌":          {"Ura dora", "裏ドラ"},
+	"拔北宝牌":         {"Kita dora", "抜きドラ"},
+	"%d符 %d番":      {"%d fu %d han", "%d符 %d翻"},
+	" %d点\n":       {" %d points\n", " %d点\n"},
+	"宝牌%d":         {"Dora %d", "ドラ%d"},
+	"打点校验：计算结果为无役": {"Score check: calculated as no yaku", "打点チェック：計算結果は役なし"},
+	"打点校验：实际为 %d符%d番，计算结果为 %d符%d番 %s": {"Score check: actual %d fu %d han, calculated %d fu %d han %s", "打点チェック：実際は %d符%d翻、計算結果は %d符%d翻 %s"},
+
+	// 铳率
+	"%s安牌:":      {"%s safe:", "%s安牌:"},
+	"综合安牌:":      {"Overall safe:", "総合安牌:"},
+	"愚形听牌/振听":    {"bad wait/furiten", "愚形聴牌/フリテン"},
+	"可能愚形听牌/振听":  {"maybe bad wait/furiten", "愚形聴牌/フリテンの可能性"},
+	"[%d无筋: %s]": {"[%d non-suji: %s]", "[%d無筋: %s]"},
+	"自家牌河:":      {"Our river:", "自家の河:"},
+	"牌河易读，两面待容易被看穿":        {"easy to read, a ryanmen wait is likely to be spotted", "読まれやすい河、両面待ちは見抜かれやすい"},
+	"他家视角下待牌的铳率: %s":       {"Deal-in rate of our waits as seen by others: %s", "他家から見た待ち牌の放銃率: %s"},
+	"筋牌陷阱: %s，他家容易放铳":      {"Suji trap: %s, likely to be discarded by others", "筋引っかけ: %s、他家が放銃しやすい"},
+	"待牌在他家看来较危险，立直后他家容易弃和": {"Our waits look dangerous to others, they are likely to fold against a riichi", "待ち牌は他家から見て危険、リーチすると降りられやすい"},
+	"[%d无筋]": {"[%d non-suji]", "[%d無筋]"},
+
+	// 何切
+	"%s：":            {"%s:", "%s："},
+	"吃,":             {" chi, ", "チー,"},
+	"碰,":             {" pon, ", "ポン,"},
+	"吃，":             {" chi, ", "チー、"},
+	"碰，":             {" pon, ", "ポン、"},
+	"切":              {"discard ", "打"},
+	"切 ":             {"discard ", "打 "},
+	"进张":             {" waits", "受入"},
+	"听牌数":            {"tenpai waits", "聴牌枚数"},
+	"%-6.2f[%2d 改良]": {"%-6.2f[%2d improves]", "%-6.2f[%2d 改良]"},
+	"（%.2f%% 参考和率）":  {" (%.2f%% est. win rate)", "（%.2f%% 参考和了率）"},
+	"（%.2f 综合分）":     {" (%.2f score)", "（%.2f 総合点）"},
+	"%5.2f%% 参考和率":   {"%5.2f%% est. win rate", "%5.2f%% 参考和了率"},
+	"[%5.2f速度]":      {"[%5.2f speed]", "[%5.2f速度]"},
+	"[局收支%4d]":       {"[EV %4d]", "[局収支%4d]"},
+	"[荣和%d]":         {"[Ron %d]", "[ロン%d]"},
+	"[默听%d]":         {"[Dama %d]", "[ダマ%d]"},
+	"[立直%d]":         {"[Riichi %d]", "[リーチ%d]"},
+	"[无役]":           {"[No yaku]", "[役なし]"},
+	"[可能振听]":         {"[Maybe furiten]", "[フリテンの可能性]"},
+	"[振听]":           {"[Furiten]", "[フリテン]"},
+	"[%2d改良]":        {"[%2d improves]", "[%2d改良]"},
+	"摸 %s 改良成 %s\n":  {"draw %s improves to %s\n", "%s ツモで %s に改良\n"},
+	"当前%s":           {"Now %s", "現在%s"},
+	"鸣牌后%s":          {"%s after call", "鳴き後%s"},
+	"【已胡牌】":          {"[Agari]", "【和了】"},
+	"建议向听倒退":         {"Consider going back a shanten", "向聴戻し推奨"},
+	"默听打点充足：追求和率默听，追求打点立直": {"Dama score is enough: dama for win rate, riichi for score", "ダマで打点十分：和了率重視ならダマ、打点重視ならリーチ"},
+
+	// 参数错误
+	"参数错误: %d 张牌":                  {"Invalid input: %d tiles", "入力エラー: %d 枚"},
+	"参数错误: 某种牌超过了 4 张":             {"Invalid input: more than 4 of a tile", "入力エラー: 4 枚を超えた牌があります"},
+	"参数错误: %s 不是风牌":                {"Invalid input: %s is not a wind", "入力エラー: %s は風牌ではありません"},
+	"参数错误: 鸣牌分析时手牌不能为 %d 张":        {"Invalid input: hand cannot have %d tiles for call analysis", "入力エラー: 鳴き分析では手牌を %d 枚にできません"},
+	"参数错误: %s 超过了 4 张":             {"Invalid input: more than 4 of %s", "入力エラー: %s が 4 枚を超えています"},
+	"未知的副露类型 %s":                   {"Unknown meld type %s", "不明な副露の種類 %s"},
+	"副露 %s 不是%s":                   {"Meld %s is not a %s", "副露 %s は %s ではありません"},
+	"副露 %s 中没有 %s":                 {"Meld %s does not contain %s", "副露 %s に %s がありません"},
+	"赤5":                           {"a red five", "赤5"},
+	"参数错误: 副露 %s 缺少 %c":            {"Invalid input: meld %s is missing %c", "入力エラー: 副露 %s に %c がありません"},
+	"参数错误: %s 中有多个 &":              {"Invalid input: more than one & in %s", "入力エラー: %s に & が複数あります"},
+	"参数错误: %s 中有多个 +":              {"Invalid input: more than one + in %s", "入力エラー: %s に + が複数あります"},
+	"参数错误: %s 中的赤5超过了 5 的个数":       {"Invalid input: more red fives than fives in %s", "入力エラー: %s の赤5が5の枚数を超えています"},
+	"参数错误: 副露后不能立直":                {"Invalid input: cannot riichi with open melds", "入力エラー: 副露後はリーチできません"},
+	"参数错误: 第 %d 巡":                 {"Invalid input: turn %d", "入力エラー: %d 巡目"},
+	"参数错误: -%s=%s 不是整数":            {"Invalid input: -%s=%s is not an integer", "入力エラー: -%s=%s は整数ではありません"},
+	"参数错误: -dealer 和 -wind 不能同时使用": {"Invalid input: -dealer and -wind cannot be used together", "入力エラー: -dealer と -wind は同時に指定できません"},
+
+	// 交互模式
+	"切牌，0m 为赤5":               {"Discard a tile, 0m is a red five", "打牌、0m は赤5"},
+	"摸牌，包括岭上牌":                {"Draw a tile, including after a kan", "ツモ（嶺上牌を含む）"},
+	"吃他家打出的 3m，用手牌中的 45m":     {"Chi the discarded 3m with 45m from the hand", "捨てられた 3m を手牌の 45m でチー"},
+	"碰他家打出的牌":                 {"Pon a discarded tile", "捨て牌をポン"},
+	"杠，根据手牌自动判断暗杠、大明杠或加杠":     {"Kan, closed/open/added is decided from the hand", "カン（暗槓・大明槓・加槓は手牌から判断）"},
+	"立直，可以同时切牌":               {"Declare riichi, optionally with the discard", "リーチ（打牌も同時に指定可）"},
+	"翻开宝牌指示牌":                 {"Reveal a dora indicator", "ドラ表示牌をめくる"},
+	"他家牌河、副露中出现的牌":            {"Tiles seen in other rivers or melds", "他家の河・副露に見えた牌"},
+	"设置场风":                    {"Set the round wind", "場風を設定"},
+	"设置自风，自风为东时为亲家":           {"Set the seat wind, East is the dealer", "自風を設定、東なら親"},
+	"撤销上一步":                   {"Undo the last step", "一手戻す"},
+	"重做撤销的一步":                 {"Redo an undone step", "戻した手をやり直す"},
+	"查看已执行的命令":                {"Show the command history", "実行したコマンドを表示"},
+	"保存对局，如 save hand.json":   {"Save the session, e.g. save hand.json", "対局を保存、例: save hand.json"},
+	"读取对局，如 load hand.json":   {"Load a session, e.g. load hand.json", "対局を読み込む、例: load hand.json"},
+	"退出":                      {"Quit", "終了"},
+	"输入 help 查看所有命令":          {"Type help to list all commands", "help でコマンド一覧を表示"},
+	"未知的命令 %s，输入 help 查看所有命令": {"Unknown command %s, type help to list all commands", "不明なコマンド %s、help で一覧を表示"},
+	"切掉的牌不存在":                 {"That tile is not in the hand", "その牌は手牌にありません"},
+	"不可能摸更多的牌了":               {"No more of that tile can be drawn", "その牌はもうツモれません"},
+	"现在不能切牌":                  {"Cannot discard now", "今は打牌できません"},
+	"现在不能摸牌":                  {"Cannot draw now", "今はツモできません"},
+	"现在不能鸣牌":                  {"Cannot call now", "今は鳴けません"},
+	"立直后不能鸣牌":                 {"Cannot call after riichi", "リーチ後は鳴けません"},
+	"已经立直了":                   {"Already in riichi", "既にリーチしています"},
+	"手牌中没有足够的牌":               {"Not enough tiles in the hand", "手牌の枚数が足りません"},
+	"参数错误: 需要一张牌":             {"Invalid input: one tile is required", "入力エラー: 牌を 1 枚指定してください"},
+	"参数错误: 需要一张风牌":            {"Invalid input: one wind tile is required", "入力エラー: 風牌を 1 枚指定してください"},
+	"参数错误: 需要文件名":             {"Invalid input: a file name is required", "入力エラー: ファイル名を指定してください"},
+	"参数错误: 需要被吃的牌和手牌中的两张牌，如 chi 3m 45m": {"Invalid input: give the called tile and two tiles from the hand, e.g. chi 3m 45m", "入力エラー: 鳴く牌と手牌の 2 枚を指定してください、例: chi 3m 45m"},
+	"交互模式中不能用 + 分析鸣牌":                   {"Call analysis with + is not available in interactive mode", "対話モードでは + による鳴き分析はできません"},
+	"对局文件 %s 中没有初始手牌":                   {"Session file %s has no initial hand", "対局ファイル %s に初期手牌がありません"},
+	"已保存至 %s":                           {"Saved to %s", "%s に保存しました"},
+	"没有可以撤销的命令":                         {"Nothing to undo", "戻せる手がありません"},
+	"没有可以重做的命令":                         {"Nothing to redo", "やり直せる手がありません"},
+	"（已撤销）":                             {"(undone)", "（取り消し済み）"},
+
+	// 牌谱重放
+	"第 %d 行 雀魂帧解码失败：%v":           {"Line %d: failed to decode Mahjong Soul frame: %v", "%d 行目 雀魂フレームのデコードに失敗：%v"},
+	"%s 中没有可以重放的消息":               {"No replayable messages in %s", "%s に再生できるメッセージがありません"},
+	"%s 中没有可以重放的对局":               {"No replayable games in %s", "%s に再生できる対局がありません"},
+	"第 %d 行 错误：%v\n":              {"Line %d: error: %v\n", "%d 行目 エラー：%v\n"},
+	"%3d: %s（第 %d 行）\n":           {"%3d: %s (line %d)\n", "%3d: %s（%d 行目）\n"},
+	"已经是最后一步":                     {"Already at the last step", "既に最後のステップです"},
+	"已经是第一步":                      {"Already at the first step", "既に最初のステップです"},
+	"局数有误":                        {"Invalid round number", "局番号が正しくありません"},
+	"共有 %d 条消息解析出错":               {"%d messages failed to parse", "%d 件のメッセージの解析に失敗しました"},
+	"重放完成：共 %d 条消息，%d 局，%d 个错误\n": {"Replay finished: %d messages, %d rounds, %d errors\n", "再生完了：メッセージ %d 件、%d 局、エラー %d 件\n"},
+	"回车/n: 下一步  b: 上一步  r <局>: 跳到第几局  l: 局列表  c: 重放到结束  q: 退出": {
+		"Enter/n: next  b: back  r <round>: jump to round  l: round list  c: replay to the end  q: quit",
+		"Enter/n: 次へ  b: 戻る  r <局>: 指定した局へ  l: 局一覧  c: 最後まで再生  q: 終了",
+	},
+
+	// 雀魂
+	"尚未正确获取到玩家账号 ID，请您刷新网页，或开启一局人机对战（错误信息：您的账号 ID %d 不在对战列表 %v 中）": {
+		"Your account ID has not been obtained correctly. Refresh the page or start a game against bots (error: account ID %d is not in the seat list %v)",
+		"アカウント ID を正しく取得できていません。ページを再読み込みするか、AI との対戦を開始してください（エラー：アカウント ID %d が対戦リスト %v にありません）",
+	},
+	"等待玩家准备 (%d/%d) %v": {"Waiting for players (%d/%d) %v", "プレイヤーの準備待ち (%d/%d) %v"},
+	"重连数据解析错误：":         {"Failed to parse reconnect data:", "再接続データの解析エラー："},
+	"操作没有名称":            {"Action has no name", "操作に名前がありません"},
+
+	// 牌谱
+	"关闭牌谱文件失败:": {"Failed to close the record file:", "牌譜ファイルを閉じられませんでした:"},
+	"推送分析结果失败:": {"Failed to push analysis results:", "解析結果の送信に失敗しました:"},
+	"创建牌谱文件失败:": {"Failed to create the record file:", "牌譜ファイルを作成できませんでした:"},
+	"写入牌谱失败:":   {"Failed to write the record:", "牌譜の書き込みに失敗しました:"},
+
+	// 和牌结果中平台提供的役名，util 中没有对应役种的役满和需要区分牌种的役牌
+	"自风 东":    {"Seat wind East", "自風 東"},
+	"自风 南":    {"Seat wind South", "自風 南"},
+	"自风 西":    {"Seat wind West", "自風 西"},
+	"自风 北":    {"Seat wind North", "自風 北"},
+	"场风 东":    {"Round wind East", "場風 東"},
+	"场风 南":    {"Round wind South", "場風 南"},
+	"场风 西":    {"Round wind West", "場風 西"},
+	"场风 北":    {"Round wind North", "場風 北"},
+	"役牌 白":    {"Yakuhai White", "役牌 白"},
+	"役牌 发":    {"Yakuhai Green", "役牌 發"},
+	"役牌 中":    {"Yakuhai Red", "役牌 中"},
+	"人和":      {"Renhou", "人和"},
+	"天和":      {"Tenhou", "天和"},
+	"地和":      {"Chiihou", "地和"},
+	"大三元":     {"Daisangen", "大三元"},
+	"四暗刻":     {"Suuankou", "四暗刻"},
+	"四暗刻单骑":   {"Suuankou tanki", "四暗刻単騎"},
+	"字一色":     {"Tsuuiisou", "字一色"},
+	"绿一色":     {"Ryuuiisou", "緑一色"},
+	"清老头":     {"Chinroutou", "清老頭"},
+	"九莲宝灯":    {"Chuuren poutou", "九蓮宝燈"},
+	"纯正九莲宝灯":  {"Junsei chuuren poutou", "純正九蓮宝燈"},
+	"国士无双":    {"Kokushi musou", "国士無双"},
+	"国士无双十三面": {"Kokushi musou 13-sided wait", "国士無双十三面"},
+	"大四喜":     {"Daisuushii", "大四喜"},
+	"小四喜":     {"Shousuushii", "小四喜"},
+	"四杠子":     {"Suukantsu", "四槓子"},
+	"未知役%d":   {"Unknown yaku %d", "不明な役%d"},
+
+	// 全屏界面
+	"牌河":        {"Rivers", "河"},
+	"铳率":        {"Deal-in risk", "放銃率"},
+	"何切":        {"Discards", "何切る"},
+	"提示":        {"Messages", "お知らせ"},
+	"自风%s":      {"seat wind %s", "自風%s"},
+	"手牌: %s":    {"Hand: %s", "手牌: %s"},
+	"[听牌率%d%%]": {"[tenpai %d%%]", "[聴牌率%d%%]"},
+	"宝牌指示牌 %s  宝牌 %s  牌山剩余 %d 张": {"Dora indicators %s  Dora %s  Wall %d tiles left", "ドラ表示牌 %s  ドラ %s  残り %d 枚"},
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestMessageCatalog(t *testing.T) {
+	// 翻译中的格式化动词须与原文一致
+	verbRegexp := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for msg, translation := range messageCatalog {
+		verbs := verbRegexp.FindAllString(msg, -1)
+		for _, s := range []string{translation.en, translation.ja} {
+			if s == "" {
+				t.Fatal("缺少翻译", msg)
+			}
+			if !reflect.DeepEqual(verbRegexp.FindAllString(s, -1), verbs) {
+				t.Fatal("格式化动词不一致", msg, s)
+			}
+		}
+	}
+
+	for _, names := range []map[int]string{yakuNamesEN, yakuNamesJA} {
+		for yakuType := range util.YakuNameMap {
+			if names[yakuType] == "" {
+				t.Fatal("缺少役名", util.YakuNameMap[yakuType])
+			}
+		}
+	}
+}
+
+func TestSetLang(t *testing.T) {
+	defer setLang(langZH)
+
+	if err := setLang("fr"); err == nil || lang != langZH {
+		t.Fatal("应当返回错误")
+	}
+
+	if err := setLang(langJA); err != nil {
+		t.Fatal(err)
+	}
+	if tileName(33) != "中" || tileName(32) != "發" || shantenName(1) != "一向聴" || yakuName(util.YakuPinfu) != "平和" {
+		t.Fatal("日文有误")
+	}
+
+	if err := setLang(langEN); err != nil {
+		t.Fatal(err)
+	}
+	if tileName(27) != "East" || shantenName(0) != "Tenpai" || shantenName(2) != "2-shanten" || tr("振听") != "Furiten" || trf("[立直%d]", 2000) != "[Riichi 2000]" {
+		t.Fatal("英文有误")
+	}
+	if s := yakuTypesWithDoraToStr(map[int]struct{}{util.YakuTanyao: {}, util.YakuRiichi: {}}, 2); s != "[Riichi Tanyao Dora 2]" {
+		t.Fatal("役种有误", s)
+	}
+	if names := playerNames(3); !reflect.DeepEqual(names, []string{"Self", "Right", "Left"}) {
+		t.Fatal("玩家名有误", names)
+	}
+}
+
+func TestEnglishAnalysisOutput(t *testing.T) {
+	defer setLang(langZH)
+	setLang(langEN)
+
+	buf := &bytes.Buffer{}
+	r, _ := newRenderer("plain", buf)
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	// 何切、鸣牌和已胡牌
+	for _, humanTiles := range []string{"24m 55p 3456789s 115z", "33567789m 46s", "123456789m 11p 123s"} {
+		playerInfo := model.NewSimplePlayerInfo(util.MustStrToTiles34(humanTiles), nil)
+		if util.CountOfTiles34(playerInfo.HandTiles34)%3 == 1 {
+			analysisMeld(playerInfo, 5, false, true, true, nil)
+		} else if _, err := analysisTiles34(playerInfo, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 下家立直，2m 已见 4 张、2s 3s 各剩 1 张，手牌中的 1m 为 NC 安牌，1s 为 OC 安牌
+	hands := util.MustStrToTiles34("1m 1s 5p")
+	leftCounts := make([]int, 34)
+	for i := range leftCounts {
+		leftCounts[i] = 4
+	}
+	leftCounts[1] = 0
+	leftCounts[19], leftCounts[20] = 1, 1
+	riskTables := riskInfoList{{}, {tenpaiRate: 100, riskTable: make(riskTable, 34), leftNoSujiTiles: []int{0, 8}}, {}, {}}
+	outputRenderer.renderRisks(riskTables, hands, leftCounts)
+
+	out := buf.String()
+	if !strings.Contains(out, "1-shanten:") || !strings.Contains(out, "[Agari]") || !strings.Contains(out, " chi, discard ") {
+		t.Fatal("输出有误", out)
+	}
+	if !strings.Contains(out, "NC: 1m") || !strings.Contains(out, "OC: 1s") {
+		t.Fatal("NC/OC 安牌有误", out)
+	}
+	for _, c := range out {
+		if unicode.Is(unicode.Han, c) {
+			t.Fatal("含有未翻译的文字", out)
+		}
+	}
+}
+
+func TestWinYakuDisplayName(t *testing.T) {
+	defer setLang(langZH)
+	setLang(langEN)
+
+	for _, tc := range []struct {
+		yaku     *WinYaku
+		expected string
+	}{
+		{&WinYaku{YakuType: util.YakuTsumo, Name: "门前清自摸和"}, "Tsumo"},
+		{&WinYaku{YakuType: util.YakuYakuhai, Name: "役牌 白"}, "Yakuhai White"},
+		{&WinYaku{YakuType: winYakuTypeUnknown, Name: "国士无双"}, "Kokushi musou"},
+	} {
+		if name := tc.yaku.displayName(); name != tc.expected {
+			t.Fatal("役名有误", tc.yaku.Name, name)
+		}
+	}
+}
@@ -1,77 +1,519 @@
 package main
 
 import (
-	"github.com/EndlessCheng/mahjong-helper/util"
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"github.com/EndlessCheng/mahjong-helper/util"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"io/ioutil"
+	"os"
+	"strings"
 )
 
-func interact(raw string) {
-	tiles34, err := analysisHumanTiles(model.NewSimpleHumanTilesInfo(raw))
+// 交互模式
+// 初始手牌和局况同命令行分析，之后每行输入一条命令，如 切 3m、摸 4p、碰 7z，输入 help 查看所有命令
+// 直接输入一张牌时，手牌为 3n+2 张则切牌，否则摸牌
+// 改变局面的命令都会记录下来，撤销和重做通过从初始局面重放这些命令实现，保存的对局文件即为初始局面和命令列表
+
+// 改变局面的命令
+type interactCommand struct {
+	name    string   // 记录到对局文件中的命令名
+	aliases []string // 其他写法
+	args    string   // 参数说明
+	help    string
+	run     func(pi *model.PlayerInfo, args []string) error
+}
+
+var interactCommands = []*interactCommand{
+	{"discard", []string{"d", "切"}, "3m", "切牌，0m 为赤5", interactDiscard},
+	{"draw", []string{"t", "摸"}, "3m", "摸牌，包括岭上牌", interactDraw},
+	{"chi", []string{"吃"}, "3m 45m", "吃他家打出的 3m，用手牌中的 45m", interactChi},
+	{"pon", []string{"碰"}, "7z", "碰他家打出的牌", interactPon},
+	{"kan", []string{"杠"}, "5p", "杠，根据手牌自动判断暗杠、大明杠或加杠", interactKan},
+	{"riichi", []string{"立直"}, "[3m]", "立直，可以同时切牌", interactRiichi},
+	{"dora", []string{"宝牌"}, "1z", "翻开宝牌指示牌", interactDora},
+	{"seen", []string{"见"}, "19p 1z", "他家牌河、副露中出现的牌", interactSeen},
+	{"round", []string{"场风"}, "S", "设置场风", interactRoundWind},
+	{"wind", []string{"自风"}, "W", "设置自风，自风为东时为亲家", interactSelfWind},
+}
+
+func findInteractCommand(name string) *interactCommand {
+	for _, command := range interactCommands {
+		if name == command.name {
+			return command
+		}
+		for _, alias := range command.aliases {
+			if name == alias {
+				return command
+			}
+		}
+	}
+	return nil
+}
+
+func interactHelp() {
+	for _, command := range interactCommands {
+		fmt.Printf("  %-7s %-8s %s（%s）\n", command.name, command.args, tr(command.help), strings.Join(command.aliases, "/"))
+	}
+	for _, line := range [][2]string{
+		{"undo", "撤销上一步"},
+		{"redo", "重做撤销的一步"},
+		{"history", "查看已执行的命令"},
+		{"save", "保存对局，如 save hand.json"},
+		{"load", "读取对局，如 load hand.json"},
+		{"quit", "退出"},
+	} {
+		fmt.Printf("  %-16s %s\n", line[0], tr(line[1]))
+	}
+}
+
+// 解析一张牌，返回这张牌是否为赤5
+func parseInteractTile(args []string) (tile34 int, isRedFive bool, err error) {
+	if len(args) != 1 {
+		return -1, false, errors.New(tr("参数错误: 需要一张牌"))
+	}
+	if tile34, err = util.StrToTile34(args[0]); err != nil {
+		return
+	}
+	return tile34, tile34 < 27 && args[0][0] == '0', nil
+}
+
+// 手牌中某种牌的写法，优先使用非赤5，如手牌为 505p 时，两张 5p 写作 55p，三张写作 055p
+func handTilesStr(pi *model.PlayerInfo, tile34 int, count int) string {
+	if pi.HandTiles34[tile34] < count {
+		return ""
+	}
+	humanTiles := util.Tile34ToStr(tile34)
+	if tile34 >= 27 || tile34%9 != 4 {
+		return strings.Repeat(humanTiles[:1], count) + humanTiles[1:]
+	}
+	numRed := count - (pi.HandTiles34[tile34] - handRedFives(pi)[tile34/9])
+	if numRed < 0 {
+		numRed = 0
+	}
+	return strings.Repeat("0", numRed) + strings.Repeat("5", count-numRed) + humanTiles[1:]
+}
+
+func interactDiscard(pi *model.PlayerInfo, args []string) error {
+	tile34, isRedFive, err := parseInteractTile(args)
 	if err != nil {
-		errorExit(err)
+		return err
+	}
+	if util.CountOfTiles34(pi.HandTiles34)%3 != 2 {
+		return errors.New(tr("现在不能切牌"))
 	}
-	printed := true
-	countOfTiles := util.CountOfTiles34(tiles34)
+	// 手上的 5 只有赤5时，切 5 也视作切赤5
+	isRedFive = isRedFive || isOnlyRedFiveInHand(pi, tile34)
+	if pi.HandTiles34[tile34] == 0 || isRedFive && handRedFives(pi)[tile34/9] == 0 {
+		return errors.New(tr("切掉的牌不存在"))
+	}
+	pi.DiscardTile(tile34, isRedFive)
+	return nil
+}
 
-	var tile string
-	for {
-		for {
-			if countOfTiles < 14 {
-				countOfTiles = 999
-				break
-			}
-			printed = false
-			fmt.Print("> 切 ")
-			fmt.Scanf("%s\n", &tile)
-			tile34, err := util.StrToTile34(tile)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
-			} else {
-				if tiles34[tile34] == 0 {
-					fmt.Fprintln(os.Stderr, "切掉的牌不存在")
-				} else {
-					tiles34[tile34]--
-					break
-				}
-			}
+func interactDraw(pi *model.PlayerInfo, args []string) error {
+	tile34, isRedFive, err := parseInteractTile(args)
+	if err != nil {
+		return err
+	}
+	if util.CountOfTiles34(pi.HandTiles34)%3 != 1 {
+		return errors.New(tr("现在不能摸牌"))
+	}
+	if pi.LeftTiles34[tile34] == 0 {
+		return errors.New(tr("不可能摸更多的牌了"))
+	}
+	pi.HandTiles34[tile34]++
+	pi.LeftTiles34[tile34]--
+	if isRedFive {
+		pi.NumRedFives[tile34/9]++
+	}
+	if pi.Turn > 0 {
+		pi.Turn++
+	}
+	return nil
+}
+
+// 用手牌中的 humanSelfTiles 鸣他家打出的 humanCalledTile
+func interactCall(pi *model.PlayerInfo, meldType int, humanCalledTile string, humanSelfTiles string) error {
+	if util.CountOfTiles34(pi.HandTiles34)%3 != 1 {
+		return errors.New(tr("现在不能鸣牌"))
+	}
+	if pi.IsRiichi {
+		return errors.New(tr("立直后不能鸣牌"))
+	}
+	if humanSelfTiles == "" {
+		return errors.New(tr("手牌中没有足够的牌"))
+	}
+	meld, err := parseMeldRecord(&meldRecord{Type: meldRecordTypes[meldType], Tiles: humanCalledTile + " " + humanSelfTiles, CalledTile: humanCalledTile})
+	if err != nil {
+		return err
+	}
+	meld.RedFiveFromOthers = humanCalledTile[0] == '0'
+
+	selfTiles34 := util.MustStrToTiles34(humanSelfTiles)
+	for tile, c := range selfTiles34 {
+		if pi.HandTiles34[tile] < c {
+			return errors.New(tr("手牌中没有足够的牌"))
+		}
+	}
+	if meld.ContainRedFive {
+		if numRed := util.CountRedFives(humanSelfTiles); numRed[meld.Tiles[0]/9] > handRedFives(pi)[meld.Tiles[0]/9] {
+			return errors.New(tr("手牌中没有足够的牌"))
 		}
+	}
+	if pi.LeftTiles34[meld.CalledTile] == 0 {
+		return fmt.Errorf(tr("参数错误: %s 超过了 4 张"), humanCalledTile)
+	}
+
+	pi.LeftTiles34[meld.CalledTile]--
+	pi.AddMeld(meld)
+	return nil
+}
+
+func interactChi(pi *model.PlayerInfo, args []string) error {
+	if len(args) != 2 {
+		return errors.New(tr("参数错误: 需要被吃的牌和手牌中的两张牌，如 chi 3m 45m"))
+	}
+	return interactCall(pi, meldTypeChi, args[0], args[1])
+}
+
+func interactPon(pi *model.PlayerInfo, args []string) error {
+	tile34, _, err := parseInteractTile(args)
+	if err != nil {
+		return err
+	}
+	return interactCall(pi, meldTypePon, args[0], handTilesStr(pi, tile34, 2))
+}
+
+func interactKan(pi *model.PlayerInfo, args []string) error {
+	tile34, isRedFive, err := parseInteractTile(args)
+	if err != nil {
+		return err
+	}
+
+	// 他家打出的牌：大明杠
+	if util.CountOfTiles34(pi.HandTiles34)%3 == 1 {
+		return interactCall(pi, meldTypeMinkan, args[0], handTilesStr(pi, tile34, 3))
+	}
 
-		if !printed {
-			raw = util.Tiles34ToStr(tiles34)
-			if _, err := analysisHumanTiles(model.NewSimpleHumanTilesInfo(raw)); err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
+	// 自家摸牌后：暗杠或加杠
+	if pi.HandTiles34[tile34] == 4 {
+		meld, err := parseMeldRecord(&meldRecord{Type: meldRecordTypes[meldTypeAnkan], Tiles: handTilesStr(pi, tile34, 4)})
+		if err != nil {
+			return err
+		}
+		meld.SelfTiles = meld.Tiles
+		pi.AddMeld(meld)
+		return nil
+	}
+	for i, meld := range pi.Melds {
+		if meld.MeldType == meldTypePon && meld.Tiles[0] == tile34 {
+			isRedFive = isRedFive || isOnlyRedFiveInHand(pi, tile34)
+			if pi.HandTiles34[tile34] == 0 || isRedFive && handRedFives(pi)[tile34/9] == 0 {
+				return errors.New(tr("手牌中没有足够的牌"))
 			}
+			pi.HandTiles34[tile34]--
+			meld.MeldType = meldTypeKakan
+			meld.Tiles = append(append([]int(nil), meld.Tiles...), tile34)
+			meld.ContainRedFive = meld.ContainRedFive || isRedFive
+			pi.Melds[i] = meld
+			return nil
+		}
+	}
+	return errors.New(tr("手牌中没有足够的牌"))
+}
 
-			printed = true
+func interactRiichi(pi *model.PlayerInfo, args []string) error {
+	if pi.IsRiichi {
+		return errors.New(tr("已经立直了"))
+	}
+	if pi.IsNaki() {
+		return errors.New(tr("参数错误: 副露后不能立直"))
+	}
+	if len(args) > 0 {
+		if err := interactDiscard(pi, args); err != nil {
+			return err
 		}
+	}
+	pi.IsRiichi = true
+	return nil
+}
 
-		for {
-			printed = false
+func interactDora(pi *model.PlayerInfo, args []string) error {
+	doraIndicators, err := util.StrToTiles(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	if err := interactSeen(pi, args); err != nil {
+		return err
+	}
+	pi.DoraTiles = append(append([]int(nil), pi.DoraTiles...), model.DoraList(doraIndicators)...)
+	return nil
+}
 
-			fmt.Print("> 摸 ")
-			fmt.Scanf("%s\n", &tile)
-			tile34, err := util.StrToTile34(tile)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
-			} else {
-				if tiles34[tile34] == 4 {
-					fmt.Fprintln(os.Stderr, "不可能摸更多的牌了")
-				} else {
-					tiles34[tile34]++
-					break
-				}
-			}
+func interactSeen(pi *model.PlayerInfo, args []string) error {
+	tiles, err := util.StrToTiles(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	seenTiles34 := make([]int, 34)
+	for _, tile := range tiles {
+		seenTiles34[tile]++
+	}
+	for tile, c := range seenTiles34 {
+		if pi.LeftTiles34[tile] < c {
+			return fmt.Errorf(tr("参数错误: %s 超过了 4 张"), util.Tile34ToStr(tile))
 		}
+	}
+	for tile, c := range seenTiles34 {
+		pi.LeftTiles34[tile] -= c
+	}
+	return nil
+}
 
-		if !printed {
-			raw = util.Tiles34ToStr(tiles34)
-			if _, err := analysisHumanTiles(model.NewSimpleHumanTilesInfo(raw)); err != nil {
-				fmt.Fprintln(os.Stderr, err.Error())
-			}
+func interactRoundWind(pi *model.PlayerInfo, args []string) error {
+	if len(args) != 1 {
+		return errors.New(tr("参数错误: 需要一张风牌"))
+	}
+	tile34, err := parseWindTile(args[0])
+	if err != nil {
+		return err
+	}
+	pi.RoundWindTile = tile34
+	return nil
+}
+
+func interactSelfWind(pi *model.PlayerInfo, args []string) error {
+	if len(args) != 1 {
+		return errors.New(tr("参数错误: 需要一张风牌"))
+	}
+	tile34, err := parseWindTile(args[0])
+	if err != nil {
+		return err
+	}
+	pi.SelfWindTile = tile34
+	pi.IsParent = tile34 == 27
+	return nil
+}
+
+//
+
+// 交互模式中的对局，可以保存为 JSON 文件
+type interactSession struct {
+	Init     *analysisRequest `json:"init"`     // 初始手牌和局况
+	Commands []string         `json:"commands"` // 已执行的改变局面的命令，如 discard 3m
+
+	playerInfo *model.PlayerInfo
+	undone     []string // 撤销的命令，用于重做，最后撤销的在最后
+}
+
+func newInteractSession(init *analysisRequest) (*interactSession, error) {
+	if init.TargetTile != "" {
+		return nil, errors.New(tr("交互模式中不能用 + 分析鸣牌"))
+	}
+	s := &interactSession{Init: init, Commands: []string{}}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func loadInteractSession(path string) (*interactSession, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &interactSession{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Init == nil {
+		return nil, fmt.Errorf(tr("对局文件 %s 中没有初始手牌"), path)
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *interactSession) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// 从初始局面重放所有命令
+func (s *interactSession) replay() error {
+	playerInfo, err := s.Init.playerInfo()
+	if err != nil {
+		return err
+	}
+	s.playerInfo = playerInfo
+	for _, line := range s.Commands {
+		if _, err := s.apply(line); err != nil {
+			return fmt.Errorf("%s: %v", line, err)
+		}
+	}
+	return nil
+}
+
+// 执行一条改变局面的命令，返回记录到对局文件中的写法
+func (s *interactSession) apply(line string) (string, error) {
+	fields := strings.Fields(line)
+	command := findInteractCommand(fields[0])
+	if command == nil {
+		if _, err := util.StrToTile34(fields[0]); err != nil || len(fields) != 1 {
+			return "", fmt.Errorf(tr("未知的命令 %s，输入 help 查看所有命令"), fields[0])
+		}
+		// 直接输入一张牌
+		command = findInteractCommand("draw")
+		if util.CountOfTiles34(s.playerInfo.HandTiles34)%3 == 2 {
+			command = findInteractCommand("discard")
+		}
+	} else {
+		fields = fields[1:]
+	}
+	if err := command.run(s.playerInfo, fields); err != nil {
+		return "", err
+	}
+	return strings.Join(append([]string{command.name}, fields...), " "), nil
+}
+
+// 执行并记录一条改变局面的命令
+// 执行失败时局面不变
+func (s *interactSession) exec(line string) error {
+	command, err := s.apply(line)
+	if err != nil {
+		// 命令可能只执行了一半，重放以复原局面
+		if er := s.replay(); er != nil {
+			return er
+		}
+		return err
+	}
+	s.Commands = append(s.Commands, command)
+	return nil
+}
+
+func (s *interactSession) undo() error {
+	if len(s.Commands) == 0 {
+		return errors.New(tr("没有可以撤销的命令"))
+	}
+	last := s.Commands[len(s.Commands)-1]
+	s.Commands = s.Commands[:len(s.Commands)-1]
+	s.undone = append(s.undone, last)
+	return s.replay()
+}
+
+func (s *interactSession) redo() error {
+	if len(s.undone) == 0 {
+		return errors.New(tr("没有可以重做的命令"))
+	}
+	last := s.undone[len(s.undone)-1]
+	if err := s.exec(last); err != nil {
+		return err
+	}
+	s.undone = s.undone[:len(s.undone)-1]
+	return nil
+}
+
+func (s *interactSession) printHistory() {
+	fmt.Println("  0. " + s.Init.Tiles)
+	for i, command := range s.Commands {
+		fmt.Printf("%3d. %s\n", i+1, command)
+	}
+	for i := len(s.undone) - 1; i >= 0; i-- {
+		fmt.Printf("     %s %s\n", s.undone[i], tr("（已撤销）"))
+	}
+}
+
+func (s *interactSession) printAnalysis() {
+	if _, err := analysisTiles34(copyPlayerInfo(s.playerInfo), nil); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
 
-			printed = true
+// 执行一行输入，返回是否退出
+func (s *interactSession) run(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "quit", "exit", "q":
+		return true, nil
+	case "help", "h", "?":
+		interactHelp()
+		return
+	case "history":
+		s.printHistory()
+		return
+	case "save":
+		if len(fields) != 2 {
+			return false, errors.New(tr("参数错误: 需要文件名"))
+		}
+		if err = s.save(fields[1]); err == nil {
+			fmt.Printf(tr("已保存至 %s")+"\n", fields[1])
+		}
+		return
+	case "load":
+		if len(fields) != 2 {
+			return false, errors.New(tr("参数错误: 需要文件名"))
+		}
+		loaded, er := loadInteractSession(fields[1])
+		if er != nil {
+			return false, er
+		}
+		*s = *loaded
+	case "undo", "u":
+		err = s.undo()
+	case "redo", "r":
+		err = s.redo()
+	default:
+		if err = s.exec(line); err == nil {
+			s.undone = nil
+		}
+	}
+	if err != nil {
+		return
+	}
+	s.printAnalysis()
+	return
+}
+
+func interact(humanTilesInfo *model.HumanTilesInfo, sessionFile string) {
+	var s *interactSession
+	var err error
+	if sessionFile != "" {
+		s, err = loadInteractSession(sessionFile)
+	} else {
+		var init *analysisRequest
+		if init, err = newAnalysisRequest(humanTilesInfo); err == nil {
+			s, err = newInteractSession(init)
+		}
+	}
+	if err != nil {
+		errorExit(err)
+	}
+
+	fmt.Println(tr("输入 help 查看所有命令"))
+	s.printAnalysis()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			return
+		}
+		quit, err := s.run(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		if quit {
+			return
 		}
 	}
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestInteractSession(t *testing.T, humanTiles string) *interactSession {
+	init, err := newAnalysisRequest(model.NewSimpleHumanTilesInfo(humanTiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newInteractSession(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func execInteractCommands(t *testing.T, s *interactSession, lines ...string) {
+	for _, line := range lines {
+		if err := s.exec(line); err != nil {
+			t.Fatal(line, err)
+		}
+	}
+}
+
+func TestInteractSession(t *testing.T) {
+	s := newTestInteractSession(t, "24m 55p 3478s 77z 5s")
+
+	// 直接输入一张牌，以及他家打出 7z 后碰
+	execInteractCommands(t, s, "5s", "摸 7z", "d 2m", "pon 7z", "切 4m")
+	if humanTilesOfPlayer(s.playerInfo) != "55p 3478s 7z & (777z)" {
+		t.Fatal("手牌有误", humanTilesOfPlayer(s.playerInfo))
+	}
+	if !reflect.DeepEqual(s.Commands, []string{"discard 5s", "draw 7z", "discard 2m", "pon 7z", "discard 4m"}) {
+		t.Fatal("记录的命令有误", s.Commands)
+	}
+	if s.playerInfo.LeftTiles34[33] != 0 || len(s.playerInfo.DiscardTiles) != 3 {
+		t.Fatal("牌山或舍牌有误", s.playerInfo.LeftTiles34, s.playerInfo.DiscardTiles)
+	}
+
+	// 执行失败时局面不变
+	for _, line := range []string{"切 1m", "chi 6s 79s", "riichi", "foo 1m", "摸 7z"} {
+		if err := s.exec(line); err == nil {
+			t.Fatal(line, "应当返回错误")
+		}
+	}
+	if humanTilesOfPlayer(s.playerInfo) != "55p 3478s 7z & (777z)" || len(s.Commands) != 5 {
+		t.Fatal("局面不应改变", humanTilesOfPlayer(s.playerInfo))
+	}
+
+	execInteractCommands(t, s, "吃 2s 34s", "d 7z", "dora 4p", "seen 5p", "round S", "wind S")
+	if humanTilesOfPlayer(s.playerInfo) != "55p 78s & (234s) (777z)" {
+		t.Fatal("手牌有误", humanTilesOfPlayer(s.playerInfo))
+	}
+	if !reflect.DeepEqual(s.playerInfo.DoraTiles, []int{13}) || s.playerInfo.LeftTiles34[13] != 1 || s.playerInfo.RoundWindTile != 28 || s.playerInfo.SelfWindTile != 28 || s.playerInfo.IsParent {
+		t.Fatal("局况有误", s.playerInfo)
+	}
+
+	// 撤销和重做
+	for i := 0; i < 4; i++ {
+		if err := s.undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if humanTilesOfPlayer(s.playerInfo) != "55p 78s & (234s) (777z)" || len(s.playerInfo.DoraTiles) != 0 || s.playerInfo.IsParent {
+		t.Fatal("撤销有误", s.playerInfo)
+	}
+	if err := s.redo(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.playerInfo.DoraTiles, []int{13}) || len(s.undone) != 3 {
+		t.Fatal("重做有误", s.playerInfo.DoraTiles, s.undone)
+	}
+
+	// 保存和读取
+	dir, err := ioutil.TempDir("", "interact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	if err := s.save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadInteractSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Commands, s.Commands) || !reflect.DeepEqual(loaded.playerInfo, s.playerInfo) {
+		t.Fatal("读取的对局有误", loaded.Commands)
+	}
+}
+
+func TestInteractKan(t *testing.T) {
+	s := newTestInteractSession(t, "1111m 055p 234s 666z 7z")
+
+	// 暗杠后摸岭上牌，然后大明杠
+	execInteractCommands(t, s, "kan 1m", "摸 7z", "切 7z", "杠 5p", "摸 6z")
+	if humanTilesOfPlayer(s.playerInfo) != "234s 66667z & (0555p) {1111m}" {
+		t.Fatal("手牌有误", humanTilesOfPlayer(s.playerInfo))
+	}
+	if s.playerInfo.IsNaki() != true || s.playerInfo.NumRedFives[1] != 1 || s.playerInfo.Melds[0].MeldType != meldTypeAnkan {
+		t.Fatal("副露有误", s.playerInfo.Melds)
+	}
+
+	// 碰之后加杠
+	s = newTestInteractSession(t, "1235m 55p 234s 666z 9p")
+	execInteractCommands(t, s, "pon 0p", "d 5m", "摸 5p", "kan 5p")
+	if humanTilesOfPlayer(s.playerInfo) != "123m 9p 234s 666z & [0555p]" {
+		t.Fatal("手牌有误", humanTilesOfPlayer(s.playerInfo))
+	}
+	if !s.playerInfo.Melds[0].RedFiveFromOthers || s.playerInfo.NumRedFives[1] != 1 || s.playerInfo.LeftTiles34[13] != 0 {
+		t.Fatal("加杠有误", s.playerInfo)
+	}
+
+	// 立直后不能鸣牌
+	s = newTestInteractSession(t, "1235m 55p 234s 666z 9p 5m")
+	execInteractCommands(t, s, "立直 5m")
+	if err := s.exec("pon 5p"); err == nil || !s.playerInfo.IsRiichi {
+		t.Fatal("立直后不能鸣牌")
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// 雀魂的 protobuf 定义（protobufjs 导出的 JSON 格式）
+// 根据该定义动态解码 protobuf 数据，不需要生成代码
+//
+//go:embed liqi.json
+var liqiJSON []byte
+
+type liqiField struct {
+	Rule string `json:"rule"` // repeated 或空
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+}
+
+type liqiMethod struct {
+	RequestType  string `json:"requestType"`
+	ResponseType string `json:"responseType"`
+}
+
+// 定义中的一个节点，可以是 message、enum、service 或命名空间
+type liqiNode struct {
+	Fields  map[string]*liqiField  `json:"fields"`
+	Values  map[string]int         `json:"values"`
+	Methods map[string]*liqiMethod `json:"methods"`
+	Nested  map[string]*liqiNode   `json:"nested"`
+}
+
+// 解析后的 message
+type liqiMessageType struct {
+	fullName string
+	fields   map[int]*liqiMessageField
+}
+
+type liqiMessageField struct {
+	name       string
+	typeName   string
+	isRepeated bool
+
+	// 字段为 message 时的类型，为 nil 表示标量或 enum
+	messageType *liqiMessageType
+	isEnum      bool
+}
+
+type liqiSchema struct {
+	// 完整名称（如 .lq.ActionNewRound）到 message 的映射
+	messages map[string]*liqiMessageType
+	// 完整名称（如 .lq.FastTest.authGame）到方法的映射，其中的类型为完整名称
+	methods map[string]*liqiMethod
+}
+
+func parseLiqiSchema(data []byte) (*liqiSchema, error) {
+	root := &liqiNode{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+
+	s := &liqiSchema{
+		messages: map[string]*liqiMessageType{},
+		methods:  map[string]*liqiMethod{},
+	}
+	enums := map[string]bool{}
+	nodes := map[string]*liqiNode{}
+	// 方法所在的作用域，待收集完所有类型后再解析其请求、响应类型
+	methodScopes := map[string]string{}
+
+	// 先收集所有类型的完整名称
+	var collect func(scope string, node *liqiNode)
+	collect = func(scope string, node *liqiNode) {
+		for name, child := range node.Nested {
+			fullName := scope + "." + name
+			switch {
+			case child.Fields != nil:
+				s.messages[fullName] = &liqiMessageType{fullName: fullName, fields: map[int]*liqiMessageField{}}
+				nodes[fullName] = child
+			case child.Values != nil:
+				enums[fullName] = true
+			case child.Methods != nil:
+				for methodName, method := range child.Methods {
+					s.methods[fullName+"."+methodName] = method
+					methodScopes[fullName+"."+methodName] = scope
+				}
+			}
+			collect(fullName, child)
+		}
+	}
+	collect("", root)
+
+	isKnown := func(name string) bool { return s.messages[name] != nil || enums[name] }
+	isMessage := func(name string) bool { return s.messages[name] != nil }
+
+	for name, method := range s.methods {
+		method.RequestType = resolveLiqiTypeName(methodScopes[name], method.RequestType, isMessage)
+		method.ResponseType = resolveLiqiTypeName(methodScopes[name], method.ResponseType, isMessage)
+	}
+
+	// 再解析各个 message 的字段类型
+	for fullName, node := range nodes {
+		messageType := s.messages[fullName]
+		for name, field := range node.Fields {
+			f := &liqiMessageField{
+				name:       name,
+				typeName:   field.Type,
+				isRepeated: field.Rule == "repeated",
+			}
+			if !isLiqiScalarType(field.Type) {
+				typeName := resolveLiqiTypeName(fullName, field.Type, isKnown)
+				if !isKnown(typeName) {
+					return nil, fmt.Errorf("%s.%s 的类型 %s 未定义", fullName, name, field.Type)
+				}
+				f.typeName = typeName
+				f.messageType = s.messages[typeName]
+				f.isEnum = enums[typeName]
+			}
+			messageType.fields[field.ID] = f
+		}
+	}
+	return s, nil
+}
+
+// 按照 protobuf 的规则，从内层作用域向外查找类型
+func resolveLiqiTypeName(scope string, typeName string, isKnown func(string) bool) string {
+	if strings.HasPrefix(typeName, ".") {
+		return typeName
+	}
+	for {
+		if fullName := scope + "." + typeName; isKnown(fullName) {
+			return fullName
+		}
+		if scope == "" {
+			return typeName
+		}
+		scope = scope[:strings.LastIndexByte(scope, '.')]
+	}
+}
+
+func isLiqiScalarType(typeName string) bool {
+	switch typeName {
+	case "double", "float",
+		"int32", "int64", "uint32", "uint64", "sint32", "sint64",
+		"fixed32", "fixed64", "sfixed32", "sfixed64",
+		"bool", "string", "bytes":
+		return true
+	}
+	return false
+}
+
+var (
+	_liqiSchema     *liqiSchema
+	_liqiSchemaOnce sync.Once
+)
+
+// 内置的雀魂 protobuf 定义，首次使用时解析
+func mustLiqiSchema() *liqiSchema {
+	_liqiSchemaOnce.Do(func() {
+		s, err := parseLiqiSchema(liqiJSON)
+		if err != nil {
+			panic(fmt.Sprintln("liqi.json 解析失败", err))
+		}
+		_liqiSchema = s
+	})
+	return _liqiSchema
+}
+
+// 将 protobuf 数据解码成 message，返回字段名到值的映射
+// 与 protobufjs 解码后 JSON.stringify 的结果一致：只包含数据中出现了的字段，64 位整数同样为数值
+// bytes 字段的值为 liqiBytes，序列化成 JSON 时为 base64 字符串
+func (s *liqiSchema) decode(typeName string, data []byte) (map[string]interface{}, error) {
+	messageType, ok := s.messages[typeName]
+	if !ok {
+		return nil, fmt.Errorf("未知的类型 %s", typeName)
+	}
+	return messageType.decode(data)
+}
+
+// 与 decode 相同，但补上未出现的标量字段的默认值
+// protobuf 不编码默认值，而雀魂消息的类型是根据字段是否存在来判断的，见 majsoulRoundData.translate
+func (s *liqiSchema) decodeWithDefaults(typeName string, data []byte) (map[string]interface{}, error) {
+	msg, err := s.decode(typeName, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range s.messages[typeName].fields {
+		if field.isRepeated || field.messageType != nil {
+			continue
+		}
+		if _, ok := msg[field.name]; !ok {
+			msg[field.name] = field.defaultValue()
+		}
+	}
+	return msg, nil
+}
+
+func (f *liqiMessageField) defaultValue() interface{} {
+	switch f.typeName {
+	case "string":
+		return ""
+	case "bool":
+		return false
+	case "bytes":
+		return liqiBytes{}
+	}
+	return 0
+}
+
+// bytes 字段的值，序列化成 JSON 时为 base64 字符串
+type liqiBytes []byte
+
+func (b liqiBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.StdEncoding.EncodeToString(b))
+}
+
+func (t *liqiMessageType) decode(data []byte) (msg map[string]interface{}, err error) {
+	msg = map[string]interface{}{}
+	r := &protoReader{data: data}
+	for !r.eof() {
+		var key uint64
+		if key, err = r.varint(); err != nil {
+			return
+		}
+		fieldID, wireType := int(key>>3), int(key&7)
+
+		field, ok := t.fields[fieldID]
+		if !ok {
+			// 未知字段（客户端更新后新增的字段等）直接跳过
+			if err = r.skip(wireType); err != nil {
+				return
+			}
+			continue
+		}
+
+		// packed repeated 标量
+		if field.isRepeated && wireType == protoWireBytes && field.messageType == nil && field.typeName != "string" && field.typeName != "bytes" {
+			var packed []byte
+			if packed, err = r.bytes(); err != nil {
+				return
+			}
+			pr := &protoReader{data: packed}
+			for !pr.eof() {
+				var value interface{}
+				if value, err = field.read(pr, protoPackedWireType(field.typeName)); err != nil {
+					return
+				}
+				msg[field.name] = append(liqiRepeated(msg[field.name]), value)
+			}
+			continue
+		}
+
+		var value interface{}
+		if value, err = field.read(r, wireType); err != nil {
+			return nil, fmt.Errorf("%s.%s 解码失败：%v", t.fullName, field.name, err)
+		}
+		if field.isRepeated {
+			msg[field.name] = append(liqiRepeated(msg[field.name]), value)
+		} else {
+			msg[field.name] = value
+		}
+	}
+	return
+}
+
+func liqiRepeated(values interface{}) []interface{} {
+	if values == nil {
+		return nil
+	}
+	return values.([]interface{})
+}
+
+// 读取一个字段值
+func (f *liqiMessageField) read(r *protoReader, wireType int) (interface{}, error) {
+	if f.messageType != nil {
+		if wireType != protoWireBytes {
+			return nil, fmt.Errorf("wire type %d 有误", wireType)
+		}
+		data, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return f.messageType.decode(data)
+	}
+
+	typeName := f.typeName
+	if f.isEnum {
+		typeName = "int32"
+	}
+	if expected := protoPackedWireType(typeName); wireType != expected {
+		return nil, fmt.Errorf("wire type %d 有误", wireType)
+	}
+
+	switch typeName {
+	case "string":
+		data, err := r.bytes()
+		return string(data), err
+	case "bytes":
+		data, err := r.bytes()
+		return liqiBytes(data), err
+	case "double":
+		v, err := r.fixed64()
+		return math.Float64frombits(v), err
+	case "float":
+		v, err := r.fixed32()
+		return float64(math.Float32frombits(v)), err
+	case "fixed64":
+		return r.fixed64()
+	case "sfixed64":
+		v, err := r.fixed64()
+		return int64(v), err
+	case "fixed32":
+		return r.fixed32()
+	case "sfixed32":
+		v, err := r.fixed32()
+		return int32(v), err
+	}
+
+	v, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	switch typeName {
+	case "bool":
+		return v != 0, nil
+	case "int32":
+		return int32(v), nil
+	case "uint32":
+		return uint32(v), nil
+	case "int64":
+		return int64(v), nil
+	case "sint32":
+		return int32(uint32(v)>>1) ^ -int32(v&1), nil
+	case "sint64":
+		return int64(v>>1) ^ -int64(v&1), nil
+	default: // uint64
+		return v, nil
+	}
+}
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+// 标量类型对应的 wire type
+func protoPackedWireType(typeName string) int {
+	switch typeName {
+	case "string", "bytes":
+		return protoWireBytes
+	case "double", "fixed64", "sfixed64":
+		return protoWireFixed64
+	case "float", "fixed32", "sfixed32":
+		return protoWireFixed32
+	}
+	return protoWireVarint
+}
+
+// protobuf 的 wire format 读取
+type protoReader struct {
+	data []byte
+	pos  int
+}
+
+var errProtoTruncated = fmt.Errorf("数据不完整")
+
+func (r *protoReader) eof() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *protoReader) varint() (v uint64, err error) {
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.eof() {
+			return 0, errProtoTruncated
+		}
+		b := r.data[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("varint 过长")
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errProtoTruncated
+	}
+	data := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return data, nil
+}
+
+func (r *protoReader) fixed32() (v uint32, err error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, errProtoTruncated
+	}
+	for i := 3; i >= 0; i-- {
+		v = v<<8 | uint32(r.data[r.pos+i])
+	}
+	r.pos += 4
+	return
+}
+
+func (r *protoReader) fixed64() (v uint64, err error) {
+	if len(r.data)-r.pos < 8 {
+		return 0, errProtoTruncated
+	}
+	for i := 7; i >= 0; i-- {
+		v = v<<8 | uint64(r.data[r.pos+i])
+	}
+	r.pos += 8
+	return
+}
+
+func (r *protoReader) skip(wireType int) (err error) {
+	switch wireType {
+	case protoWireVarint:
+		_, err = r.varint()
+	case protoWireFixed64:
+		_, err = r.fixed64()
+	case protoWireBytes:
+		_, err = r.bytes()
+	case protoWireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("不支持的 wire type %d", wireType)
+	}
+	return
+}
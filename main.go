@@ -22,21 +22,22 @@ var (
 	showAgariAboveShanten1 bool
 	showScore              bool
 	showAllYakuTypes       bool
+	recordGame             bool
 )
 
 func welcome() int {
 	platforms := map[int]string{
-		0: "天凤",
-		1: "雀魂",
+		0: tr("天凤"),
+		1: tr("雀魂"),
 	}
 
-	fmt.Println("使用说明：https://github.com/EndlessCheng/mahjong-helper")
-	fmt.Println("问题反馈：https://github.com/EndlessCheng/mahjong-helper/issues")
-	fmt.Println("吐槽群：375865038")
+	fmt.Println(tr("使用说明：") + "https://github.com/EndlessCheng/mahjong-helper")
+	fmt.Println(tr("问题反馈：") + "https://github.com/EndlessCheng/mahjong-helper/issues")
+	fmt.Println(tr("吐槽群：") + "375865038")
 
 	fmt.Println()
 
-	fmt.Println("请输入数字，以选择对应的平台：")
+	fmt.Println(tr("请输入数字，以选择对应的平台："))
 	for k, v := range platforms {
 		fmt.Printf("%d - %s\n", k, v)
 	}
@@ -50,51 +51,117 @@ func welcome() int {
 	clearConsole()
 	platformName := platforms[choose]
 	if choose == 1 {
-		platformName += "（水晶杠杠版）"
+		platformName += tr("（水晶杠杠版）")
 	}
-	color.HiGreen("已选择 - %s", platformName)
+	color.HiGreen(tr("已选择 - %s"), platformName)
 	if choose == 1 {
-		color.HiYellow("提醒：若您已登录游戏，请刷新网页，或者开启一局人机对战\n" +
-			"该步骤用于获取您的账号 ID，便于在游戏开始时分析自风，否则程序将无法解析后续数据")
+		color.HiYellow(tr("提醒：若您已登录游戏，请刷新网页，或者开启一局人机对战\n" +
+			"该步骤用于获取您的账号 ID，便于在游戏开始时分析自风，否则程序将无法解析后续数据"))
 	}
 
 	return choose
 }
 
 func main() {
-	color.HiGreen("日本麻将助手 %s (by EndlessCheng)", version)
+	flags, restArgs := parseArgs(os.Args[1:])
+
+	isMjai := flags.Bool("mjai")
+	if isMjai {
+		// 标准输出留给 mjai 协议
+		redirectOutputForMjai()
+	}
+
+	// 界面语言：zh（默认）、en、ja
+	langName := flags.String("lang")
+	if langName == "" {
+		langName = gameConf.Lang
+	}
+	if err := setLang(langName); err != nil {
+		errorExit(err)
+	}
+
+	// 分析结果的输出格式：terminal（默认）、plain、json、html、tui（全屏界面）
+	renderName := flags.String("render")
+	if renderName == "" {
+		renderName = gameConf.Renderer
+	}
+	r, err := newRenderer(renderName, os.Stdout)
+	if err != nil {
+		errorExit(err)
+	}
+	outputRenderer = r
+
+	color.HiGreen(tr("日本麻将助手 %s (by EndlessCheng)"), version)
 	if version != "dev" {
 		go alertNewVersion(version)
 	}
 
-	flags, restArgs := parseArgs(os.Args[1:])
-
 	isMajsoul := flags.Bool("majsoul")
 	isTenhou := flags.Bool("tenhou")
 	isAnalysis := flags.Bool("analysis")
 	isInteractive := flags.Bool("i", "interactive")
+	isReplay := flags.Bool("replay")
+	isBatch := flags.Bool("batch")
 	showImproveDetail = flags.Bool("id", "detail")
 	showAgariAboveShanten1 = flags.Bool("a", "agari")
 	showScore = flags.Bool("s", "score")
 	showAllYakuTypes = flags.Bool("y", "yaku")
+	recordGame = flags.Bool("record")
 
-	humanDoraTiles := flags.String("d", "dora")
-	humanTiles := strings.Join(restArgs, " ")
+	// 重放天凤牌谱、雀魂牌谱，分析 tenhou.net/6 牌谱时以哪个座位为自家（0 为第一局的东家）
+	seat, err := flags.Int("seat")
+	if err != nil {
+		errorExit(err)
+	}
+
+	// 分析手牌时的局况，如 -round=S2 -wind=W -riichi -turn=9 -discards=19m5z -seen=5z -ind=3p
+	turn, err := flags.Int("turn")
+	if err != nil {
+		errorExit(err)
+	}
+	selfWind := flags.String("wind")
+	if flags.Bool("dealer") {
+		if selfWind != "" {
+			errorExit(tr("参数错误: -dealer 和 -wind 不能同时使用"))
+		}
+		selfWind = "1z"
+	}
+	humanTilesInfo := &model.HumanTilesInfo{
+		HumanTiles:          strings.Join(restArgs, " "),
+		HumanDoraTiles:      flags.String("d", "dora"),
+		HumanDoraIndicators: flags.String("ind", "indicators"),
+		HumanDiscardTiles:   flags.String("discards"),
+		HumanSeenTiles:      flags.String("seen"),
+		HumanRoundWind:      flags.String("round"),
+		HumanSelfWind:       selfWind,
+		IsRiichi:            flags.Bool("riichi"),
+		Turn:                turn,
+	}
 
 	switch {
+	case isReplay:
+		// 离线重放，默认重放 gamedata.log
+		replayFile := flags.String("replay")
+		if replayFile == "" {
+			replayFile = logFile
+		}
+		runReplay(replayFile, isBatch, seat)
+	case flags.Bool("tenhou6"):
+		// 分析 tenhou.net/6 牌谱中的局面，如 -tenhou6=log.json -seat=2 -round=E3 -turn=7
+		runTenhou6Analysis(flags.String("tenhou6"), seat, flags.String("round"), turn)
+	case isMjai:
+		// 通过标准输入输出对接 mjai 模拟器
+		runMjai()
 	case isMajsoul:
 		runServer(true)
 	case isTenhou || isAnalysis:
 		runServer(false)
 	case isInteractive:
 		// 交互模式
-		interact(humanTiles)
+		// 交互模式，可以用 -load 读取保存的对局
+		interact(humanTilesInfo, flags.String("load"))
 	case len(restArgs) > 0:
 		//t0 := time.Now()
-		humanTilesInfo := &model.HumanTilesInfo{
-			HumanTiles:     humanTiles,
-			HumanDoraTiles: humanDoraTiles,
-		}
 		if _, err := analysisHumanTiles(humanTilesInfo); err != nil {
 			fmt.Println(err)
 		}
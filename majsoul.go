@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"github.com/fatih/color"
 	"github.com/EndlessCheng/mahjong-helper/util"
@@ -19,17 +22,32 @@ type majsoulMessage struct {
 	IsGameStart *bool `json:"is_game_start"` // false=新游戏，true=重连
 	SeatList    []int `json:"seat_list"`
 	ReadyIDList []int `json:"ready_id_list"`
+	GameConfig  *struct {
+		Mode struct {
+			Mode int `json:"mode"` // 1=四人东 2=四人南 11=三人东 12=三人南
+		} `json:"mode"`
+	} `json:"game_config"`
 
 	// NotifyPlayerLoadGameReady
 	//ReadyIDList []int `json:"ready_id_list"`
 
+	// ResSyncGame || ResEnterGame
+	// 重连时 game_restore.actions 为断线前本局的所有操作，按顺序排列
+	// {"is_end":false,"step":3,"game_restore":{"snapshot":{...},"actions":[{"step":0,"name":"ActionNewRound","data":"CAAQAB..."},...],"game_state":1}}
+	GameRestore *struct {
+		Actions []*majsoulAction `json:"actions"`
+	} `json:"game_restore"`
+
 	// ActionNewRound
 	// {"chang":0,"ju":0,"ben":0,"tiles":["1m","3m","7m","3p","6p","7p","6s","1z","1z","2z","3z","4z","7z"],"dora":"6m","scores":[25000,25000,25000,25000],"liqibang":0,"al":false,"md5":"","left_tile_count":69}
-	MD5   string      `json:"md5"`
-	Chang *int        `json:"chang"`
-	Ju    *int        `json:"ju"`
-	Tiles interface{} `json:"tiles"` // 一般情况下为 []interface{}, interface{} 即 string，但是暗杠的情况下，该值为一个 string
-	Dora  string      `json:"dora"`
+	MD5      string      `json:"md5"`
+	Chang    *int        `json:"chang"`
+	Ju       *int        `json:"ju"`
+	Ben      int         `json:"ben"` // 本场数
+	Tiles    interface{} `json:"tiles"` // 一般情况下为 []interface{}, interface{} 即 string，但是暗杠的情况下，该值为一个 string
+	Dora     string      `json:"dora"`
+	Liqibang int         `json:"liqibang"` // 场上的立直棒数
+	// `json:"scores"` // 各家点数，按座位排列，见 RawScores
 
 	// ActionDealTile
 	// {"seat":1,"tile":"5m","left_tile_count":23,"operation":{"seat":1,"operation_list":[{"type":1}],"time_add":0,"time_fixed":60000},"zhenting":false}
@@ -48,7 +66,15 @@ type majsoulMessage struct {
 	IsLiqi    *bool     `json:"is_liqi"`
 	IsWliqi   *bool     `json:"is_wliqi"`
 	Moqie     *bool     `json:"moqie"`
-	Operation *struct{} `json:"operation"`
+	Operation *majsoulOperation `json:"operation"`
+
+	// 立直成功，附带在立直宣言牌之后的下一条消息中（ActionDealTile、ActionChiPengGang 等）
+	// {"seat":2,"score":24000,"liqibang":1}
+	Liqi *struct {
+		Seat     int `json:"seat"`
+		Score    int `json:"score"`
+		Liqibang int `json:"liqibang"`
+	} `json:"liqi"`
 
 	// ActionChiPengGang || ActionAnGangAddGang
 	// 他家吃 {"seat":0,"type":0,"tiles":["2s","3s","4s"],"froms":[0,0,3],"zhenting":false}
@@ -62,17 +88,104 @@ type majsoulMessage struct {
 	// ActionLiqi
 
 	// ActionHule
-	Hules []struct {
-		Seat          int  `json:"seat"`
-		Zimo          bool `json:"zimo"`
-		PointRong     int  `json:"point_rong"`
-		PointZimoQin  int  `json:"point_zimo_qin"`
-		PointZimoXian int  `json:"point_zimo_xian"`
-	} `json:"hules"`
+	Hules       []*majsoulHuleInfo `json:"hules"`
+	DeltaScores []int              `json:"delta_scores"` // 各家点数变化，按座位排列
 
 	// ActionLiuJu
+	// {"type":1,"seat":0,"tiles":["1m","9m","1p","9p","1s","9s","1z","2z","3z","4z","5z","6z","7z","5m"],"allplayertiles":[]}
+	// `json:"type"` // 1=九种九牌 2=四风连打 3=四杠散了 4=四家立直 5=三家和了
+	AllPlayerTiles []string `json:"allplayertiles"`
+
+	// ActionNoTile
+	// {"liujumanguan":false,"players":[{"tingpai":true,"hand":["1m","2m","3m"],"tings":[{"tile":"4m"}]},{"tingpai":false},{"tingpai":false},{"tingpai":false}],"scores":[{"seat":0,"old_scores":[25000,25000,25000,25000],"delta_scores":[3000,-1000,-1000,-1000]}],"gameend":false}
+	LiujuManguan *bool `json:"liujumanguan"`
+	Players      []struct {
+		Tingpai bool `json:"tingpai"`
+	} `json:"players"`
+	// scores 在 ActionNewRound 中为 []int，在 ActionNoTile 中为 []NoTileScoreInfo，故延迟解析
+	RawScores json.RawMessage `json:"scores"`
+
+	// ActionBaBei
+	// {"seat":1,"moqie":false,"doras":[],"operation":{...}}
+	// 与 ActionDiscardTile 相比没有 tile
+}
+
+// 自家可以进行的操作
+// {"seat":1,"operation_list":[{"type":2,"combination":["7p|8p"]},{"type":3,"combination":["6p|6p"]},{"type":9}],"time_add":0,"time_fixed":60000}
+type majsoulOperation struct {
+	OperationList []struct {
+		Type int `json:"type"`
+	} `json:"operation_list"`
+}
+
+const (
+	majsoulOperationTypeDiscard = iota + 1
+	majsoulOperationTypeChi
+	majsoulOperationTypePon
+	majsoulOperationTypeAnkan
+	majsoulOperationTypeMinkan
+	majsoulOperationTypeKakan
+	majsoulOperationTypeRiichi
+	majsoulOperationTypeTsumo
+	majsoulOperationTypeRon
+	majsoulOperationTypeKyuushuKyuuhai
+	majsoulOperationTypeKita
+)
+
+// 转换成 ActionOptions，只能舍牌或没有可以进行的操作时返回 nil
+func (o *majsoulOperation) actionOptions() *ActionOptions {
+	if o == nil {
+		return nil
+	}
+	options := &ActionOptions{}
+	for _, operation := range o.OperationList {
+		switch operation.Type {
+		case majsoulOperationTypeChi:
+			options.Chi = true
+		case majsoulOperationTypePon:
+			options.Pon = true
+		case majsoulOperationTypeMinkan:
+			options.Minkan = true
+		case majsoulOperationTypeRon:
+			options.Ron = true
+		case majsoulOperationTypeAnkan, majsoulOperationTypeKakan:
+			options.SelfKan = true
+		case majsoulOperationTypeRiichi:
+			options.Riichi = true
+		case majsoulOperationTypeTsumo:
+			options.Tsumo = true
+		case majsoulOperationTypeKyuushuKyuuhai:
+			options.KyuushuKyuuhai = true
+		case majsoulOperationTypeKita:
+			options.Kita = true
+		}
+	}
+	if *options == (ActionOptions{}) {
+		return nil
+	}
+	return options
+}
 
-	// ActionBabei
+// ActionHule 中的和牌信息
+// {"hand":["2m","3m","4m","6p","6p"],"ming":["shunzi(1s,2s,3s)","kezi(5z,5z,5z)"],"hu_tile":"6p","seat":1,"zimo":false,"qinjia":false,"liqi":false,"doras":["3m"],"li_doras":[],"yiman":false,"count":2,"fans":[{"name":"役牌 白","val":1},{"name":"宝牌","val":1}],"fu":30,"point_rong":2000}
+type majsoulHuleInfo struct {
+	Hand    []string `json:"hand"` // 不含和了牌
+	Ming    []string `json:"ming"`
+	HuTile  string   `json:"hu_tile"`
+	Seat    int      `json:"seat"`
+	Zimo    bool     `json:"zimo"`
+	Doras   []string `json:"doras"`
+	LiDoras []string `json:"li_doras"`
+	Yiman   bool     `json:"yiman"`
+	Count   int      `json:"count"` // 番数，役满时为役满倍数
+	Fans    []struct {
+		Name string `json:"name"`
+		Val  int    `json:"val"`
+	} `json:"fans"`
+	Fu            int `json:"fu"`
+	PointRong     int `json:"point_rong"`
+	PointZimoQin  int `json:"point_zimo_qin"`
+	PointZimoXian int `json:"point_zimo_xian"`
 }
 
 const (
@@ -82,6 +195,34 @@ const (
 	majsoulMeldTypeAnkan
 )
 
+// .lq.ActionPrototype
+// data 为 name 对应的操作（如 .lq.ActionDealTile）经 protobuf 编码后的 base64 字符串
+type majsoulAction struct {
+	Step int    `json:"step"`
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// 解码操作，转换成与注入脚本推送的操作相同的格式
+func (a *majsoulAction) message() (*majsoulMessage, error) {
+	if a.Name == "" {
+		return nil, errors.New(tr("操作没有名称"))
+	}
+	action, err := mustLiqiSchema().decodeWithDefaults(".lq."+a.Name, a.Data)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return nil, err
+	}
+	msg := &majsoulMessage{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 type majsoulRoundData struct {
 	*roundData
 
@@ -89,6 +230,8 @@ type majsoulRoundData struct {
 	accountID  int
 	seat       int // 初始座位：0-第一局的东家 1-第一局的南家 2-第一局的西家 3-第一局的北家
 	msg        *majsoulMessage
+
+	events eventQueue
 }
 
 func (d *majsoulRoundData) fatalParse(info string, msg string) {
@@ -117,8 +260,9 @@ func (d *majsoulRoundData) normalTiles(tiles interface{}) (majsoulTiles []string
 }
 
 func (d *majsoulRoundData) parseWho(seat int) int {
-	// 转换成 0=自家, 1=下家, 2=对家, 3=上家
-	who := (seat + d.dealer - d.roundNumber%4 + 4) % 4
+	// 转换成 0=自家, 1=下家, 2=对家, 3=上家（三人麻将为 0=自家, 1=下家, 2=上家）
+	playerNumber := len(d.players)
+	who := (seat + d.dealer - d.roundNumber%4 + playerNumber) % playerNumber
 	return who
 }
 
@@ -166,7 +310,7 @@ func (d *majsoulRoundData) CheckMessage() bool {
 		if d.accountID > 0 {
 			// 有 accountID 时，检查 accountID 是否正确
 			if !util.InInts(d.accountID, msg.SeatList) {
-				color.HiRed("尚未正确获取到玩家账号 ID，请您刷新网页，或开启一局人机对战（错误信息：您的账号 ID %d 不在对战列表 %v 中）", d.accountID, msg.SeatList)
+				color.HiRed(tr("尚未正确获取到玩家账号 ID，请您刷新网页，或开启一局人机对战（错误信息：您的账号 ID %d 不在对战列表 %v 中）"), d.accountID, msg.SeatList)
 				return false
 			}
 		} else {
@@ -190,43 +334,108 @@ func (d *majsoulRoundData) CheckMessage() bool {
 
 	// 当自家准备好时（msg.SeatList == nil），打印准备信息
 	if msg.SeatList == nil && msg.ReadyIDList != nil {
-		fmt.Printf("等待玩家准备 (%d/%d) %v\n", len(msg.ReadyIDList), 4, msg.ReadyIDList)
+		outputRenderer.renderMessage(trf("等待玩家准备 (%d/%d) %v", len(msg.ReadyIDList), 4, msg.ReadyIDList))
 	}
 
-	// 筛去重连的消息，目前的程序不考虑重连的情况
-	if msg.IsGameStart != nil && *msg.IsGameStart {
+	// 重连时，重放断线前本局的所有操作
+	if msg.GameRestore != nil && len(msg.GameRestore.Actions) > 0 {
+		d.restoreActions(msg.GameRestore.Actions)
 		return false
 	}
 
 	return true
 }
 
+// 逐条重放重连数据中的操作，恢复各家的牌河、副露、立直和宝牌等信息
+func (d *majsoulRoundData) restoreActions(actions []*majsoulAction) {
+	originMsg := d.msg
+	originSkipOutput := d.skipOutput
+	d.skipOutput = true
+	for _, action := range actions {
+		msg, err := action.message()
+		if err != nil {
+			fmt.Println(tr("重连数据解析错误："), action.Name, err)
+			continue
+		}
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			fmt.Println(tr("重连数据解析错误："), err)
+		}
+	}
+	d.skipOutput = originSkipOutput
+	d.msg = originMsg
+
+	// 离线重放时可能处于静默状态
+	if d.skipOutput {
+		return
+	}
+
+	if !debugMode {
+		clearConsole()
+	}
+	text := &styledText{}
+	text.print(tr("重连成功，已恢复本局数据")+"\n", color.FgHiGreen)
+	text.print(trf("%s%d局，自风为%s\n", tileName(d.roundWindTile), d.roundNumber%4+1, tileName(d.players[0].selfWindTile)))
+	d.printScores(text)
+	outputRenderer.renderText("round", text)
+	if _, err := d.printRestoredRound(); err != nil {
+		fmt.Println(tr("错误："), err)
+	}
+}
+
+func (d *majsoulRoundData) IsGameStart() bool {
+	// ResAuthGame
+	return d.msg.GameConfig != nil
+}
+
+func (d *majsoulRoundData) ParseGameStart() (gameLength int, playerNumber int) {
+	// mode: 1=四人东 2=四人南 11=三人东 12=三人南
+	mode := d.msg.GameConfig.Mode.Mode
+	gameLength = gameLengthHanchan
+	if mode%10 == 1 {
+		gameLength = gameLengthTonpuu
+	}
+	playerNumber = 4
+	if mode >= 10 {
+		playerNumber = 3
+	}
+	return
+}
+
+func (d *majsoulRoundData) IsSeat() bool {
+	// ResAuthGame
+	// 三人麻将的 seat_list 长度为 3
+	return len(d.msg.SeatList) == 4 || len(d.msg.SeatList) == 3
+}
+
+// 第一局的庄家
+func (d *majsoulRoundData) ParseSeat() (dealer int) {
+	seatList := d.msg.SeatList
+	// dealer: 0=自家, 1=下家, 2=对家, 3=上家
+	dealer = 1
+	for i := len(seatList) - 1; i >= 0; i-- {
+		if seatList[i] == d.accountID {
+			break
+		}
+		dealer++
+	}
+	return dealer % len(seatList)
+}
+
 func (d *majsoulRoundData) IsInit() bool {
-	msg := d.msg
-	// ResAuthGame || ActionNewRound
-	const playerNumber = 4
-	return len(msg.SeatList) == playerNumber || msg.MD5 != ""
+	// ActionNewRound
+	return d.msg.MD5 != ""
 }
 
 func (d *majsoulRoundData) ParseInit() (roundNumber int, dealer int, doraIndicator int, handTiles []int, numRedFives []int) {
 	msg := d.msg
-	const playerNumber = 4
-
-	if len(msg.SeatList) == playerNumber {
-		// dealer: 0=自家, 1=下家, 2=对家, 3=上家
-		dealer = 1
-		for i := len(msg.SeatList) - 1; i >= 0; i-- {
-			if msg.SeatList[i] == d.accountID {
-				break
-			}
-			dealer++
-		}
-		dealer %= playerNumber
-		return
-	}
-	dealer = -1
 
-	roundNumber = playerNumber*(*msg.Chang) + *msg.Ju
+	// 三人麻将同样按照每个场风 4 局来计算
+	roundNumber = 4*(*msg.Chang) + *msg.Ju
+	// 根据上一局的庄家推算本局的庄家
+	// 重连时 roundNumber 可能不连续，所以这里不能简单地 +1
+	playerNumber := len(d.players)
+	dealer = (d.dealer - d.roundNumber%4 + roundNumber%4 + playerNumber) % playerNumber
 	doraIndicator, _ = d.mustParseMajsoulTile(msg.Dora)
 	numRedFives = make([]int, 3)
 	majsoulTiles := d.normalTiles(msg.Tiles)
@@ -240,6 +449,27 @@ func (d *majsoulRoundData) ParseInit() (roundNumber int, dealer int, doraIndicat
 	return
 }
 
+func (d *majsoulRoundData) ParseInitScores() (honba int, riichiSticks int, scores []int) {
+	msg := d.msg
+	honba = msg.Ben
+	riichiSticks = msg.Liqibang
+
+	seatScores := []int{}
+	if len(msg.RawScores) > 0 {
+		if err := json.Unmarshal(msg.RawScores, &seatScores); err != nil {
+			panic(fmt.Sprintln("[ParseInitScores] 解析错误", err))
+		}
+	}
+	if len(seatScores) == 0 {
+		return
+	}
+	scores = make([]int, len(seatScores))
+	for seat, score := range seatScores {
+		scores[d.parseWho(seat)] = score
+	}
+	return
+}
+
 func (d *majsoulRoundData) IsSelfDraw() bool {
 	msg := d.msg
 
@@ -253,33 +483,26 @@ func (d *majsoulRoundData) IsSelfDraw() bool {
 	return who == 0
 }
 
-func (d *majsoulRoundData) ParseSelfDraw() (tile int, isRedFive bool, kanDoraIndicator int) {
-	msg := d.msg
-	tile, isRedFive = d.mustParseMajsoulTile(msg.Tile)
-	kanDoraIndicator = -1
-	if d.isNewDora(msg.Doras) {
-		kanDoraIndicator, _ = d.mustParseMajsoulTile(msg.Doras[len(msg.Doras)-1])
-	}
+func (d *majsoulRoundData) ParseSelfDraw() (tile int, isRedFive bool, options *ActionOptions) {
+	tile, isRedFive = d.mustParseMajsoulTile(d.msg.Tile)
+	options = d.msg.Operation.actionOptions()
 	return
 }
 
 func (d *majsoulRoundData) IsDiscard() bool {
 	msg := d.msg
 	// ActionDiscardTile
-	return msg.Moqie != nil
+	// ActionBaBei 同样带有 moqie，但是没有 tile
+	return msg.Moqie != nil && msg.Tile != ""
 }
 
-func (d *majsoulRoundData) ParseDiscard() (who int, discardTile int, isRedFive bool, isTsumogiri bool, isReach bool, canBeMeld bool, kanDoraIndicator int) {
+func (d *majsoulRoundData) ParseDiscard() (who int, discardTile int, isRedFive bool, isTsumogiri bool, isReach bool, options *ActionOptions) {
 	msg := d.msg
 	who = d.parseWho(*msg.Seat)
 	discardTile, isRedFive = d.mustParseMajsoulTile(msg.Tile)
 	isTsumogiri = *msg.Moqie
 	isReach = *msg.IsLiqi || *msg.IsWliqi
-	canBeMeld = msg.Operation != nil
-	kanDoraIndicator = -1
-	if d.isNewDora(msg.Doras) {
-		kanDoraIndicator, _ = d.mustParseMajsoulTile(msg.Doras[len(msg.Doras)-1])
-	}
+	options = msg.Operation.actionOptions()
 	return
 }
 
@@ -287,23 +510,18 @@ func (d *majsoulRoundData) IsOpen() bool {
 	msg := d.msg
 	// FIXME: 更好的判断？
 	// ActionChiPengGang || ActionAnGangAddGang
-	if msg.Tiles == nil {
+	if msg.Tiles == nil || d.isLiuJu() {
 		return false
 	}
 	majsoulTiles := d.normalTiles(msg.Tiles)
 	return len(majsoulTiles) <= 4
 }
 
-func (d *majsoulRoundData) ParseOpen() (who int, meld *model.Meld, kanDoraIndicator int) {
+func (d *majsoulRoundData) ParseOpen() (who int, meld *model.Meld) {
 	msg := d.msg
 
 	who = d.parseWho(*msg.Seat)
 
-	kanDoraIndicator = -1
-	if d.isNewDora(msg.Doras) { // 暗杠（有时会在玩家摸牌后才发送 doras，可能是因为需要考虑抢暗杠的情况）
-		kanDoraIndicator, _ = d.mustParseMajsoulTile(msg.Doras[len(msg.Doras)-1])
-	}
-
 	var meldType, calledTile int
 
 	majsoulTiles := d.normalTiles(msg.Tiles)
@@ -376,16 +594,21 @@ func (d *majsoulRoundData) ParseOpen() (who int, meld *model.Meld, kanDoraIndica
 	return
 }
 
-func (d *majsoulRoundData) IsReach() bool {
-	return false
+func (d *majsoulRoundData) IsKita() bool {
+	msg := d.msg
+	// ActionBaBei
+	return msg.Seat != nil && msg.Moqie != nil && msg.Tile == ""
 }
 
-func (d *majsoulRoundData) ParseReach() (who int) {
-	return 0
+func (d *majsoulRoundData) ParseKita() (who int) {
+	return d.parseWho(*d.msg.Seat)
 }
 
-func (d *majsoulRoundData) IsFuriten() bool {
-	return false
+func (d *majsoulRoundData) ParseReachSuccess() (who int) {
+	if d.msg.Liqi == nil {
+		return -1
+	}
+	return d.parseWho(d.msg.Liqi.Seat)
 }
 
 func (d *majsoulRoundData) IsRoundWin() bool {
@@ -394,30 +617,279 @@ func (d *majsoulRoundData) IsRoundWin() bool {
 	return msg.Hules != nil
 }
 
-func (d *majsoulRoundData) ParseRoundWin() (whos []int, points []int) {
-	msg := d.msg
+// 雀魂的役名与 util 中役种的对应，役牌和宝牌单独判断
+var majsoulYakuTypeMap = map[string]int{
+	"门前清自摸和": util.YakuTsumo,
+	"立直":     util.YakuRiichi,
+	"一发":     util.YakuIppatsu,
+	"枪杠":     util.YakuChankan,
+	"岭上开花":   util.YakuRinshan,
+	"海底摸月":   util.YakuHaitei,
+	"河底捞鱼":   util.YakuHoutei,
+	"平和":     util.YakuPinfu,
+	"断幺九":    util.YakuTanyao,
+	"一杯口":    util.YakuIipeikou,
+	"两立直":    util.YakuDaburii,
+	"七对子":    util.YakuChiitoi,
+	"混全带幺九":  util.YakuChanta,
+	"一气通贯":   util.YakuIttsuu,
+	"三色同顺":   util.YakuSanshokuDoujun,
+	"三色同刻":   util.YakuSanshokuDoukou,
+	"三杠子":    util.YakuSanKantsu,
+	"对对和":    util.YakuToitoi,
+	"三暗刻":    util.YakuSanAnkou,
+	"小三元":    util.YakuShousangen,
+	"混老头":    util.YakuHonroutou,
+	"二杯口":    util.YakuRyanpeikou,
+	"纯全带幺九":  util.YakuJunchan,
+	"混一色":    util.YakuHonitsu,
+	"清一色":    util.YakuChinitsu,
+}
+
+func (d *majsoulRoundData) majsoulYakuType(name string) int {
+	if yakuType, ok := majsoulYakuTypeMap[name]; ok {
+		return yakuType
+	}
+	// 役牌 白、自风 东、场风 南 等
+	if strings.HasPrefix(name, "役牌") || strings.Contains(name, "风") {
+		return util.YakuYakuhai
+	}
+	return winYakuTypeUnknown
+}
+
+// 解析和牌信息中的副露，如 shunzi(1s,2s,3s)
+// 雀魂没有提供鸣的是哪张牌，这里取第一张
+func (d *majsoulRoundData) parseHuleMing(ming string) (meld *model.Meld, numRedFives []int) {
+	splits := strings.SplitN(strings.TrimSuffix(ming, ")"), "(", 2)
+	if len(splits) != 2 {
+		panic(fmt.Sprintln("无法解析副露", ming))
+	}
+	meldType, ok := map[string]int{
+		"shunzi":   meldTypeChi,
+		"kezi":     meldTypePon,
+		"minggang": meldTypeMinkan,
+		"angang":   meldTypeAnkan,
+	}[splits[0]]
+	if !ok {
+		panic(fmt.Sprintln("未知的副露类型", ming))
+	}
+
+	numRedFives = make([]int, 3)
+	var tiles []int
+	for _, majsoulTile := range strings.Split(splits[1], ",") {
+		tile, isRedFive := d.mustParseMajsoulTile(majsoulTile)
+		if isRedFive {
+			numRedFives[tile/9]++
+		}
+		tiles = append(tiles, tile)
+	}
+	sort.Ints(tiles)
+	meld = &model.Meld{
+		MeldType:       meldType,
+		Tiles:          tiles,
+		CalledTile:     tiles[0],
+		ContainRedFive: numRedFives[0]+numRedFives[1]+numRedFives[2] > 0,
+	}
+	return
+}
+
+func (d *majsoulRoundData) parseHuleInfo(hule *majsoulHuleInfo) *WinResult {
+	who := d.parseWho(hule.Seat)
+	result := &WinResult{
+		Who:         who,
+		WinTile:     -1,
+		NumRedFives: make([]int, 3),
+		Fu:          hule.Fu,
+		Point:       hule.PointRong,
+	}
+	if hule.Zimo {
+		// 自摸时由其余各家支付，三人麻将少一家
+		payers := len(d.players) - 1
+		if who == d.dealer {
+			result.Point = payers * hule.PointZimoXian
+		} else {
+			result.Point = hule.PointZimoQin + (payers-1)*hule.PointZimoXian
+		}
+	}
 
-	for _, result := range msg.Hules {
-		who := d.parseWho(result.Seat)
-		whos = append(whos, d.parseWho(result.Seat))
-		point := result.PointRong
-		if result.Zimo {
-			if who == d.dealer {
-				point = 3 * result.PointZimoXian
+	addTile := func(majsoulTile string) {
+		tile, isRedFive := d.mustParseMajsoulTile(majsoulTile)
+		if isRedFive {
+			result.NumRedFives[tile/9]++
+		}
+		result.HandTiles[tile]++
+	}
+	if hule.HuTile != "" {
+		result.WinTile, _ = d.mustParseMajsoulTile(hule.HuTile)
+		if len(hule.Hand) > 0 {
+			result.HandTiles = make([]int, 34)
+			for _, majsoulTile := range hule.Hand {
+				addTile(majsoulTile)
+			}
+			addTile(hule.HuTile)
+		}
+	}
+	for _, ming := range hule.Ming {
+		meld, numRedFives := d.parseHuleMing(ming)
+		result.Melds = append(result.Melds, meld)
+		for i, num := range numRedFives {
+			result.NumRedFives[i] += num
+		}
+	}
+	result.DoraIndicators, _ = d.mustParseMajsoulTiles(hule.Doras)
+	result.UraDoraIndicators, _ = d.mustParseMajsoulTiles(hule.LiDoras)
+
+	isChankan := false
+	for _, fan := range hule.Fans {
+		switch {
+		case fan.Name == "宝牌":
+			result.Dora += fan.Val
+		case fan.Name == "红宝牌":
+			result.AkaDora += fan.Val
+		case fan.Name == "里宝牌":
+			result.UraDora += fan.Val
+		case strings.HasSuffix(fan.Name, "北宝牌"):
+			result.NukiDora += fan.Val
+		default:
+			if fan.Val == 0 {
+				continue
+			}
+			yaku := &WinYaku{YakuType: d.majsoulYakuType(fan.Name), Name: fan.Name}
+			if hule.Yiman {
+				yaku.YakuType = winYakuTypeUnknown
+				yaku.Yakuman = fan.Val
 			} else {
-				point = result.PointZimoQin + 2*result.PointZimoXian
+				yaku.Han = fan.Val
+			}
+			if yaku.YakuType == util.YakuChankan {
+				isChankan = true
 			}
+			result.Yakus = append(result.Yakus, yaku)
+		}
+	}
+	if hule.Yiman {
+		result.YakumanTimes = hule.Count
+	} else {
+		result.Han = hule.Count
+	}
+
+	switch {
+	case hule.Zimo:
+		result.FromWho = who
+	case isChankan:
+		result.FromWho = d.chankanWho(who, result.WinTile)
+	default:
+		result.FromWho = d.latestDiscardWho()
+	}
+	return result
+}
+
+func (d *majsoulRoundData) ParseRoundWin() (results []*WinResult, deltaPoints []int) {
+	msg := d.msg
+
+	if len(msg.DeltaScores) > 0 {
+		deltaPoints = make([]int, len(msg.DeltaScores))
+		for seat, delta := range msg.DeltaScores {
+			deltaPoints[d.parseWho(seat)] = delta
 		}
-		points = append(points, point)
+	}
+
+	for _, hule := range msg.Hules {
+		results = append(results, d.parseHuleInfo(hule))
 	}
 	return
 }
 
-func (d *majsoulRoundData) IsNewDora() bool {
+const (
+	majsoulLiuJuTypeKyuushuKyuuhai = iota + 1
+	majsoulLiuJuTypeSuufonRenda
+	majsoulLiuJuTypeSuukanSanra
+	majsoulLiuJuTypeSuuchaRiichi
+	majsoulLiuJuTypeSanchahou
+)
+
+var majsoulLiuJuTypeMap = map[int]int{
+	majsoulLiuJuTypeKyuushuKyuuhai: ryuukyokuTypeKyuushuKyuuhai,
+	majsoulLiuJuTypeSuufonRenda:    ryuukyokuTypeSuufonRenda,
+	majsoulLiuJuTypeSuukanSanra:    ryuukyokuTypeSuukanSanra,
+	majsoulLiuJuTypeSuuchaRiichi:   ryuukyokuTypeSuuchaRiichi,
+	majsoulLiuJuTypeSanchahou:      ryuukyokuTypeSanchahou,
+}
+
+// ActionLiuJu
+func (d *majsoulRoundData) isLiuJu() bool {
 	msg := d.msg
-	// 在最后处理该项
-	// ActionDealTile
-	return d.isNewDora(msg.Doras)
+	if msg.AllPlayerTiles != nil {
+		return true
+	}
+	if _, ok := majsoulLiuJuTypeMap[msg.Type]; !ok {
+		return false
+	}
+	// 排除 ActionChiPengGang、ActionAnGangAddGang 等同样带有 type 的消息
+	if msg.Froms != nil || msg.Moqie != nil || msg.Hules != nil || msg.Chang != nil {
+		return false
+	}
+	if _, isSelfKan := msg.Tiles.(string); isSelfKan {
+		return false
+	}
+	if msg.Tiles == nil {
+		return true
+	}
+	// 九种九牌时为手牌，其余情况为空
+	tileCount := len(d.normalTiles(msg.Tiles))
+	return tileCount == 0 || tileCount > 4
+}
+
+// ActionNoTile
+func (d *majsoulRoundData) isNoTile() bool {
+	return d.msg.LiujuManguan != nil || d.msg.Players != nil
+}
+
+func (d *majsoulRoundData) IsRyuukyoku() bool {
+	return d.isLiuJu() || d.isNoTile()
+}
+
+func (d *majsoulRoundData) ParseRyuukyoku() (ryuukyokuType int, tenpaiWhos []int, deltaPoints []int) {
+	msg := d.msg
+
+	if !d.isNoTile() {
+		return majsoulLiuJuTypeMap[msg.Type], nil, nil
+	}
+
+	ryuukyokuType = ryuukyokuTypeExhaustive
+	if msg.LiujuManguan != nil && *msg.LiujuManguan {
+		ryuukyokuType = ryuukyokuTypeNagashiMangan
+	}
+
+	for seat, player := range msg.Players {
+		if player.Tingpai {
+			tenpaiWhos = append(tenpaiWhos, d.parseWho(seat))
+		}
+	}
+	sort.Ints(tenpaiWhos)
+
+	noTileScores := []struct {
+		Seat        int   `json:"seat"`
+		DeltaScores []int `json:"delta_scores"`
+	}{}
+	if len(msg.RawScores) > 0 {
+		if err := json.Unmarshal(msg.RawScores, &noTileScores); err != nil {
+			panic(fmt.Sprintln("[ParseRyuukyoku] 解析错误", err))
+		}
+	}
+	deltaPoints = make([]int, len(d.players))
+	for _, score := range noTileScores {
+		for seat, delta := range score.DeltaScores {
+			deltaPoints[d.parseWho(seat)] += delta
+		}
+	}
+	return
+}
+
+func (d *majsoulRoundData) IsNewDora() bool {
+	// ActionDealTile 等
+	// 暗杠的杠宝牌有时会在玩家摸牌后才发送，可能是因为需要考虑抢暗杠的情况
+	return d.isNewDora(d.msg.Doras)
 }
 
 func (d *majsoulRoundData) ParseNewDora() (kanDoraIndicator int) {
@@ -426,3 +898,79 @@ func (d *majsoulRoundData) ParseNewDora() (kanDoraIndicator int) {
 	kanDoraIndicator, _ = d.mustParseMajsoulTile(msg.Doras[len(msg.Doras)-1])
 	return
 }
+
+//
+
+func (d *majsoulRoundData) Next() (Event, error) {
+	return d.events.next(d.msg, d.translate)
+}
+
+// 将雀魂消息翻译成事件
+// 雀魂的一条消息可能对应多个事件：
+// 游戏开始时附带座位信息；立直成功附带在立直宣言牌之后的下一条消息中；杠宝牌附带在摸牌、舍牌或鸣牌中
+func (d *majsoulRoundData) translate() (events []Event, err error) {
+	if d.IsGameStart() {
+		gameLength, playerNumber := d.ParseGameStart()
+		events = append(events, &GameStartEvent{GameLength: gameLength, PlayerNumber: playerNumber})
+	}
+
+	if who := d.ParseReachSuccess(); who != -1 {
+		events = append(events, &RiichiEvent{Who: who, IsAccepted: true})
+	}
+
+	// 杠宝牌先于摸牌、舍牌和鸣牌处理，以便分析时计入新的宝牌
+	appendNewDora := func() {
+		if d.IsNewDora() {
+			events = append(events, &DoraEvent{Indicator: d.ParseNewDora()})
+		}
+	}
+
+	switch {
+	case d.IsSeat():
+		events = append(events, &SeatEvent{Dealer: d.ParseSeat()})
+	case d.IsInit():
+		roundNumber, dealer, doraIndicator, handTiles, numRedFives := d.ParseInit()
+		honba, riichiSticks, scores := d.ParseInitScores()
+		events = append(events, &InitEvent{
+			RoundNumber:    roundNumber,
+			Dealer:         dealer,
+			DoraIndicators: []int{doraIndicator},
+			HandTiles:      handTiles,
+			NumRedFives:    numRedFives,
+			Honba:          honba,
+			RiichiSticks:   riichiSticks,
+			Scores:         scores,
+		})
+	case d.IsOpen():
+		appendNewDora()
+		who, meld := d.ParseOpen()
+		events = append(events, &CallEvent{Who: who, Meld: meld})
+	case d.IsKita():
+		events = append(events, &KitaEvent{Who: d.ParseKita()})
+	case d.IsSelfDraw():
+		appendNewDora()
+		tile, isRedFive, options := d.ParseSelfDraw()
+		events = append(events, &DrawEvent{Tile: tile, IsRedFive: isRedFive, Options: options})
+	case d.IsDiscard():
+		appendNewDora()
+		who, discardTile, isRedFive, isTsumogiri, isReach, options := d.ParseDiscard()
+		events = append(events, &DiscardEvent{
+			Who:         who,
+			Tile:        discardTile,
+			IsRedFive:   isRedFive,
+			IsTsumogiri: isTsumogiri,
+			IsReach:     isReach,
+			Options:     options,
+		})
+	case d.IsRoundWin():
+		results, deltaPoints := d.ParseRoundWin()
+		events = append(events, newWinEvent(results, deltaPoints))
+	case d.IsRyuukyoku():
+		ryuukyokuType, tenpaiWhos, deltaPoints := d.ParseRyuukyoku()
+		events = append(events, &DrawGameEvent{Type: ryuukyokuType, TenpaiWhos: tenpaiWhos, DeltaPoints: deltaPoints})
+	default:
+		// 他家摸牌等
+		appendNewDora()
+	}
+	return
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"strings"
+)
+
+// 雀魂牌谱导入
+// 牌谱为 .lq.GameDetailRecords，其中每条记录为一个 .lq.Wrapper，如 .lq.RecordNewRound、.lq.RecordDealTile 等
+// 记录的字段与对局中的 Action 基本一致，区别在于：
+// - RecordNewRound 中有四家的手牌 tiles0-tiles3
+// - RecordDealTile 中有各家摸到的牌
+// - 能否鸣牌为各家的 operations 列表
+// 这里以某个座位的视角，将记录转换成与注入脚本相同的 JSON 消息，交给 majsoulRoundData 解析
+// 同时记录各家的手牌，以便在复盘时查看对手的手牌
+//
+// 支持两种文件：
+// - 下载的牌谱数据，即 .lq.Wrapper{name: .lq.GameDetailRecords}
+// - fetchGameRecord 的响应 .lq.ResGameRecord，其中还有对局信息（各家账号、对局模式）
+
+const majsoulGameDetailRecords = ".lq.GameDetailRecords"
+
+// 牌谱中的一条记录
+type majsoulPaipuRecord struct {
+	name string // 不含 .lq. 前缀，如 RecordNewRound
+	data map[string]interface{}
+}
+
+type majsoulPaipu struct {
+	// 按座位排列的账号 ID，没有对局信息时为 nil
+	accountIDs []int
+	// 对局模式，见 majsoulMessage.GameConfig，没有对局信息时为 0
+	mode int
+
+	records []*majsoulPaipuRecord
+}
+
+// 解析雀魂牌谱，不是雀魂牌谱时 isPaipu 为 false
+func parseMajsoulPaipu(data []byte) (paipu *majsoulPaipu, isPaipu bool, err error) {
+	s := mustLiqiSchema()
+	dec := &majsoulFrameDecoder{schema: s}
+
+	var head map[string]interface{}
+	name, recordsData, err := dec.decodeWrapper(data)
+	if err != nil || name != majsoulGameDetailRecords {
+		// fetchGameRecord 的响应
+		res, _err := s.decode(".lq.ResGameRecord", data)
+		if _err != nil {
+			return nil, false, nil
+		}
+		wrapperData, _ := res["data"].(liqiBytes)
+		name, recordsData, err = dec.decodeWrapper(wrapperData)
+		if err != nil || name != majsoulGameDetailRecords {
+			return nil, false, nil
+		}
+		head, _ = res["head"].(map[string]interface{})
+	}
+	isPaipu = true
+
+	gameDetailRecords, err := s.decode(majsoulGameDetailRecords, recordsData)
+	if err != nil {
+		return
+	}
+	paipu = &majsoulPaipu{}
+	records, _ := gameDetailRecords["records"].([]interface{})
+	for i, record := range records {
+		name, recordData, _err := dec.decodeWrapper(record.(liqiBytes))
+		if _err == nil {
+			var msg map[string]interface{}
+			if msg, _err = s.decode(name, recordData); _err == nil {
+				paipu.records = append(paipu.records, &majsoulPaipuRecord{name: strings.TrimPrefix(name, ".lq."), data: msg})
+				continue
+			}
+		}
+		return nil, true, fmt.Errorf("第 %d 条记录解码失败：%v", i+1, _err)
+	}
+	if head != nil {
+		paipu.parseHead(head)
+	}
+	return
+}
+
+// 解析 .lq.RecordGame 中的各家账号和对局模式
+func (p *majsoulPaipu) parseHead(head map[string]interface{}) {
+	if config, ok := head["config"].(map[string]interface{}); ok {
+		if mode, ok := config["mode"].(map[string]interface{}); ok {
+			p.mode = liqiInt(mode["mode"])
+		}
+	}
+	accounts, _ := head["accounts"].([]interface{})
+	if len(accounts) == 0 {
+		return
+	}
+	p.accountIDs = make([]int, p.playerNumber())
+	for _, account := range accounts {
+		account := account.(map[string]interface{})
+		if seat := liqiInt(account["seat"]); seat < len(p.accountIDs) {
+			p.accountIDs[seat] = liqiInt(account["account_id"])
+		}
+	}
+}
+
+func (p *majsoulPaipu) playerNumber() int {
+	if p.mode >= 10 {
+		return 3
+	}
+	for _, record := range p.records {
+		if record.name == "RecordNewRound" {
+			if tiles, _ := record.data["tiles3"].([]interface{}); len(tiles) == 0 {
+				return 3
+			}
+			break
+		}
+	}
+	return 4
+}
+
+// 将解码后的整数字段转换成 int，字段不存在时为 0
+func liqiInt(value interface{}) int {
+	switch v := value.(type) {
+	case uint32:
+		return int(v)
+	case int32:
+		return int(v)
+	case uint64:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+func liqiStrings(value interface{}) (strs []string) {
+	values, _ := value.([]interface{})
+	for _, v := range values {
+		strs = append(strs, v.(string))
+	}
+	return
+}
+
+// 将牌谱转换成以 seat 为自家的雀魂消息
+type majsoulPaipuConverter struct {
+	paipu        *majsoulPaipu
+	seat         int
+	playerNumber int
+
+	// 各家的手牌（雀魂的牌，如 0m 为赤5万）
+	hands [][]string
+}
+
+// 去掉手牌中的一张牌，没有这张牌时去掉对应的赤5或普通的5
+func (c *majsoulPaipuConverter) removeTile(seat int, tile string) {
+	hand := c.hands[seat]
+	for _, t := range []string{tile, majsoulSwapRedFive(tile)} {
+		for i, handTile := range hand {
+			if handTile == t {
+				c.hands[seat] = append(hand[:i], hand[i+1:]...)
+				return
+			}
+		}
+	}
+	panic(fmt.Sprintf("座位 %d 的手牌 %v 中没有 %s", seat, hand, tile))
+}
+
+func majsoulSwapRedFive(tile string) string {
+	switch tile[0] {
+	case '0':
+		return "5" + tile[1:]
+	case '5':
+		return "0" + tile[1:]
+	}
+	return tile
+}
+
+// 手牌的文本表示，如 123m 55p 1z
+func majsoulHandToStr(hand []string) string {
+	tiles := make([]int, len(hand))
+	for i, tile := range hand {
+		tile34, err := util.StrToTile34(strings.Replace(tile, "0", "5", 1))
+		if err != nil {
+			panic(err)
+		}
+		tiles[i] = tile34
+	}
+	return util.TilesToStr(tiles)
+}
+
+// 复盘时显示的对手手牌
+func (c *majsoulPaipuConverter) opponentHands() string {
+	if c.hands == nil {
+		return ""
+	}
+	names := []string{"", "下家", "对家", "上家"}
+	if c.playerNumber == 3 {
+		names = []string{"", "下家", "上家"}
+	}
+	var lines []string
+	for who := 1; who < c.playerNumber; who++ {
+		seat := (c.seat + who) % c.playerNumber
+		lines = append(lines, fmt.Sprintf("%s手牌：%s", names[who], majsoulHandToStr(c.hands[seat])))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 游戏开始时的消息，同 ResAuthGame
+func (c *majsoulPaipuConverter) gameStartMessage() map[string]interface{} {
+	seatList := c.paipu.accountIDs
+	if seatList == nil {
+		// 没有对局信息时，用座位号代替账号 ID
+		seatList = make([]int, c.playerNumber)
+		for i := range seatList {
+			seatList[i] = i + 1
+		}
+	}
+	mode := c.paipu.mode
+	if mode == 0 {
+		// 没有对局信息时按半庄处理
+		mode = 2
+		if c.playerNumber == 3 {
+			mode = 12
+		}
+	}
+	return map[string]interface{}{
+		"is_game_start": false,
+		"seat_list":     seatList,
+		"game_config":   map[string]interface{}{"mode": map[string]interface{}{"mode": mode}},
+	}
+}
+
+// 转换一条记录，不需要的记录返回 nil
+func (c *majsoulPaipuConverter) convert(record *majsoulPaipuRecord) map[string]interface{} {
+	msg := map[string]interface{}{}
+	for key, value := range record.data {
+		msg[key] = value
+	}
+	seat := liqiInt(record.data["seat"])
+	tile, _ := record.data["tile"].(string)
+	if record.name == "RecordAnGangAddGang" {
+		tile, _ = record.data["tiles"].(string)
+	}
+
+	// 只保留自家能否鸣牌的信息
+	operationOf := func(key string) {
+		delete(msg, key)
+		operations, _ := record.data[key].([]interface{})
+		for _, operation := range operations {
+			if operation := operation.(map[string]interface{}); liqiInt(operation["seat"]) == c.seat {
+				msg["operation"] = operation
+			}
+		}
+	}
+	setDefault := func(key string, value interface{}) {
+		if _, ok := msg[key]; !ok {
+			msg[key] = value
+		}
+	}
+
+	switch record.name {
+	case "RecordNewRound":
+		c.hands = make([][]string, c.playerNumber)
+		for i := range c.hands {
+			c.hands[i] = liqiStrings(record.data[fmt.Sprintf("tiles%d", i)])
+		}
+		for i := 0; i < 4; i++ {
+			delete(msg, fmt.Sprintf("tiles%d", i))
+		}
+		delete(msg, "paishan")
+		delete(msg, "tingpai")
+		// 复制一份，之后摸牌、舍牌时会修改手牌
+		msg["tiles"] = append([]string{}, c.hands[c.seat]...)
+		setDefault("chang", 0)
+		setDefault("ju", 0)
+		if md5, _ := msg["md5"].(string); md5 == "" {
+			// md5 用于判断是否为新的一局，早期的牌谱中没有
+			msg["md5"] = "paipu"
+		}
+		return msg
+	case "RecordDealTile":
+		c.hands[seat] = append(c.hands[seat], tile)
+		if seat != c.seat {
+			// 看不到他家摸的牌
+			delete(msg, "tile")
+			delete(msg, "operation")
+		}
+	case "RecordDiscardTile":
+		c.removeTile(seat, tile)
+		setDefault("is_liqi", false)
+		setDefault("is_wliqi", false)
+		setDefault("moqie", false)
+		operationOf("operations")
+	case "RecordChiPengGang":
+		froms, _ := record.data["froms"].([]interface{})
+		for i, t := range liqiStrings(record.data["tiles"]) {
+			if liqiInt(froms[i]) == seat {
+				c.removeTile(seat, t)
+			}
+		}
+		delete(msg, "operation")
+	case "RecordAnGangAddGang":
+		n := 1
+		if liqiInt(record.data["type"]) == majsoulMeldTypeAnkan {
+			n = 4
+		}
+		for i := 0; i < n; i++ {
+			c.removeTile(seat, tile)
+		}
+		operationOf("operations")
+	case "RecordBaBei":
+		c.removeTile(seat, "4z")
+		setDefault("moqie", false)
+		operationOf("operations")
+	case "RecordHule", "RecordNoTile", "RecordLiuJu":
+		return msg
+	default:
+		return nil
+	}
+
+	// 以上均为某一家的操作，seat 为 0 时可能被省略
+	msg["seat"] = seat
+	delete(msg, "zhenting")
+	return msg
+}
+
+// 读取雀魂牌谱，以 seat 的视角转换成重放用的雀魂消息
+// 消息的行号为其在牌谱中是第几条记录，第 0 行为账号和游戏开始信息
+func loadMajsoulPaipuMessages(paipu *majsoulPaipu, seat int) (messages []*replayMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("雀魂牌谱解析失败：%v", r)
+		}
+	}()
+
+	c := &majsoulPaipuConverter{
+		paipu:        paipu,
+		seat:         seat,
+		playerNumber: paipu.playerNumber(),
+	}
+	if seat >= c.playerNumber {
+		return nil, fmt.Errorf("座位 %d 超出了玩家人数", seat)
+	}
+
+	appendMessage := func(lo int, msg map[string]interface{}, note string) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, &replayMessage{lo: lo, data: data, note: note})
+		return nil
+	}
+
+	startMsg := c.gameStartMessage()
+	accountID := startMsg["seat_list"].([]int)[seat]
+	if err = appendMessage(0, map[string]interface{}{"account_id": accountID}, ""); err != nil {
+		return
+	}
+	if err = appendMessage(0, startMsg, ""); err != nil {
+		return
+	}
+
+	for i, record := range paipu.records {
+		msg := c.convert(record)
+		if msg == nil {
+			continue
+		}
+		if err = appendMessage(i+1, msg, c.opponentHands()); err != nil {
+			return
+		}
+	}
+	return
+}
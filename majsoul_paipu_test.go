@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func pbStrings(id int, strs ...string) (b []byte) {
+	for _, s := range strs {
+		b = append(b, pbString(id, s)...)
+	}
+	return
+}
+
+func majsoulTestRecord(name string, data []byte) []byte {
+	return pbBytes(1, pbConcat(pbString(1, ".lq."+name), pbBytes(2, data)))
+}
+
+func majsoulTestPaipu() []byte {
+	scores := pbBytes(5, pbConcat(pbVarint(25000), pbVarint(25000), pbVarint(25000), pbVarint(25000)))
+	records := pbConcat(
+		majsoulTestRecord("RecordNewRound", pbConcat(
+			pbUint(1, 0), pbUint(2, 0), pbUint(3, 0), pbString(4, "6m"), scores, pbUint(6, 0),
+			pbStrings(7, "1m", "2m", "3m", "4m", "5m", "6m", "7m", "8m", "9m", "1p", "2p", "3p", "4p", "1z"),
+			pbStrings(8, "1s", "1s", "2s", "3s", "4s", "5s", "6s", "7s", "8s", "9s", "2p", "2p", "3p"),
+			pbStrings(9, "1z", "1z", "2z", "2z", "3z", "3z", "4m", "4m", "5p", "5p", "6p", "6p", "7p"),
+			pbStrings(10, "9p", "9p", "9p", "8s", "8s", "7m", "7m", "6s", "6s", "3m", "3m", "4z", "4z"),
+			pbString(13, "md5"), pbUint(15, 69),
+		)),
+		// 东家切 1z，西家可以碰
+		majsoulTestRecord("RecordDiscardTile", pbConcat(
+			pbUint(1, 0), pbString(2, "1z"), pbUint(3, 0), pbUint(5, 0), pbUint(9, 0),
+			pbBytes(10, pbConcat(pbUint(1, 2), pbBytes(2, pbUint(1, 3)))),
+		)),
+		majsoulTestRecord("RecordChiPengGang", pbConcat(
+			pbUint(1, 2), pbUint(2, 1), pbStrings(3, "1z", "1z", "1z"), pbBytes(4, pbConcat(pbVarint(2), pbVarint(2), pbVarint(0))),
+		)),
+		majsoulTestRecord("RecordDiscardTile", pbConcat(pbUint(1, 2), pbString(2, "7p"), pbUint(3, 0), pbUint(5, 0), pbUint(9, 0))),
+		majsoulTestRecord("RecordDealTile", pbConcat(pbUint(1, 3), pbString(2, "0p"), pbUint(3, 68))),
+		majsoulTestRecord("RecordDiscardTile", pbConcat(pbUint(1, 3), pbString(2, "0p"), pbUint(3, 0), pbUint(5, 1), pbUint(9, 0))),
+		// 座位 0 省略了 seat
+		majsoulTestRecord("RecordDealTile", pbConcat(pbString(2, "2z"), pbUint(3, 67))),
+		majsoulTestRecord("RecordDiscardTile", pbConcat(pbString(2, "2z"), pbUint(5, 1))),
+		majsoulTestRecord("RecordNoTile", pbConcat(
+			pbUint(1, 0),
+			pbBytes(2, pbUint(3, 0)), pbBytes(2, pbUint(3, 0)), pbBytes(2, pbUint(3, 0)), pbBytes(2, pbUint(3, 0)),
+		)),
+	)
+	return pbConcat(pbString(1, majsoulGameDetailRecords), pbBytes(2, records))
+}
+
+func TestMajsoulPaipu(t *testing.T) {
+	debugMode = true
+
+	data := majsoulTestPaipu()
+	// fetchGameRecord 的响应：东风战，座位 2 的账号为 300
+	head := pbConcat(
+		pbBytes(5, pbBytes(2, pbUint(1, 1))),
+		pbBytes(11, pbConcat(pbUint(1, 300), pbUint(2, 2))),
+	)
+	resData := pbConcat(pbBytes(3, head), pbBytes(4, data))
+
+	paipu, isPaipu, err := parseMajsoulPaipu(resData)
+	if err != nil || !isPaipu {
+		t.Fatal("牌谱解析有误", err)
+	}
+	if paipu.mode != 1 || len(paipu.accountIDs) != 4 || paipu.accountIDs[2] != 300 || len(paipu.records) != 9 {
+		t.Fatal("对局信息解析有误", paipu.mode, paipu.accountIDs, len(paipu.records))
+	}
+	if _, isPaipu, _ := parseMajsoulPaipu([]byte(`{"tag":"GO"}`)); isPaipu {
+		t.Fatal("不是雀魂牌谱")
+	}
+
+	file, err := ioutil.TempFile("", "paipu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(data)
+	file.Close()
+
+	// 每个座位都能完整重放
+	for seat := 0; seat < 4; seat++ {
+		r, err := newReplayer(file.Name(), seat)
+		if err != nil {
+			t.Fatal(seat, err)
+		}
+		if r.dataSourceType != dataSourceTypeMajsoul {
+			t.Fatal("数据来源有误")
+		}
+		if errorCount := r.runAll(false); errorCount > 0 {
+			t.Fatal(seat, "重放出错", errorCount)
+		}
+		if len(r.rounds) != 1 {
+			t.Fatal(seat, "局数有误", r.rounds)
+		}
+
+		rd := r.roundData()
+		numHandTiles := 0
+		for _, c := range rd.counts {
+			numHandTiles += c
+		}
+		expectedHandTiles := 13
+		if seat == 2 {
+			expectedHandTiles = 10
+		}
+		if numHandTiles != expectedHandTiles {
+			t.Fatal(seat, "手牌数有误", numHandTiles)
+		}
+		// 西家碰了东家的 1z
+		if who := (2 - seat + 4) % 4; len(rd.players[who].melds) != 1 {
+			t.Fatal(seat, "西家的副露有误")
+		}
+	}
+
+	// 对手的手牌
+	messages, err := loadMajsoulPaipuMessages(paipu, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := messages[len(messages)-1].note
+	if !strings.Contains(note, "下家手牌：3377m 999p 6688s 44z") || !strings.Contains(note, "上家手牌：223p 1123456789s") {
+		t.Fatal("对手的手牌有误", note)
+	}
+}
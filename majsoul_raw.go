@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// 解码雀魂的原始 WebSocket 帧
+// 帧的第一个字节为类型：
+// 0x01 通知：其后为 .lq.Wrapper{name, data}
+// 0x02 请求：其后为 2 字节的请求序号（小端序），然后是 .lq.Wrapper，name 为方法名，如 .lq.FastTest.authGame
+// 0x03 响应：其后为对应请求的序号，然后是 .lq.Wrapper，name 为空，需要根据请求的方法确定响应的类型
+// 对局中的操作以通知的形式下发，其 Wrapper 为 .lq.ActionPrototype{step, name, data}，data 为 name 对应的操作
+// 解码后转换成与注入脚本相同的 JSON 消息，交给 majsoulRoundData 解析
+// 这样即使注入脚本失效，也可以直接使用抓包得到的数据
+
+const (
+	majsoulFrameNotify   = 0x01
+	majsoulFrameRequest  = 0x02
+	majsoulFrameResponse = 0x03
+)
+
+const majsoulActionPrototype = ".lq.ActionPrototype"
+
+// 需要解析的通知，其余通知（聊天、连接状态等）忽略
+var majsoulRawNotifies = map[string]bool{
+	majsoulActionPrototype:          true,
+	".lq.NotifyPlayerLoadGameReady": true,
+}
+
+// 需要解析的响应
+var majsoulRawResponses = map[string]bool{
+	".lq.Lobby.login":           true,
+	".lq.Lobby.oauth2Login":     true,
+	".lq.Lobby.fetchFriendList": true,
+	".lq.FastTest.authGame":     true,
+	".lq.FastTest.enterGame":    true,
+	".lq.FastTest.syncGame":     true,
+}
+
+type majsoulFrameDecoder struct {
+	mu     sync.Mutex
+	schema *liqiSchema
+
+	// 请求序号到方法名的映射，收到响应后删除
+	pendingMethods map[int]string
+}
+
+func newMajsoulFrameDecoder() *majsoulFrameDecoder {
+	return &majsoulFrameDecoder{
+		schema:         mustLiqiSchema(),
+		pendingMethods: map[int]string{},
+	}
+}
+
+// 解码一个帧，返回需要交给 majsoulRoundData 解析的 JSON 消息，不需要解析的帧返回 nil
+func (dec *majsoulFrameDecoder) decode(frame []byte) ([]byte, error) {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("空帧")
+	}
+
+	switch frame[0] {
+	case majsoulFrameNotify:
+		name, data, err := dec.decodeWrapper(frame[1:])
+		if err != nil {
+			return nil, err
+		}
+		if !majsoulRawNotifies[name] {
+			return nil, nil
+		}
+		var msg map[string]interface{}
+		if name == majsoulActionPrototype {
+			msg, err = dec.decodeAction(data)
+		} else {
+			msg, err = dec.schema.decodeWithDefaults(name, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(msg)
+	case majsoulFrameRequest:
+		if len(frame) < 3 {
+			return nil, fmt.Errorf("请求帧过短")
+		}
+		name, _, err := dec.decodeWrapper(frame[3:])
+		if err != nil {
+			return nil, err
+		}
+		dec.pendingMethods[majsoulFrameIndex(frame)] = name
+		return nil, nil
+	case majsoulFrameResponse:
+		if len(frame) < 3 {
+			return nil, fmt.Errorf("响应帧过短")
+		}
+		index := majsoulFrameIndex(frame)
+		methodName, ok := dec.pendingMethods[index]
+		if !ok {
+			// 没有抓到对应的请求
+			return nil, nil
+		}
+		delete(dec.pendingMethods, index)
+		if !majsoulRawResponses[methodName] {
+			return nil, nil
+		}
+		method, ok := dec.schema.methods[methodName]
+		if !ok {
+			return nil, fmt.Errorf("未知的方法 %s", methodName)
+		}
+		_, data, err := dec.decodeWrapper(frame[3:])
+		if err != nil {
+			return nil, err
+		}
+		msg, err := dec.schema.decodeWithDefaults(method.ResponseType, data)
+		if err != nil {
+			return nil, err
+		}
+		// 重连时 game_restore.actions 中的操作保持 {step, name, data} 的格式，与注入脚本相同，见 majsoulMessage.GameRestore
+		return json.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("未知的帧类型 %d", frame[0])
+	}
+}
+
+func majsoulFrameIndex(frame []byte) int {
+	return int(frame[1]) | int(frame[2])<<8
+}
+
+func (dec *majsoulFrameDecoder) decodeWrapper(data []byte) (name string, innerData []byte, err error) {
+	wrapper, err := dec.schema.decode(".lq.Wrapper", data)
+	if err != nil {
+		return
+	}
+	name, _ = wrapper["name"].(string)
+	innerData, _ = wrapper["data"].(liqiBytes)
+	return
+}
+
+// 解码 .lq.ActionPrototype 中的操作
+func (dec *majsoulFrameDecoder) decodeAction(data []byte) (map[string]interface{}, error) {
+	action, err := dec.schema.decode(majsoulActionPrototype, data)
+	if err != nil {
+		return nil, err
+	}
+	return dec.decodeActionPrototype(action)
+}
+
+func (dec *majsoulFrameDecoder) decodeActionPrototype(action map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := action["name"].(string)
+	data, _ := action["data"].(liqiBytes)
+	if name == "" {
+		return nil, fmt.Errorf("操作没有名称")
+	}
+	return dec.schema.decodeWithDefaults(".lq."+name, data)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// 用于测试的 protobuf 编码
+func pbVarint(v uint64) (b []byte) {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func pbUint(id int, v uint64) []byte {
+	return append(pbVarint(uint64(id)<<3|protoWireVarint), pbVarint(v)...)
+}
+
+func pbBytes(id int, data []byte) []byte {
+	b := append(pbVarint(uint64(id)<<3|protoWireBytes), pbVarint(uint64(len(data)))...)
+	return append(b, data...)
+}
+
+func pbString(id int, s string) []byte {
+	return pbBytes(id, []byte(s))
+}
+
+func pbConcat(fields ...[]byte) (b []byte) {
+	for _, field := range fields {
+		b = append(b, field...)
+	}
+	return
+}
+
+func majsoulTestFrame(frameType byte, index int, name string, data []byte) []byte {
+	frame := []byte{frameType}
+	if frameType != majsoulFrameNotify {
+		frame = append(frame, byte(index), byte(index>>8))
+	}
+	return append(frame, pbConcat(pbString(1, name), pbBytes(2, data))...)
+}
+
+func majsoulTestAction(name string, data []byte) []byte {
+	return pbConcat(pbUint(1, 1), pbString(2, name), pbBytes(3, data))
+}
+
+func TestParseLiqiSchema(t *testing.T) {
+	s := mustLiqiSchema()
+	if method := s.methods[".lq.FastTest.authGame"]; method == nil || method.RequestType != ".lq.ReqAuthGame" || method.ResponseType != ".lq.ResAuthGame" {
+		t.Fatal("方法解析有误", method)
+	}
+	// 嵌套类型
+	for _, field := range s.messages[".lq.RecordGame"].fields {
+		if field.name == "accounts" && field.typeName != ".lq.RecordGame.AccountInfo" {
+			t.Fatal("嵌套类型解析有误", field.typeName)
+		}
+	}
+}
+
+func TestMajsoulFrameDecoder(t *testing.T) {
+	dec := newMajsoulFrameDecoder()
+
+	decode := func(frame []byte) map[string]interface{} {
+		data, err := dec.decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data == nil {
+			return nil
+		}
+		msg := map[string]interface{}{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	// 请求不需要解析，只记录方法
+	if msg := decode(majsoulTestFrame(majsoulFrameRequest, 300, ".lq.FastTest.authGame", pbString(1, "token"))); msg != nil {
+		t.Fatal("请求不应解析", msg)
+	}
+
+	// 响应，seat_list 为 packed
+	// 与真实的 proto3 编码一致，值为默认值的字段（is_game_start=false 等）不编码，解码时补上
+	packedSeatList := pbConcat(pbVarint(200), pbVarint(100), pbVarint(300), pbVarint(400))
+	resAuthGame := pbConcat(
+		pbBytes(3, packedSeatList),
+		pbBytes(5, pbBytes(2, pbUint(1, 2))), // game_config.mode.mode = 2
+		pbUint(99, 1),                        // 未知字段
+	)
+	msg := decode(majsoulTestFrame(majsoulFrameResponse, 300, "", resAuthGame))
+	d := &majsoulMessage{}
+	data, _ := json.Marshal(msg)
+	if err := json.Unmarshal(data, d); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.SeatList, []int{200, 100, 300, 400}) || d.IsGameStart == nil || *d.IsGameStart || d.GameConfig == nil || d.GameConfig.Mode.Mode != 2 {
+		t.Fatal("ResAuthGame 解码有误", string(data))
+	}
+
+	// 同一序号的响应只解析一次
+	if msg := decode(majsoulTestFrame(majsoulFrameResponse, 300, "", resAuthGame)); msg != nil {
+		t.Fatal("没有对应请求的响应不应解析", msg)
+	}
+
+	// 对局中的操作
+	// 座位 0 的摸牌，seat 和 zhenting 均不编码
+	dealTile := pbConcat(pbString(2, "0m"), pbUint(3, 23))
+	msg = decode(majsoulTestFrame(majsoulFrameNotify, 0, majsoulActionPrototype, majsoulTestAction("ActionDealTile", dealTile)))
+	expected := map[string]interface{}{"seat": 0.0, "tile": "0m", "left_tile_count": 23.0, "zhenting": false}
+	if !reflect.DeepEqual(msg, expected) {
+		t.Fatal("ActionDealTile 解码有误", msg)
+	}
+
+	// 手切，moqie、is_liqi 等均不编码
+	discardTile := pbConcat(pbUint(1, 2), pbString(2, "5p"))
+	msg = decode(majsoulTestFrame(majsoulFrameNotify, 0, majsoulActionPrototype, majsoulTestAction("ActionDiscardTile", discardTile)))
+	d = &majsoulMessage{}
+	data, _ = json.Marshal(msg)
+	if err := json.Unmarshal(data, d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Seat == nil || *d.Seat != 2 || d.Moqie == nil || *d.Moqie || d.IsLiqi == nil || *d.IsLiqi || d.IsWliqi == nil || *d.IsWliqi {
+		t.Fatal("ActionDiscardTile 解码有误", string(data))
+	}
+
+	// 无关的通知
+	if msg := decode(majsoulTestFrame(majsoulFrameNotify, 0, ".lq.NotifyPlayerConnectionState", pbUint(1, 2))); msg != nil {
+		t.Fatal("无关的通知不应解析", msg)
+	}
+
+	// 重连
+	decode(majsoulTestFrame(majsoulFrameRequest, 301, ".lq.FastTest.syncGame", nil))
+	discardTile = pbConcat(pbString(2, "1z"), pbUint(5, 1))
+	gameRestore := pbConcat(
+		pbBytes(2, majsoulTestAction("ActionDealTile", dealTile)),
+		pbBytes(2, majsoulTestAction("ActionDiscardTile", discardTile)),
+	)
+	msg = decode(majsoulTestFrame(majsoulFrameResponse, 301, "", pbBytes(4, gameRestore)))
+	d = &majsoulMessage{}
+	data, _ = json.Marshal(msg)
+	if err := json.Unmarshal(data, d); err != nil {
+		t.Fatal(err)
+	}
+	if d.GameRestore == nil || len(d.GameRestore.Actions) != 2 || d.GameRestore.Actions[1].Name != "ActionDiscardTile" {
+		t.Fatal("ResSyncGame 解码有误", string(data))
+	}
+	action, err := d.GameRestore.Actions[1].message()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未编码的 is_liqi 等字段补上默认值
+	if action.Tile != "1z" || action.Moqie == nil || !*action.Moqie || action.IsLiqi == nil || *action.IsLiqi {
+		t.Fatal("ResSyncGame 中的操作解码有误", string(data))
+	}
+
+	// 数据不完整
+	if _, err := dec.decode(majsoulTestFrame(majsoulFrameNotify, 0, majsoulActionPrototype, majsoulTestAction("ActionDealTile", dealTile))[:10]); err == nil {
+		t.Fatal("应当解码失败")
+	}
+}
+
+func TestParseHexFrame(t *testing.T) {
+	if frame, ok := parseHexFrame("01 0a0b"); !ok || !reflect.DeepEqual(frame, []byte{1, 10, 11}) {
+		t.Fatal("十六进制帧解析有误", frame)
+	}
+	for _, line := range []string{"", "{}", "04aa", "01a"} {
+		if _, ok := parseHexFrame(line); ok {
+			t.Fatal(line, "不是雀魂帧")
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMajsoulTranslate(t *testing.T) {
+	debugMode = true
+
+	d := &majsoulRoundData{accountID: 100}
+	d.roundData = newRoundData(d, 0, 0)
+
+	eventTypes := func(raw string) (types []string) {
+		msg := &majsoulMessage{}
+		if err := json.Unmarshal([]byte(raw), msg); err != nil {
+			t.Fatal(err)
+		}
+		d.msg = msg
+		for {
+			event, err := d.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event == nil {
+				return
+			}
+			types = append(types, reflect.TypeOf(event).Elem().Name())
+			if err := d.handleEvent(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	assertTypes := func(raw string, expected ...string) {
+		if types := eventTypes(raw); !reflect.DeepEqual(types, expected) {
+			t.Fatal(raw, "事件有误", types)
+		}
+	}
+
+	// 自家为第一局的南家
+	assertTypes(`{"is_game_start":false,"seat_list":[200,100,300,400],"game_config":{"mode":{"mode":2}}}`, "GameStartEvent", "SeatEvent")
+	if d.dealer != 3 {
+		t.Fatal("庄家有误", d.dealer)
+	}
+
+	assertTypes(`{"chang":0,"ju":1,"ben":0,"tiles":["1m","3m","7m","3p","6p","7p","6s","1z","1z","2z","3z","4z","7z","9s"],"dora":"6m","scores":[25000,25000,25000,25000],"liqibang":0,"md5":"abc"}`, "InitEvent")
+	if d.dealer != 0 || d.roundNumber != 1 {
+		t.Fatal("庄家有误", d.dealer, d.roundNumber)
+	}
+
+	// 自家舍牌立直，下家摸牌时附带立直成功
+	assertTypes(`{"seat":1,"tile":"9s","is_liqi":true,"moqie":false,"is_wliqi":false}`, "DiscardEvent")
+	if !d.players[0].isReached {
+		t.Fatal("立直有误")
+	}
+	assertTypes(`{"seat":2,"left_tile_count":68,"liqi":{"seat":1,"score":24000,"liqibang":1}}`, "RiichiEvent")
+	if d.game.scores[0] != 24000 || d.game.riichiSticks != 1 {
+		t.Fatal("立直成功有误", d.game.scores, d.game.riichiSticks)
+	}
+
+	// 他家暗杠后的摸牌，只翻出杠宝牌
+	assertTypes(`{"seat":2,"left_tile_count":60,"doras":["6m","1p"]}`, "DoraEvent")
+	// 杠宝牌先于自家摸牌处理
+	assertTypes(`{"seat":1,"tile":"2z","left_tile_count":59,"doras":["6m","1p","2p"]}`, "DoraEvent", "DrawEvent")
+	if len(d.doraIndicators) != 3 {
+		t.Fatal("宝牌指示牌有误", d.doraIndicators)
+	}
+}
+
+func TestMajsoulOperation(t *testing.T) {
+	parse := func(raw string) *ActionOptions {
+		msg := &majsoulMessage{}
+		if err := json.Unmarshal([]byte(raw), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.Operation.actionOptions()
+	}
+
+	options := parse(`{"seat":0,"tile":"6p","operation":{"seat":1,"operation_list":[{"type":2,"combination":["7p|8p"]},{"type":3,"combination":["6p|6p"]},{"type":9}]}}`)
+	if options == nil || !options.Chi || !options.Pon || options.Minkan || !options.Ron {
+		t.Fatal("舍牌选项有误", options)
+	}
+	options = parse(`{"seat":1,"tile":"5m","operation":{"seat":1,"operation_list":[{"type":1},{"type":4,"combination":["5m|5m|5m|0m"]},{"type":7},{"type":8}]}}`)
+	if options == nil || !options.SelfKan || !options.Riichi || !options.Tsumo || options.canCall() {
+		t.Fatal("摸牌选项有误", options)
+	}
+	// 只能舍牌
+	if options := parse(`{"seat":1,"tile":"5m","operation":{"seat":1,"operation_list":[{"type":1}]}}`); options != nil {
+		t.Fatal("只能舍牌", options)
+	}
+	if options := parse(`{"seat":1,"tile":"5m"}`); options != nil {
+		t.Fatal("没有操作", options)
+	}
+}
+
+func TestMajsoulHuleZimoPoint(t *testing.T) {
+	for _, tc := range []struct {
+		playerNumber int
+		seat         int
+		expected     int
+	}{
+		{4, 0, 3 * 2600},
+		{4, 1, 2600 + 2*1300},
+		{3, 0, 2 * 2600},
+		{3, 1, 2600 + 1300},
+	} {
+		d := &majsoulRoundData{}
+		d.roundData = newRoundDataWithGame(d, newGameData(gameLengthHanchan, tc.playerNumber), 0, 0)
+		hule := &majsoulHuleInfo{Seat: tc.seat, Zimo: true, PointZimoQin: 2600, PointZimoXian: 1300}
+		if tc.seat == 0 {
+			hule.PointZimoXian = 2600
+		}
+		if point := d.parseHuleInfo(hule).Point; point != tc.expected {
+			t.Fatal("自摸点数有误", tc.playerNumber, tc.seat, point)
+		}
+	}
+}
+
+func TestMajsoulReconnect(t *testing.T) {
+	d := &majsoulRoundData{accountID: 100}
+	d.roundData = newRoundData(d, 0, 0)
+	d.skipOutput = true
+
+	analysis := func(raw string) {
+		d.msg = &majsoulMessage{}
+		if err := json.Unmarshal([]byte(raw), d.msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 自家为第一局的南家
+	analysis(`{"is_game_start":true,"seat_list":[200,100,300,400],"game_config":{"mode":{"mode":2}}}`)
+
+	// 与 ResSyncGame 相同的格式，data 为 protobuf 编码后的 base64 字符串
+	// 东一局的 chang 和 ju 均为 0，不会被编码；舍牌的 moqie 和 is_liqi 为 false，同样不会被编码
+	tiles := []string{"1m", "3m", "7m", "3p", "6p", "7p", "6s", "1z", "1z", "2z", "3z", "4z", "7z"}
+	newRound := pbConcat(pbString(5, "6m"), pbBytes(6, pbConcat(pbVarint(25000), pbVarint(25000), pbVarint(25000), pbVarint(25000))), pbString(12, "abc"), pbUint(13, 69))
+	for _, tile := range tiles {
+		newRound = append(newRound, pbString(4, tile)...)
+	}
+	actions := []struct {
+		name string
+		data []byte
+	}{
+		{"ActionNewRound", newRound},
+		{"ActionDiscardTile", pbString(2, "9m")},                      // 东家（上家）舍牌
+		{"ActionDealTile", pbConcat(pbUint(1, 1), pbString(2, "5s"))}, // 自家摸牌
+	}
+	restoredActions := []string{}
+	for i, action := range actions {
+		restoredActions = append(restoredActions, fmt.Sprintf(`{"step":%d,"name":"%s","data":"%s"}`, i, action.name, base64.StdEncoding.EncodeToString(action.data)))
+	}
+	analysis(`{"is_end":false,"step":3,"game_restore":{"actions":[` + strings.Join(restoredActions, ",") + `],"game_state":1}}`)
+
+	if d.roundNumber != 0 || d.dealer != 3 {
+		t.Fatal("场次有误", d.roundNumber, d.dealer)
+	}
+	if discards := d.players[3].discardTiles; len(discards) != 1 || discards[0] != 8 {
+		t.Fatal("上家的牌河有误", discards)
+	}
+	if handCount := util.CountOfTiles34(d.counts); handCount != 14 {
+		t.Fatal("手牌数有误", handCount)
+	}
+}
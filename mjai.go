@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"github.com/fatih/color"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// mjai 协议
+// https://gimite.net/pukiwiki/index.php?Mjai%20%E9%BA%BB%E9%9B%80AI%E5%AF%BE%E6%88%A6%E3%82%B5%E3%83%BC%E3%83%90
+// 座位均为绝对座位（0 为第一局的东家），牌的写法为 1m-9m 1p-9p 1s-9s E S W N P F C，赤5为 5mr 5pr 5sr，看不到的牌为 ?
+type mjaiMessage struct {
+	Type string `json:"type"`
+
+	// start_game
+	// {"type":"start_game","id":0,"names":["shanten","shanten","shanten","shanten"]}
+	ID    *int     `json:"id"` // 自家座位
+	Names []string `json:"names"`
+
+	// start_kyoku
+	// {"type":"start_kyoku","bakaze":"E","kyoku":1,"honba":0,"kyotaku":0,"oya":0,"dora_marker":"7s","tehais":[["3m","4m",...],["?","?",...],...],"scores":[25000,25000,25000,25000]}
+	Bakaze     string     `json:"bakaze"`
+	Kyoku      int        `json:"kyoku"` // 1-4
+	Honba      int        `json:"honba"`
+	Kyotaku    int        `json:"kyotaku"` // 场上的立直棒数
+	Oya        int        `json:"oya"`
+	DoraMarker string     `json:"dora_marker"` // 同样用于 dora
+	Tehais     [][]string `json:"tehais"`
+	Scores     []int      `json:"scores"`
+
+	// tsumo {"type":"tsumo","actor":0,"pai":"6p"}
+	// dahai {"type":"dahai","actor":0,"pai":"6p","tsumogiri":true}
+	// chi/pon/daiminkan {"type":"pon","actor":1,"target":0,"pai":"5m","consumed":["5m","5mr"]}
+	// kakan {"type":"kakan","actor":1,"pai":"5m","consumed":["5m","5m","5mr"]}
+	// ankan {"type":"ankan","actor":1,"consumed":["5m","5m","5m","5mr"]}
+	// reach {"type":"reach","actor":1}
+	// reach_accepted {"type":"reach_accepted","actor":1,"deltas":[0,-1000,0,0],"scores":[25000,24000,25000,25000]}
+	// nukidora {"type":"nukidora","actor":1,"pai":"N"}（三人麻将）
+	Actor     int      `json:"actor"`
+	Target    int      `json:"target"`
+	Pai       string   `json:"pai"`
+	Tsumogiri bool     `json:"tsumogiri"`
+	Consumed  []string `json:"consumed"`
+
+	// hora {"type":"hora","actor":1,"target":0,"pai":"5m","fu":30,"fan":2,"hora_points":2000,"deltas":[-2000,2000,0,0],"scores":[23000,27000,25000,25000]}
+	// ryukyoku {"type":"ryukyoku","reason":"fanpai","tenpais":[true,false,false,false],"deltas":[3000,-1000,-1000,-1000],"scores":[28000,24000,24000,24000]}
+	HoraPoints int    `json:"hora_points"`
+	Deltas     []int  `json:"deltas"`
+	Reason     string `json:"reason"`
+	Tenpais    []bool `json:"tenpais"`
+}
+
+const mjaiUnknownTile = "?"
+
+var mjaiHonorTiles = []string{"E", "S", "W", "N", "P", "F", "C"}
+
+// 解析 mjai 的牌，如 5mr 为赤5m，P 为白
+func parseMjaiTile(mjaiTile string) (tile34 int, isRedFive bool, err error) {
+	for i, honor := range mjaiHonorTiles {
+		if mjaiTile == honor {
+			return 27 + i, false, nil
+		}
+	}
+	if len(mjaiTile) == 3 && mjaiTile[0] == '5' && mjaiTile[2] == 'r' {
+		mjaiTile = mjaiTile[:2]
+		isRedFive = true
+	}
+	if len(mjaiTile) != 2 || mjaiTile[0] < '1' || mjaiTile[0] > '9' {
+		return -1, false, fmt.Errorf("无法解析 mjai 牌 %q", mjaiTile)
+	}
+	idx := strings.IndexByte("mps", mjaiTile[1])
+	if idx == -1 {
+		return -1, false, fmt.Errorf("无法解析 mjai 牌 %q", mjaiTile)
+	}
+	return 9*idx + int(mjaiTile[0]-'1'), isRedFive, nil
+}
+
+// 转换成 mjai 的牌，用于回应
+func mjaiTile(tile34 int, isRedFive bool) string {
+	if tile34 >= 27 {
+		return mjaiHonorTiles[tile34-27]
+	}
+	s := fmt.Sprintf("%d%c", tile34%9+1, "mps"[tile34/9])
+	if isRedFive {
+		s += "r"
+	}
+	return s
+}
+
+var mjaiBakazes = []string{"E", "S", "W", "N"}
+
+// 部分实现（如 Mortal）的 ryukyoku 没有 reason，均视作荒牌流局
+var mjaiRyuukyokuTypeMap = map[string]int{
+	"":              ryuukyokuTypeExhaustive,
+	"fanpai":        ryuukyokuTypeExhaustive,
+	"nagashimangan": ryuukyokuTypeNagashiMangan,
+	"kyushukyuhai":  ryuukyokuTypeKyuushuKyuuhai,
+	"sufonrenda":    ryuukyokuTypeSuufonRenda,
+	"suchareach":    ryuukyokuTypeSuuchaRiichi,
+	"sukaikan":      ryuukyokuTypeSuukanSanra,
+	"sanchaho":      ryuukyokuTypeSanchahou,
+}
+
+//
+
+type mjaiRoundData struct {
+	*roundData
+
+	originJSON string
+	seat       int // 自家的绝对座位
+	msg        *mjaiMessage
+
+	events eventQueue
+
+	// 以下用于机器人模式，见 mjai_bot.go
+	drawTile       string // 自家最近摸到的牌（mjai 的写法），用于判断是否摸切，舍牌后为空
+	pendingDiscard int    // 立直宣言、吃碰之后要切的牌，没有时为 -1
+	skippedWinTile bool   // 同巡内见逃了和了牌（同巡振听），自家舍牌后解除
+}
+
+func (d *mjaiRoundData) parseWho(actor int) int {
+	// 转换成 0=自家, 1=下家, 2=对家, 3=上家
+	playerNumber := len(d.players)
+	return (actor - d.seat + playerNumber) % playerNumber
+}
+
+func (d *mjaiRoundData) mustParseMjaiTile(mjaiTile string) (tile34 int, isRedFive bool) {
+	tile34, isRedFive, err := parseMjaiTile(mjaiTile)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+// 按座位排列的数据转换成按 0=自家, 1=下家, 2=对家, 3=上家 排列
+func (d *mjaiRoundData) rotate(seatValues []int) []int {
+	if len(seatValues) == 0 {
+		return nil
+	}
+	values := make([]int, len(seatValues))
+	for seat, v := range seatValues {
+		values[(seat-d.seat+len(seatValues))%len(seatValues)] = v
+	}
+	return values
+}
+
+func (d *mjaiRoundData) parseMeld() *model.Meld {
+	msg := d.msg
+	var meldType int
+	switch msg.Type {
+	case "chi":
+		meldType = meldTypeChi
+	case "pon":
+		meldType = meldTypePon
+	case "daiminkan":
+		meldType = meldTypeMinkan
+	case "kakan":
+		meldType = meldTypeKakan
+	case "ankan":
+		meldType = meldTypeAnkan
+	}
+
+	mjaiTiles := append([]string{}, msg.Consumed...)
+	if meldType != meldTypeAnkan {
+		mjaiTiles = append(mjaiTiles, msg.Pai)
+	}
+	meld := &model.Meld{MeldType: meldType}
+	for _, mjaiTile := range mjaiTiles {
+		tile, isRedFive := d.mustParseMjaiTile(mjaiTile)
+		meld.Tiles = append(meld.Tiles, tile)
+		if isRedFive {
+			meld.ContainRedFive = true
+		}
+	}
+	sort.Ints(meld.Tiles)
+
+	if meldType == meldTypeAnkan {
+		meld.CalledTile = meld.Tiles[0]
+	} else {
+		calledTile, isRedFive := d.mustParseMjaiTile(msg.Pai)
+		meld.CalledTile = calledTile
+		meld.RedFiveFromOthers = isRedFive && meldType != meldTypeKakan
+	}
+	return meld
+}
+
+func (d *mjaiRoundData) GetDataSourceType() int {
+	return dataSourceTypeMjai
+}
+
+func (d *mjaiRoundData) GetMessage() string {
+	return d.originJSON
+}
+
+func (d *mjaiRoundData) CheckMessage() bool {
+	return true
+}
+
+func (d *mjaiRoundData) Next() (Event, error) {
+	return d.events.next(d.msg, d.translate)
+}
+
+// 将 mjai 消息翻译成事件
+func (d *mjaiRoundData) translate() (events []Event, err error) {
+	msg := d.msg
+
+	var event Event
+	switch msg.Type {
+	case "start_game":
+		if msg.ID != nil {
+			d.seat = *msg.ID
+		}
+		playerNumber := 4
+		if len(msg.Names) == 3 {
+			playerNumber = 3
+		}
+		// mjai 没有对局长度的信息，视作半庄战
+		event = &GameStartEvent{GameLength: gameLengthHanchan, PlayerNumber: playerNumber}
+	case "start_kyoku":
+		bakaze := -1
+		for i, b := range mjaiBakazes {
+			if msg.Bakaze == b {
+				bakaze = i
+			}
+		}
+		if bakaze == -1 || msg.Kyoku < 1 || d.seat >= len(msg.Tehais) {
+			return nil, fmt.Errorf("start_kyoku 数据有误 %s", d.originJSON)
+		}
+		doraIndicator, _ := d.mustParseMjaiTile(msg.DoraMarker)
+		numRedFives := make([]int, 3)
+		handTiles := []int{}
+		for _, mjaiTile := range msg.Tehais[d.seat] {
+			tile, isRedFive := d.mustParseMjaiTile(mjaiTile)
+			handTiles = append(handTiles, tile)
+			if isRedFive {
+				numRedFives[tile/9]++
+			}
+		}
+		event = &InitEvent{
+			RoundNumber:    4*bakaze + msg.Kyoku - 1,
+			Dealer:         d.parseWho(msg.Oya),
+			DoraIndicators: []int{doraIndicator},
+			HandTiles:      handTiles,
+			NumRedFives:    numRedFives,
+			Honba:          msg.Honba,
+			RiichiSticks:   msg.Kyotaku,
+			Scores:         d.rotate(msg.Scores),
+		}
+	case "tsumo":
+		// 他家摸牌时为 ?
+		if d.parseWho(msg.Actor) != 0 || msg.Pai == mjaiUnknownTile {
+			return
+		}
+		tile, isRedFive := d.mustParseMjaiTile(msg.Pai)
+		event = &DrawEvent{Tile: tile, IsRedFive: isRedFive}
+	case "dahai":
+		who := d.parseWho(msg.Actor)
+		tile, isRedFive := d.mustParseMjaiTile(msg.Pai)
+		event = &DiscardEvent{
+			Who:         who,
+			Tile:        tile,
+			IsRedFive:   isRedFive,
+			IsTsumogiri: msg.Tsumogiri,
+			// mjai 不会告知能否鸣牌，交给鸣牌分析判断，但不提示可以鸣牌
+			Options: &ActionOptions{Chi: true, Pon: true, Minkan: true, IsGuess: true},
+		}
+	case "chi", "pon", "daiminkan", "kakan", "ankan":
+		event = &CallEvent{Who: d.parseWho(msg.Actor), Meld: d.parseMeld()}
+	case "nukidora":
+		event = &KitaEvent{Who: d.parseWho(msg.Actor)}
+	case "reach":
+		event = &RiichiEvent{Who: d.parseWho(msg.Actor)}
+	case "reach_accepted":
+		event = &RiichiEvent{Who: d.parseWho(msg.Actor), IsAccepted: true}
+	case "dora":
+		doraIndicator, _ := d.mustParseMjaiTile(msg.DoraMarker)
+		event = &DoraEvent{Indicator: doraIndicator}
+	case "hora":
+		event = &WinEvent{
+			Whos:        []int{d.parseWho(msg.Actor)},
+			Points:      []int{msg.HoraPoints},
+			DeltaPoints: d.rotate(msg.Deltas),
+		}
+	case "ryukyoku":
+		ryuukyokuType, ok := mjaiRyuukyokuTypeMap[msg.Reason]
+		if !ok {
+			return nil, fmt.Errorf("未知的流局类型 %s", msg.Reason)
+		}
+		var tenpaiWhos []int
+		for seat, tenpai := range msg.Tenpais {
+			if tenpai {
+				tenpaiWhos = append(tenpaiWhos, d.parseWho(seat))
+			}
+		}
+		sort.Ints(tenpaiWhos)
+		event = &DrawGameEvent{Type: ryuukyokuType, TenpaiWhos: tenpaiWhos, DeltaPoints: d.rotate(msg.Deltas)}
+	default:
+		// hello, end_kyoku, end_game 等
+		return
+	}
+	return []Event{event}, nil
+}
+
+//
+
+// 对 mjai 消息的回应
+type mjaiResponse struct {
+	Type string `json:"type"`
+
+	// join
+	Name string `json:"name,omitempty"`
+	Room string `json:"room,omitempty"`
+
+	// dahai/reach/hora/chi/pon/daiminkan/kakan/ankan，字段含义与 mjaiMessage 相同
+	Actor     *int     `json:"actor,omitempty"`
+	Target    *int     `json:"target,omitempty"`
+	Pai       string   `json:"pai,omitempty"`
+	Tsumogiri *bool    `json:"tsumogiri,omitempty"`
+	Consumed  []string `json:"consumed,omitempty"`
+}
+
+// 拆分一行 mjai 数据，可以是单条消息，也可以是消息数组（如 mjai.app）
+func splitMjaiLine(line []byte) (messages []json.RawMessage, err error) {
+	trimmed := strings.TrimSpace(string(line))
+	if strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal([]byte(trimmed), &messages)
+		return
+	}
+	return []json.RawMessage{json.RawMessage(trimmed)}, nil
+}
+
+// 通过标准输入输出与 mjai 模拟器对接
+// 每行输入为 mjai 消息，每行输出为机器人的回应（见 mjai_bot.go），分析结果输出到标准错误
+func runMjaiStdio(in io.Reader, out io.Writer) {
+	h := &mjHandler{mjaiRoundData: &mjaiRoundData{pendingDiscard: -1}}
+	h.mjaiRoundData.roundData = newRoundData(h.mjaiRoundData, 0, 0)
+
+	encoder := json.NewEncoder(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		messages, err := splitMjaiLine(scanner.Bytes())
+		if err != nil {
+			fmt.Println("错误：", err)
+			continue
+		}
+
+		response := &mjaiResponse{Type: "none"}
+		for _, msg := range messages {
+			d := mjaiMessage{}
+			if err := json.Unmarshal(msg, &d); err != nil {
+				fmt.Println("错误：", err)
+				continue
+			}
+			if d.Type == "hello" {
+				response = &mjaiResponse{Type: "join", Name: "mahjong-helper", Room: "default"}
+				continue
+			}
+			if err := h.handleMjaiMessage(&d, msg); err != nil {
+				fmt.Println("错误：", err)
+			}
+			// 消息数组只需回应最后一条，但每条消息都要更新机器人的状态
+			response = h.mjaiRoundData.respond()
+		}
+		if err := encoder.Encode(response); err != nil {
+			fmt.Println("错误：", err)
+			return
+		}
+	}
+}
+
+// 通过标准输入输出对接时，标准输出留给 mjai 协议，其余输出都转到标准错误
+var mjaiProtocolOut io.Writer = os.Stdout
+
+func redirectOutputForMjai() {
+	mjaiProtocolOut = os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+}
+
+func runMjai() {
+	runMjaiStdio(os.Stdin, mjaiProtocolOut)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+)
+
+// mjai 机器人：按照助手的分析结果回应 mjai 的决策请求
+// 自家摸牌后回应 hora/reach/ankan/kakan/dahai，他家舍牌后回应 hora/chi/pon/daiminkan/none
+// 用于在本地与其他 mjai 机器人自战，客观地衡量改动对强度的影响
+
+// 有他家立直时，若自家（切牌后）的向听数不低于此值则弃和，切铳率最低的牌
+const mjaiBotFoldShanten = 1
+
+// 立直需要的牌山剩余枚数，少于此值时默听
+const mjaiBotMinRiichiWallLeft = 4
+
+func (d *mjaiRoundData) newResponse(responseType string) *mjaiResponse {
+	seat := d.seat
+	return &mjaiResponse{Type: responseType, Actor: &seat}
+}
+
+// 根据最近一条消息决定如何回应，不需要做决策时回应 none
+func (d *mjaiRoundData) respond() (response *mjaiResponse) {
+	response = &mjaiResponse{Type: "none"}
+	if !debugMode {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Println("内部错误：", r)
+				response = &mjaiResponse{Type: "none"}
+			}
+		}()
+	}
+
+	msg := d.msg
+	switch msg.Type {
+	case "start_kyoku":
+		d.drawTile = ""
+		d.pendingDiscard = -1
+		d.skippedWinTile = false
+	case "tsumo":
+		if d.parseWho(msg.Actor) == 0 {
+			d.drawTile = msg.Pai
+			return d.respondDraw()
+		}
+	case "reach", "chi", "pon":
+		// 立直宣言、吃碰之后切牌
+		if d.parseWho(msg.Actor) == 0 && d.pendingDiscard != -1 {
+			tile := d.pendingDiscard
+			d.pendingDiscard = -1
+			return d.newDahaiResponse(tile)
+		}
+	case "dahai":
+		if who := d.parseWho(msg.Actor); who != 0 {
+			return d.respondDiscard(who)
+		}
+		d.drawTile = ""
+		d.skippedWinTile = false
+	}
+	return
+}
+
+// 自家摸牌后的决策
+func (d *mjaiRoundData) respondDraw() *mjaiResponse {
+	tile, _ := d.mustParseMjaiTile(d.drawTile)
+
+	// 自摸
+	if d.canWin(tile, true) {
+		response := d.newResponse("hora")
+		response.Target = response.Actor
+		response.Pai = d.drawTile
+		return response
+	}
+
+	// 立直后只能摸切或者不改变听牌的暗杠
+	if d.players[0].isReached {
+		if d.counts[tile] == 4 {
+			d.counts[tile]--
+			waits := d.selfWaits()
+			d.counts[tile]++
+			if d.canRiichiAnkan(tile, waits) {
+				return d.newAnkanResponse(tile)
+			}
+		}
+		return d.newTsumogiriResponse()
+	}
+
+	pi := d.newModelPlayerInfo(d.analysisTilesRisk())
+	_, results14, incShantenResults14 := util.CalculateShantenWithImproves14(pi)
+	choices := append(append(util.Hand14AnalysisResultList{}, results14...), incShantenResults14...)
+	if len(choices) == 0 {
+		return d.newTsumogiriResponse()
+	}
+	best := choices[0]
+	bestShanten := best.Result13.Shanten
+
+	if d.shouldFold(bestShanten) {
+		return d.newDahaiResponse(d.safestTile())
+	}
+
+	// 暗杠、加杠不会让向听倒退时就杠
+	if !d.someoneReached() {
+		for kanTile, c := range d.counts {
+			if c == 4 && d.shantenAfterRemove(kanTile, 4) <= bestShanten {
+				return d.newAnkanResponse(kanTile)
+			}
+		}
+		for _, meld := range d.players[0].melds {
+			if meld.MeldType == meldTypePon && d.counts[meld.CalledTile] > 0 && d.shantenAfterRemove(meld.CalledTile, 1) <= bestShanten {
+				return d.newKakanResponse(meld.CalledTile)
+			}
+		}
+	}
+
+	if d.shouldRiichi(best.Result13) {
+		d.pendingDiscard = best.DiscardTile
+		return d.newResponse("reach")
+	}
+	return d.newDahaiResponse(best.DiscardTile)
+}
+
+// 他家舍牌后的决策
+func (d *mjaiRoundData) respondDiscard(who int) *mjaiResponse {
+	tile, isRedFive := d.mustParseMjaiTile(d.msg.Pai)
+
+	// 荣和，见逃后直到自家舍牌前都是同巡振听
+	if _, ok := d.selfWaits()[tile]; ok {
+		canWin := !d.skippedWinTile && d.canWin(tile, false)
+		d.skippedWinTile = true
+		if canWin {
+			response := d.newResponse("hora")
+			target := d.msg.Actor
+			response.Target = &target
+			response.Pai = d.msg.Pai
+			return response
+		}
+	}
+
+	none := &mjaiResponse{Type: "none"}
+
+	// 立直后不能鸣牌，河底牌不能鸣牌
+	if d.players[0].isReached || d.liveWallLeft() == 0 {
+		return none
+	}
+
+	shanten13 := util.CalculateShanten(d.counts)
+	if d.shouldFold(shanten13) {
+		return none
+	}
+
+	target := d.msg.Actor
+
+	// 已经副露时，大明杠不会让向听倒退就杠
+	if d.counts[tile] == 3 && d.players[0].isNaki && !d.someoneReached() && d.shantenAfterRemove(tile, 3) <= shanten13 {
+		response := d.newResponse("daiminkan")
+		response.Target = &target
+		response.Pai = d.msg.Pai
+		response.Consumed = d.consumedTiles([]int{tile, tile, tile})
+		return response
+	}
+
+	// 吃碰后能让向听前进且有役时才鸣牌
+	allowChi := who == d.kamicha() && !d.isSanma()
+	shanten, results14, _ := util.CalculateMeld(d.newModelPlayerInfo(d.analysisTilesRisk()), tile, isRedFive, allowChi, true)
+	if len(results14) == 0 || shanten >= shanten13 {
+		return none
+	}
+	best := results14[0]
+	if len(best.Result13.YakuTypes) == 0 {
+		return none
+	}
+
+	responseType := "chi"
+	if best.OpenTiles[0] == best.OpenTiles[1] {
+		responseType = "pon"
+	}
+	response := d.newResponse(responseType)
+	response.Target = &target
+	response.Pai = d.msg.Pai
+	response.Consumed = d.consumedTiles(best.OpenTiles)
+	d.pendingDiscard = best.DiscardTile
+	return response
+}
+
+// 能否和牌（有役且没有振听）
+func (d *mjaiRoundData) canWin(tile int, isTsumo bool) bool {
+	pi := d.newModelPlayerInfo(nil)
+	pi.HandTiles34 = make([]int, 34)
+	copy(pi.HandTiles34, d.counts)
+	wallLeft := d.liveWallLeft()
+	if isTsumo {
+		// 自摸的牌还没有计入 liveWallLeft
+		pi.IsLastTile = wallLeft <= 1
+	} else {
+		if pi.IsFuriten(d.selfWaits()) {
+			return false
+		}
+		pi.HandTiles34[tile]++
+		pi.IsLastTile = wallLeft == 0
+	}
+	pi.IsTsumo = isTsumo
+	pi.WinTile = tile
+	return util.CalcPoint(pi).Point > 0
+}
+
+// 听牌且门清时立直，默听打点充足时默听
+func (d *mjaiRoundData) shouldRiichi(result13 *util.Hand13AnalysisResult) bool {
+	if d.players[0].isNaki || result13.Shanten != 0 || result13.Waits.AllCount() == 0 || result13.RiichiPoint == 0 {
+		return false
+	}
+	if d.game.scores[0] < 1000 || d.liveWallLeft() < mjaiBotMinRiichiWallLeft {
+		return false
+	}
+	// 同 analysisTiles34 中的提示
+	if result13.FuritenRate == 0 && result13.DamaPoint >= 5200 && result13.DamaWaits.AllCount() == result13.Waits.AllCount() {
+		return false
+	}
+	return true
+}
+
+func (d *mjaiRoundData) someoneReached() bool {
+	for _, player := range d.players[1:] {
+		if player.isReached {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *mjaiRoundData) shouldFold(shanten int) bool {
+	return d.someoneReached() && shanten >= mjaiBotFoldShanten
+}
+
+// 手牌中混合铳率最低的牌
+func (d *mjaiRoundData) safestTile() int {
+	mixedRiskTable := d.analysisTilesRisk().mixedRiskTable()
+	safestTile := -1
+	for tile, c := range d.counts {
+		if c > 0 && (safestTile == -1 || mixedRiskTable[tile] < mixedRiskTable[safestTile]) {
+			safestTile = tile
+		}
+	}
+	return safestTile
+}
+
+// 去掉 n 张 tile 后的向听数，用于判断杠后是否会向听倒退
+func (d *mjaiRoundData) shantenAfterRemove(tile int, n int) int {
+	d.counts[tile] -= n
+	shanten := util.CalculateShanten(d.counts)
+	d.counts[tile] += n
+	return shanten
+}
+
+// 手牌中（不含副露）的赤5数
+func (d *mjaiRoundData) numRedFivesInHand(tile int) int {
+	if tile >= 27 || tile%9 != 4 {
+		return 0
+	}
+	cnt := d.numRedFives[tile/9]
+	for _, meld := range d.players[0].melds {
+		if meld.ContainRedFive && meld.Tiles[0]/9 == tile/9 {
+			cnt--
+		}
+	}
+	return cnt
+}
+
+// 用手牌中的这些牌鸣牌，有赤5时优先用赤5
+func (d *mjaiRoundData) consumedTiles(tiles []int) (consumed []string) {
+	usedRedFive := map[int]bool{}
+	for _, tile := range tiles {
+		isRedFive := !usedRedFive[tile] && d.numRedFivesInHand(tile) > 0
+		if isRedFive {
+			usedRedFive[tile] = true
+		}
+		consumed = append(consumed, mjaiTile(tile, isRedFive))
+	}
+	return
+}
+
+// 切牌，同 util 中的分析，只有赤5时才切赤5
+func (d *mjaiRoundData) newDahaiResponse(tile int) *mjaiResponse {
+	response := d.newResponse("dahai")
+	isRedFive := d.counts[tile] > 0 && d.counts[tile] == d.numRedFivesInHand(tile)
+	response.Pai = mjaiTile(tile, isRedFive)
+	tsumogiri := response.Pai == d.drawTile
+	response.Tsumogiri = &tsumogiri
+	return response
+}
+
+// 摸切，立直后摸到赤5时也只能切这张牌
+func (d *mjaiRoundData) newTsumogiriResponse() *mjaiResponse {
+	response := d.newResponse("dahai")
+	response.Pai = d.drawTile
+	tsumogiri := true
+	response.Tsumogiri = &tsumogiri
+	return response
+}
+
+func (d *mjaiRoundData) newAnkanResponse(tile int) *mjaiResponse {
+	response := d.newResponse("ankan")
+	response.Consumed = d.consumedTiles([]int{tile, tile, tile, tile})
+	return response
+}
+
+func (d *mjaiRoundData) newKakanResponse(tile int) *mjaiResponse {
+	response := d.newResponse("kakan")
+	response.Pai = mjaiTile(tile, d.numRedFivesInHand(tile) > 0)
+	for _, meld := range d.players[0].melds {
+		if meld.MeldType == meldTypePon && meld.CalledTile == tile {
+			for i, meldTile := range meld.Tiles {
+				response.Consumed = append(response.Consumed, mjaiTile(meldTile, meld.ContainRedFive && i == 0))
+			}
+		}
+	}
+	return response
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseMjaiTile(t *testing.T) {
+	for mjaiTile, expected := range map[string]int{"1m": 0, "9m": 8, "5pr": 13, "7s": 24, "E": 27, "N": 30, "P": 31, "C": 33} {
+		tile, isRedFive, err := parseMjaiTile(mjaiTile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tile != expected || isRedFive != strings.HasSuffix(mjaiTile, "r") {
+			t.Fatal(mjaiTile, tile, isRedFive)
+		}
+	}
+	for _, mjaiTile := range []string{"?", "0m", "5zr", "1x", ""} {
+		if _, _, err := parseMjaiTile(mjaiTile); err == nil {
+			t.Fatal(mjaiTile, "应该解析失败")
+		}
+	}
+}
+
+func TestMjaiRoundData(t *testing.T) {
+	debugMode = true
+
+	h := &mjHandler{mjaiRoundData: &mjaiRoundData{}}
+	h.mjaiRoundData.roundData = newRoundData(h.mjaiRoundData, 0, 0)
+
+	lines := []string{
+		`{"type":"start_game","id":1,"names":["a","b","c","d"]}`,
+		`{"type":"start_kyoku","bakaze":"S","kyoku":2,"honba":1,"kyotaku":1,"oya":1,"dora_marker":"7s","tehais":[["?"],["1m","2m","3m","5pr","6p","7p","1s","2s","3s","E","E","P","C"],["?"],["?"]],"scores":[24000,25000,26000,24000]}`,
+		`{"type":"tsumo","actor":1,"pai":"5mr"}`,
+		`{"type":"dahai","actor":1,"pai":"C","tsumogiri":false}`,
+		`{"type":"tsumo","actor":2,"pai":"?"}`,
+		`{"type":"dahai","actor":2,"pai":"E","tsumogiri":true}`,
+		`{"type":"pon","actor":1,"target":2,"pai":"E","consumed":["E","E"]}`,
+		`{"type":"dahai","actor":1,"pai":"P","tsumogiri":false}`,
+		`{"type":"tsumo","actor":2,"pai":"?"}`,
+		`{"type":"reach","actor":2}`,
+		`{"type":"dahai","actor":2,"pai":"9m","tsumogiri":false}`,
+		`{"type":"reach_accepted","actor":2,"deltas":[0,0,-1000,0],"scores":[24000,25000,25000,24000]}`,
+		`{"type":"dora","dora_marker":"N"}`,
+	}
+	for _, line := range lines {
+		d := mjaiMessage{}
+		if err := json.Unmarshal([]byte(line), &d); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.handleMjaiMessage(&d, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rd := h.mjaiRoundData.roundData
+	if rd.roundNumber != 5 || rd.dealer != 0 || rd.roundWindTile != 28 {
+		t.Fatal("场数或庄家有误", rd.roundNumber, rd.dealer)
+	}
+	if rd.numRedFives[0] != 1 || rd.numRedFives[1] != 1 {
+		t.Fatal("赤5有误", rd.numRedFives)
+	}
+	if len(rd.players[0].melds) != 1 || rd.players[0].melds[0].CalledTile != 27 || rd.counts[27] != 0 {
+		t.Fatal("碰有误", rd.players[0].melds, rd.counts)
+	}
+	if !rd.players[1].isReached || rd.game.scores[1] != 25000 || rd.game.riichiSticks != 2 || rd.game.honba != 1 {
+		t.Fatal("立直有误", rd.game.scores, rd.game.riichiSticks)
+	}
+	if len(rd.doraIndicators) != 2 || rd.doraIndicators[1] != 30 {
+		t.Fatal("宝牌指示牌有误", rd.doraIndicators)
+	}
+}
+
+func TestRunMjaiStdio(t *testing.T) {
+	debugMode = true
+
+	in := strings.Join([]string{
+		`{"type":"hello","protocol":"mjsonp","protocol_version":3}`,
+		`[{"type":"start_game","id":0,"names":["a","b","c","d"]}]`,
+		`{"type":"end_game"}`,
+	}, "\n")
+	out := &bytes.Buffer{}
+	runMjaiStdio(strings.NewReader(in), out)
+
+	responses := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(responses) != 3 || !strings.Contains(responses[0], `"join"`) || !strings.Contains(responses[1], `"none"`) {
+		t.Fatal("回应有误", responses)
+	}
+}
+
+func TestMjaiBot(t *testing.T) {
+	debugMode = true
+
+	const tehai = `["1m","2m","3m","4m","5m","6m","7p","8p","9p","1s","1s","2s","3s"]`
+	const others = `["?","?","?","?","?","?","?","?","?","?","?","?","?"]`
+	startKyoku := `{"type":"start_kyoku","bakaze":"E","kyoku":1,"honba":0,"kyotaku":0,"oya":0,"dora_marker":"9m","tehais":[` + tehai + `,` + others + `,` + others + `,` + others + `],"scores":[25000,25000,25000,25000]}`
+
+	for _, testCase := range []struct {
+		lines    []string
+		expected mjaiMessage // 对最后一条消息的回应
+	}{
+		{
+			// 听牌后立直
+			[]string{startKyoku, `{"type":"tsumo","actor":0,"pai":"C"}`},
+			mjaiMessage{Type: "reach", Actor: 0},
+		},
+		{
+			// 立直宣言后摸切
+			[]string{startKyoku, `{"type":"tsumo","actor":0,"pai":"C"}`, `{"type":"reach","actor":0}`},
+			mjaiMessage{Type: "dahai", Actor: 0, Pai: "C", Tsumogiri: true},
+		},
+		{
+			// 自摸
+			[]string{startKyoku, `{"type":"tsumo","actor":0,"pai":"4s"}`},
+			mjaiMessage{Type: "hora", Actor: 0, Target: 0, Pai: "4s"},
+		},
+		{
+			// 荣和
+			[]string{startKyoku, `{"type":"tsumo","actor":0,"pai":"C"}`, `{"type":"dahai","actor":0,"pai":"C","tsumogiri":true}`, `{"type":"tsumo","actor":1,"pai":"?"}`, `{"type":"dahai","actor":1,"pai":"1s","tsumogiri":true}`},
+			mjaiMessage{Type: "hora", Actor: 0, Target: 1, Pai: "1s"},
+		},
+		{
+			// 舍牌振听
+			[]string{startKyoku, `{"type":"tsumo","actor":0,"pai":"1s"}`, `{"type":"dahai","actor":0,"pai":"1s","tsumogiri":true}`, `{"type":"tsumo","actor":1,"pai":"?"}`, `{"type":"dahai","actor":1,"pai":"4s","tsumogiri":true}`},
+			mjaiMessage{Type: "none"},
+		},
+	} {
+		lines := append([]string{`{"type":"start_game","id":0,"names":["a","b","c","d"]}`}, testCase.lines...)
+		out := &bytes.Buffer{}
+		runMjaiStdio(strings.NewReader(strings.Join(lines, "\n")), out)
+
+		responses := strings.Split(strings.TrimSpace(out.String()), "\n")
+		response := mjaiMessage{}
+		if err := json.Unmarshal([]byte(responses[len(responses)-1]), &response); err != nil {
+			t.Fatal(err)
+		}
+		e := testCase.expected
+		if response.Type != e.Type || response.Actor != e.Actor || response.Target != e.Target || response.Pai != e.Pai || response.Tsumogiri != e.Tsumogiri {
+			t.Fatal("回应有误", testCase.lines[len(testCase.lines)-1], responses[len(responses)-1])
+		}
+	}
+}
+
+func TestMjaiBotPon(t *testing.T) {
+	debugMode = true
+
+	lines := []string{
+		`{"type":"start_game","id":0,"names":["a","b","c","d"]}`,
+		`{"type":"start_kyoku","bakaze":"E","kyoku":1,"honba":0,"kyotaku":0,"oya":1,"dora_marker":"9m","tehais":[["2m","3m","4m","5mr","6m","6p","7p","8s","8s","P","P","9s","1p"],["?"],["?"],["?"]],"scores":[25000,25000,25000,25000]}`,
+		`{"type":"tsumo","actor":2,"pai":"?"}`,
+		`{"type":"dahai","actor":2,"pai":"P","tsumogiri":true}`,
+		`{"type":"pon","actor":0,"target":2,"pai":"P","consumed":["P","P"]}`,
+	}
+	out := &bytes.Buffer{}
+	runMjaiStdio(strings.NewReader(strings.Join(lines, "\n")), out)
+
+	responses := strings.Split(strings.TrimSpace(out.String()), "\n")
+	pon := mjaiMessage{}
+	if err := json.Unmarshal([]byte(responses[3]), &pon); err != nil {
+		t.Fatal(err)
+	}
+	if pon.Type != "pon" || pon.Target != 2 || pon.Pai != "P" || len(pon.Consumed) != 2 {
+		t.Fatal("应该碰", responses[3])
+	}
+	dahai := mjaiMessage{}
+	if err := json.Unmarshal([]byte(responses[4]), &dahai); err != nil {
+		t.Fatal(err)
+	}
+	if dahai.Type != "dahai" || dahai.Pai == "5mr" || dahai.Tsumogiri {
+		t.Fatal("碰后切牌有误", responses[4])
+	}
+}
+
+func TestMjaiDiscardOptions(t *testing.T) {
+	d := &mjaiRoundData{}
+	d.roundData = newRoundData(d, 0, 0)
+	d.msg = &mjaiMessage{Type: "dahai", Actor: 2, Pai: "C"}
+	events, err := d.translate()
+	if err != nil || len(events) != 1 {
+		t.Fatal("事件有误", events, err)
+	}
+	options := events[0].(*DiscardEvent).Options
+	// mjai 不告知能否鸣牌，仍然分析鸣牌，但不提示可以吃碰杠
+	if !options.canCall() || len(options.names()) != 0 {
+		t.Fatal("鸣牌选项有误", *options)
+	}
+}
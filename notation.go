@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"strings"
+)
+
+// 手牌的记法，用于命令行、交互模式和 POST /analysis
+// 手牌后用 & 隔开写上副露，0 表示赤5，如 2340m 55p & (406m) [5555p] {1111z}
+// 副露的括号表示副露的类型：
+//   (456m) 吃，(777z) 碰，(5555p) 大明杠
+//   [5555p] 加杠
+//   {5555p} 暗杠
+// 副露也可以不加括号，如 & 456m 777z，此时视作 () 中的副露
+
+// 副露的左括号 -> 右括号
+var meldBrackets = map[byte]byte{'(': ')', '[': ']', '{': '}'}
+
+// 解析一个副露，bracket 为副露的左括号，不加括号时为 '('
+func parseHumanMeld(bracket byte, humanMeld string) (meld model.Meld, err error) {
+	tiles, err := util.StrToTiles(humanMeld)
+	if err != nil {
+		return
+	}
+
+	r := &meldRecord{Tiles: humanMeld}
+	switch bracket {
+	case '[':
+		r.Type = meldRecordTypes[meldTypeKakan]
+	case '{':
+		r.Type = meldRecordTypes[meldTypeAnkan]
+	default:
+		switch {
+		case len(tiles) == 4:
+			r.Type = meldRecordTypes[meldTypeMinkan]
+		case len(tiles) == 3 && tiles[0] == tiles[1]:
+			r.Type = meldRecordTypes[meldTypePon]
+		default:
+			r.Type = meldRecordTypes[meldTypeChi]
+		}
+	}
+	return parseMeldRecord(r)
+}
+
+// 解析 & 之后的副露，如 (406m) [5555p] {1111z}
+func parseHumanMelds(humanMelds string) (melds []model.Meld, err error) {
+	for humanMelds = strings.TrimSpace(humanMelds); humanMelds != ""; humanMelds = strings.TrimSpace(humanMelds) {
+		bracket := humanMelds[0]
+		var humanMeld string
+		if closeBracket, ok := meldBrackets[bracket]; ok {
+			end := strings.IndexByte(humanMelds, closeBracket)
+			if end == -1 {
+				return nil, fmt.Errorf(tr("参数错误: 副露 %s 缺少 %c"), humanMelds, closeBracket)
+			}
+			humanMeld, humanMelds = humanMelds[1:end], humanMelds[end+1:]
+		} else {
+			bracket = '('
+			end := strings.IndexAny(humanMelds, " ([{")
+			if end == -1 {
+				end = len(humanMelds)
+			}
+			humanMeld, humanMelds = humanMelds[:end], humanMelds[end:]
+		}
+
+		meld, err := parseHumanMeld(bracket, strings.TrimSpace(humanMeld))
+		if err != nil {
+			return nil, err
+		}
+		melds = append(melds, meld)
+	}
+	return
+}
+
+// 解析手牌和副露，如 2340m 55p & (406m) [5555p]
+// 返回的 PlayerInfo 中赤5个数包含副露中的赤5，牌山中已去掉手牌和副露
+func parseHumanTiles(humanTiles string) (*model.PlayerInfo, error) {
+	splits := strings.Split(humanTiles, "&")
+	if len(splits) > 2 {
+		return nil, fmt.Errorf(tr("参数错误: %s 中有多个 &"), humanTiles)
+	}
+
+	tiles34, err := util.StrToTiles34(splits[0])
+	if err != nil {
+		return nil, err
+	}
+	var melds []model.Meld
+	if len(splits) == 2 {
+		if melds, err = parseHumanMelds(splits[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	playerInfo := model.NewSimplePlayerInfo(tiles34, melds)
+	playerInfo.NumRedFives = util.CountRedFives(splits[0])
+	for _, meld := range melds {
+		if meld.ContainRedFive {
+			playerInfo.NumRedFives[meld.Tiles[0]/9]++
+		}
+		for _, tile := range meld.Tiles {
+			playerInfo.LeftTiles34[tile]--
+		}
+	}
+	for i, left := range playerInfo.LeftTiles34 {
+		if left < 0 {
+			return nil, errors.New(tr("参数错误: 某种牌超过了 4 张"))
+		}
+		if i < 27 && i%9 == 4 && playerInfo.NumRedFives[i/9] > 4-left {
+			return nil, fmt.Errorf(tr("参数错误: %s 中的赤5超过了 5 的个数"), humanTiles)
+		}
+	}
+	return playerInfo, nil
+}
+
+// 手牌中各个赤5的个数，即去掉副露中的赤5
+func handRedFives(playerInfo *model.PlayerInfo) []int {
+	numRedFives := make([]int, 3)
+	copy(numRedFives, playerInfo.NumRedFives)
+	for _, meld := range playerInfo.Melds {
+		if meld.ContainRedFive {
+			numRedFives[meld.Tiles[0]/9]--
+		}
+	}
+	return numRedFives
+}
+
+// 手上的这种牌只有赤5，与 PlayerInfo.IsOnlyRedFive 不同，不计副露中的赤5
+func isOnlyRedFiveInHand(playerInfo *model.PlayerInfo, tile int) bool {
+	return tile < 27 && tile%9 == 4 && playerInfo.HandTiles34[tile] > 0 && playerInfo.HandTiles34[tile] == handRedFives(playerInfo)[tile/9]
+}
+
+// 副露的记法，如 (406m) [5555p]
+func humanMeld(meld *model.Meld) string {
+	humanTiles := util.TilesToStr(meld.Tiles)
+	if meld.ContainRedFive {
+		humanTiles = strings.Replace(humanTiles, "5", "0", 1)
+	}
+	switch meld.MeldType {
+	case meldTypeKakan:
+		return "[" + humanTiles + "]"
+	case meldTypeAnkan:
+		return "{" + humanTiles + "}"
+	default:
+		return "(" + humanTiles + ")"
+	}
+}
+
+// 手牌和副露的记法，可以原样作为输入
+// 副露按从新到旧的顺序，如 24m 55p & (777z) (406m)
+func humanTilesOfPlayer(playerInfo *model.PlayerInfo) string {
+	humanTiles := util.Tiles34ToStrWithRedFives(playerInfo.HandTiles34, handRedFives(playerInfo))
+	if len(playerInfo.Melds) > 0 {
+		humanTiles += " &"
+		for i := len(playerInfo.Melds) - 1; i >= 0; i-- {
+			humanTiles += " " + humanMeld(&playerInfo.Melds[i])
+		}
+	}
+	return humanTiles
+}
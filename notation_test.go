@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHumanTiles(t *testing.T) {
+	playerInfo, err := parseHumanTiles("2340m 55p & (406s) (777z) [6666m] {1111p}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hand := humanTilesOfPlayer(playerInfo); hand != "2340m 55p & {1111p} [6666m] (777z) (406s)" {
+		t.Fatal("手牌有误", hand)
+	}
+
+	meldTypes := []int{}
+	for _, meld := range playerInfo.Melds {
+		meldTypes = append(meldTypes, meld.MeldType)
+	}
+	if !reflect.DeepEqual(meldTypes, []int{meldTypeChi, meldTypePon, meldTypeKakan, meldTypeAnkan}) {
+		t.Fatal("副露类型有误", meldTypes)
+	}
+	if !playerInfo.Melds[0].ContainRedFive || !reflect.DeepEqual(playerInfo.NumRedFives, []int{1, 0, 1}) {
+		t.Fatal("赤5个数有误", playerInfo.NumRedFives)
+	}
+	if playerInfo.IsNaki() != true || playerInfo.LeftTiles34[5] != 0 || playerInfo.LeftTiles34[9] != 0 || playerInfo.LeftTiles34[22] != 3 {
+		t.Fatal("剩余牌有误", playerInfo.LeftTiles34)
+	}
+
+	// 不加括号的副露，以及暗杠不算鸣牌
+	playerInfo, err = parseHumanTiles("24m 55p 3478s & 777z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playerInfo.Melds) != 1 || playerInfo.Melds[0].MeldType != meldTypePon {
+		t.Fatal("副露有误", playerInfo.Melds)
+	}
+	playerInfo, err = parseHumanTiles("24m 55p 3478s & {7777z}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playerInfo.IsNaki() {
+		t.Fatal("暗杠不算鸣牌")
+	}
+
+	for _, humanTiles := range []string{
+		"24m 55p 3478s & (777z",
+		"24m 55p 3478s & (778z)",
+		"24m 55p 3478s & [456m]",
+		"24m 55p 3478s & (777z) & (888z)",
+		"24m 00p 3478s & (555p)",
+		"24m 5555p 3478s & (555p)",
+	} {
+		if _, err := parseHumanTiles(humanTiles); err == nil {
+			t.Fatal(humanTiles, "应当返回错误")
+		}
+	}
+}
+
+func TestAnalysisHumanTilesWithMelds(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r, _ := newRenderer("plain", buf)
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	playerInfo, err := analysisHumanTiles(&model.HumanTilesInfo{HumanTiles: "24m 50p 3478s & (777z)", HumanDoraTiles: "5p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playerInfo.Melds) != 1 || playerInfo.NumRedFives[1] != 1 || playerInfo.CountDora() != 3 {
+		t.Fatal("手牌有误", playerInfo)
+	}
+	if !strings.HasPrefix(buf.String(), "24m 05p 3478s & (777z)\n") {
+		t.Fatal("输出有误", buf.String())
+	}
+
+	// 鸣牌分析
+	buf.Reset()
+	if _, err := analysisHumanTiles(model.NewSimpleHumanTilesInfo("24m 55p 347s & (777z) + 0p")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "24m 55p 347s & (777z) + 0p?\n") {
+		t.Fatal("输出有误", buf.String())
+	}
+}
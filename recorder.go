@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 牌谱记录的目录，每局游戏一个 JSONL 文件，每行一个事件
+const recordDir = "records"
+
+const (
+	recordEventTypeInit      = "init"      // round 开始/重连
+	recordEventTypeDraw      = "draw"      // 自家摸牌
+	recordEventTypeDiscard   = "discard"   // 舍牌（含摸切、立直宣言牌）
+	recordEventTypeCall      = "call"      // 鸣牌（含暗杠、加杠）
+	recordEventTypeKita      = "kita"      // 拔北
+	recordEventTypeDora      = "dora"      // 杠宝牌
+	recordEventTypeWin       = "win"       // 和牌
+	recordEventTypeRyuukyoku = "ryuukyoku" // 流局
+)
+
+// 牌谱中的一个事件
+type recordEvent struct {
+	Type        string `json:"type"`
+	Time        int64  `json:"time"` // 毫秒时间戳
+	RoundNumber int    `json:"round"`
+	Dealer      int    `json:"dealer"`
+
+	// 0=自家, 1=下家, 2=对家, 3=上家
+	Who int `json:"who"`
+
+	Tile        string `json:"tile,omitempty"`
+	IsRedFive   bool   `json:"red,omitempty"`
+	IsTsumogiri bool   `json:"tsumogiri,omitempty"`
+	IsReach     bool   `json:"reach,omitempty"`
+
+	Meld *meldRecord `json:"meld,omitempty"`
+
+	// 和牌/流局
+	Whos          []int  `json:"whos,omitempty"`
+	Points        []int  `json:"points,omitempty"`
+	DeltaPoints   []int  `json:"delta_points,omitempty"`
+	RyuukyokuType string `json:"ryuukyoku_type,omitempty"`
+
+	// 和牌详情，与 Whos 一一对应
+	Wins []*winRecord `json:"wins,omitempty"`
+
+	// 事件发生后的局面
+	Snapshot *recordSnapshot `json:"snapshot"`
+
+	// 此时显示的分析结果
+	Analysis *analysisRecord `json:"analysis,omitempty"`
+
+	// 此时各家的铳率表，只在 WebSocket 推送时提供
+	Risks []*riskRecord `json:"risks,omitempty"`
+}
+
+type meldRecord struct {
+	Type           string `json:"type"` // chi/pon/ankan/minkan/kakan
+	Tiles          string `json:"tiles"`
+	CalledTile     string `json:"called_tile,omitempty"`
+	ContainRedFive bool   `json:"red,omitempty"`
+}
+
+var meldRecordTypes = []string{"chi", "pon", "ankan", "minkan", "kakan"}
+
+func newMeldRecord(meld *model.Meld) *meldRecord {
+	r := &meldRecord{
+		Type:           meldRecordTypes[meld.MeldType],
+		Tiles:          util.TilesToStr(meld.Tiles),
+		ContainRedFive: meld.ContainRedFive,
+	}
+	if meld.MeldType != meldTypeAnkan {
+		r.CalledTile = util.Mahjong[meld.CalledTile]
+	}
+	return r
+}
+
+type winYakuRecord struct {
+	Name    string `json:"name"`
+	Han     int    `json:"han,omitempty"`
+	Yakuman int    `json:"yakuman,omitempty"`
+}
+
+type winRecord struct {
+	Who      int              `json:"who"`
+	FromWho  int              `json:"from_who"` // 自摸时与 who 相同
+	Tile     string           `json:"tile,omitempty"`
+	Hand     string           `json:"hand,omitempty"` // 含和了牌，副露写在 & 之后
+	Yakus    []*winYakuRecord `json:"yakus"`
+	Han      int              `json:"han,omitempty"`
+	Fu       int              `json:"fu,omitempty"`
+	Yakuman  int              `json:"yakuman,omitempty"`
+	Dora     int              `json:"dora,omitempty"`
+	UraDora  int              `json:"ura_dora,omitempty"`
+	AkaDora  int              `json:"aka_dora,omitempty"`
+	NukiDora int              `json:"nuki_dora,omitempty"`
+	Point    int              `json:"point"`
+}
+
+func newWinRecord(result *WinResult) *winRecord {
+	r := &winRecord{
+		Who:      result.Who,
+		FromWho:  result.FromWho,
+		Tile:     result.winTileStr(),
+		Hand:     result.handStr(),
+		Han:      result.Han,
+		Fu:       result.Fu,
+		Yakuman:  result.YakumanTimes,
+		Dora:     result.Dora,
+		UraDora:  result.UraDora,
+		AkaDora:  result.AkaDora,
+		NukiDora: result.NukiDora,
+		Point:    result.Point,
+	}
+	for _, yaku := range result.Yakus {
+		r.Yakus = append(r.Yakus, &winYakuRecord{Name: yaku.displayName(), Han: yaku.Han, Yakuman: yaku.Yakuman})
+	}
+	return r
+}
+
+type recordSnapshot struct {
+	Hand           string     `json:"hand"`            // 自家手牌，副露写在 & 之后
+	Rivers         [][]string `json:"rivers"`          // 各家牌河，摸切的牌前面加上 -
+	Melds          [][]string `json:"melds"`           // 各家副露
+	NukiDoraCounts []int      `json:"nuki_dora"`       // 各家拔北数（三人麻将）
+	Reached        []bool     `json:"reached"`         // 各家是否立直
+	DoraIndicators []string   `json:"dora_indicators"` // 宝牌指示牌
+	Scores         []int      `json:"scores"`
+	Honba          int        `json:"honba"`
+	RiichiSticks   int        `json:"riichi_sticks"`
+}
+
+func (d *roundData) newRecordSnapshot() *recordSnapshot {
+	hand := util.Tiles34ToStr(d.counts)
+	selfMelds := d.players[0].melds
+	if len(selfMelds) > 0 {
+		hand += " &"
+		for i := len(selfMelds) - 1; i >= 0; i-- {
+			hand += " " + util.TilesToStr(selfMelds[i].Tiles)
+		}
+	}
+
+	s := &recordSnapshot{
+		Hand:           hand,
+		Scores:         d.game.scores,
+		Honba:          d.game.honba,
+		RiichiSticks:   d.game.riichiSticks,
+		DoraIndicators: []string{},
+	}
+	for _, indicator := range d.doraIndicators {
+		s.DoraIndicators = append(s.DoraIndicators, util.Mahjong[indicator])
+	}
+	for _, player := range d.players {
+		river := []string{}
+		for _, disTile := range player.discardTiles {
+			if disTile < 0 {
+				river = append(river, "-"+util.Mahjong[^disTile])
+			} else {
+				river = append(river, util.Mahjong[disTile])
+			}
+		}
+		s.Rivers = append(s.Rivers, river)
+
+		melds := []string{}
+		for _, meld := range player.melds {
+			melds = append(melds, util.TilesToStr(meld.Tiles))
+		}
+		s.Melds = append(s.Melds, melds)
+
+		s.NukiDoraCounts = append(s.NukiDoraCounts, player.nukiDoraCount)
+		s.Reached = append(s.Reached, player.isReached)
+	}
+	return s
+}
+
+// 何切/鸣牌的分析结果
+type analysisRecord struct {
+	Shanten int                    `json:"shanten"`
+	Choices []analysisChoiceRecord `json:"choices,omitempty"` // 按照推荐顺序
+
+	// 完整的分析结果，不记入牌谱，见 analysisResponse
+	result13            *util.Hand13AnalysisResult
+	results14           util.Hand14AnalysisResultList
+	incShantenResults14 util.Hand14AnalysisResultList
+}
+
+type analysisChoiceRecord struct {
+	Discard         string   `json:"discard,omitempty"`           // 切的牌，13 张牌时为空
+	Open            string   `json:"open,omitempty"`              // 鸣牌时用哪些牌吃/碰
+	IsBackward      bool     `json:"backward,omitempty"`          // 是否为向听倒退
+	Waits           string   `json:"waits"`                       // 进张
+	WaitsCount      int      `json:"waits_count"`                 // 进张数
+	AvgImproveWaits float64  `json:"avg_improve_waits,omitempty"` // 考虑改良的平均进张数
+	AvgAgariRate    float64  `json:"agari_rate,omitempty"`        // 听牌时的和率
+	DamaPoint       float64  `json:"dama_point,omitempty"`
+	RiichiPoint     float64  `json:"riichi_point,omitempty"`
+	FuritenRate     float64  `json:"furiten_rate,omitempty"`
+	MixedWaitsScore float64  `json:"mixed_waits_score"`
+	Yakus           []string `json:"yakus,omitempty"` // 听牌时的役种
+}
+
+func newAnalysisChoiceRecord(result13 *util.Hand13AnalysisResult) analysisChoiceRecord {
+	waitsCount, waitTiles := result13.Waits.ParseIndex()
+	return analysisChoiceRecord{
+		Waits:           util.TilesToStr(waitTiles),
+		WaitsCount:      waitsCount,
+		AvgImproveWaits: result13.AvgImproveWaitsCount,
+		AvgAgariRate:    result13.AvgAgariRate,
+		DamaPoint:       result13.DamaPoint,
+		RiichiPoint:     result13.RiichiPoint,
+		FuritenRate:     result13.FuritenRate,
+		MixedWaitsScore: result13.MixedWaitsScore,
+		Yakus:           yakuNames(result13.YakuTypes),
+	}
+}
+
+// DiscardTile 为 -1 时表示 13 张牌的分析结果
+func newAnalysisChoiceRecord14(result14 *util.Hand14AnalysisResult, isBackward bool) analysisChoiceRecord {
+	choice := newAnalysisChoiceRecord(result14.Result13)
+	if result14.DiscardTile >= 0 {
+		choice.Discard = util.Mahjong[result14.DiscardTile]
+	}
+	if len(result14.OpenTiles) > 0 {
+		choice.Open = util.TilesToStr(result14.OpenTiles)
+	}
+	choice.IsBackward = isBackward
+	return choice
+}
+
+func yakuNames(yakuTypes map[int]struct{}) (names []string) {
+	types := []int{}
+	for t := range yakuTypes {
+		types = append(types, t)
+	}
+	sort.Ints(types)
+	for _, t := range types {
+		names = append(names, yakuName(t))
+	}
+	return
+}
+
+// 他家的铳率表
+type riskRecord struct {
+	Who        int                `json:"who"`
+	TenpaiRate float64            `json:"tenpai_rate"`
+	SafeTiles  []string           `json:"safe_tiles"`
+	Risks      map[string]float64 `json:"risks"` // 自家手牌中各种牌的铳率
+}
+
+func (d *roundData) newRiskRecords(riskTables riskInfoList) []*riskRecord {
+	return riskTables.records(d.counts)
+}
+
+// hands: 自家手牌，只记录手牌中各种牌的铳率
+func (l riskInfoList) records(hands []int) (records []*riskRecord) {
+	for who, ri := range l {
+		if who == 0 {
+			continue
+		}
+		r := &riskRecord{
+			Who:        who,
+			TenpaiRate: ri.tenpaiRate,
+			SafeTiles:  []string{},
+			Risks:      map[string]float64{},
+		}
+		for tile, isSafe := range ri.safeTiles34 {
+			if isSafe {
+				r.SafeTiles = append(r.SafeTiles, util.Mahjong[tile])
+			}
+		}
+		for tile, c := range hands {
+			if c > 0 && ri.riskTable != nil {
+				r.Risks[util.Mahjong[tile]] = ri.riskTable[tile]
+			}
+		}
+		records = append(records, r)
+	}
+	return
+}
+
+func newAnalysisRecord13(result13 *util.Hand13AnalysisResult) *analysisRecord {
+	return &analysisRecord{
+		Shanten:  result13.Shanten,
+		Choices:  []analysisChoiceRecord{newAnalysisChoiceRecord(result13)},
+		result13: result13,
+	}
+}
+
+func newAnalysisRecord14(shanten int, results14 util.Hand14AnalysisResultList, incShantenResults14 util.Hand14AnalysisResultList) *analysisRecord {
+	r := &analysisRecord{Shanten: shanten, results14: results14, incShantenResults14: incShantenResults14}
+	appendChoices := func(results util.Hand14AnalysisResultList, isBackward bool) {
+		for _, result := range results {
+			r.Choices = append(r.Choices, newAnalysisChoiceRecord14(result, isBackward))
+		}
+	}
+	appendChoices(results14, false)
+	appendChoices(incShantenResults14, true)
+	return r
+}
+
+//
+
+type gameRecorder struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newGameRecorder(dataSourceType int) (*gameRecorder, error) {
+	if err := os.MkdirAll(recordDir, 0755); err != nil {
+		return nil, err
+	}
+	sourceName := dataSourceNames[dataSourceType]
+	fileName := fmt.Sprintf("%s-%s.jsonl", sourceName, time.Now().Format("20060102-150405"))
+	file, err := os.OpenFile(filepath.Join(recordDir, fileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &gameRecorder{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (r *gameRecorder) write(event *recordEvent) error {
+	return r.encoder.Encode(event)
+}
+
+func (r *gameRecorder) close() error {
+	return r.file.Close()
+}
+
+// 游戏开始时换一个新的牌谱文件
+func (d *roundData) closeRecorder() {
+	if d.recorder == nil {
+		return
+	}
+	if err := d.recorder.close(); err != nil {
+		fmt.Println(tr("关闭牌谱文件失败:"), err)
+	}
+	d.recorder = nil
+}
+
+// 补充事件的局面等信息，写入牌谱文件，并推送给 WebSocket 的订阅者
+func (d *roundData) recordEvent(event *recordEvent) {
+	isPushing := isPushingAnalysis()
+	if !recordGame && !isPushing {
+		return
+	}
+
+	event.Time = time.Now().UnixNano() / int64(time.Millisecond)
+	event.RoundNumber = d.roundNumber
+	event.Dealer = d.dealer
+	if event.Type == recordEventTypeDiscard {
+		player := d.players[event.Who]
+		event.IsReach = player.reachTileAt != -1 && player.reachTileAt == len(player.discardTiles)-1
+	}
+	event.Snapshot = d.newRecordSnapshot()
+
+	if isPushing {
+		if data, err := json.Marshal(event); err == nil {
+			analysisPusher.broadcast(data)
+		} else {
+			fmt.Println(tr("推送分析结果失败:"), err)
+		}
+	}
+
+	// 铳率表只用于推送，不写入牌谱
+	event.Risks = nil
+	if !recordGame {
+		return
+	}
+
+	if d.recorder == nil {
+		recorder, err := newGameRecorder(d.parser.GetDataSourceType())
+		if err != nil {
+			fmt.Println(tr("创建牌谱文件失败:"), err)
+			return
+		}
+		d.recorder = recorder
+	}
+
+	if err := d.recorder.write(event); err != nil {
+		fmt.Println(tr("写入牌谱失败:"), err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordEvent(t *testing.T) {
+	debugMode = true
+	recordGame = true
+	defer func() { recordGame = false }()
+
+	wd, _ := os.Getwd()
+	dir, err := ioutil.TempDir("", "mahjong-helper-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, msg := range []*tenhouMessage{
+		{Tag: "GO", Type: "9"},
+		{Tag: "INIT", Seed: "0,0,0,1,2,36", Ten: "250,250,250,250", Dealer: "0", Hai: "0,4,8,48,53,56,96,100,104,108,109,110,89"},
+		{Tag: "T112"},
+		{Tag: "D112"},
+		{Tag: "F91"},
+	} {
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.closeRecorder()
+
+	files, _ := filepath.Glob(filepath.Join(recordDir, "tenhou-*.jsonl"))
+	if len(files) != 1 {
+		t.Fatal("牌谱文件数量有误", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events := []*recordEvent{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		event := &recordEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	types := []string{recordEventTypeInit, recordEventTypeDraw, recordEventTypeDiscard, recordEventTypeDiscard}
+	if len(events) != len(types) {
+		t.Fatal("事件数量有误", len(events))
+	}
+	for i, event := range events {
+		if event.Type != types[i] {
+			t.Fatal("事件类型有误", i, event.Type)
+		}
+	}
+	if events[1].Analysis == nil || len(events[1].Analysis.Choices) == 0 {
+		t.Fatal("摸牌事件缺少何切分析")
+	}
+	if events[3].Who != 2 || events[3].Snapshot.Rivers[2][0] != "5s" {
+		t.Fatal("舍牌事件有误", *events[3].Snapshot)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/fatih/color"
+	"html"
+	"io"
+	"os"
+	"strings"
+)
+
+// 分析结果的输出
+// 牌河、铳率表、何切分析、开局信息、和牌/流局结果等不直接打印，而是交给 outputRenderer 输出，便于重定向或供其他程序读取
+// 可以通过 -render=terminal/plain/json/html/tui 或配置文件中的 renderer 选择输出格式，默认为带颜色的终端输出
+
+type renderer interface {
+	// 他家的牌河，players[0] 为自家，不输出
+	renderDiscards(players []*playerInfo)
+
+	// 手牌中各种牌对各家的危险度
+	renderRisks(riskTables riskInfoList, hands []int, leftCounts []int)
+
+	// 何切/鸣牌分析的结果
+	renderAnalysis(output *analysisOutput)
+
+	// 提示信息，attrs 为提示的颜色，为空时不着色
+	renderMessage(msg string, attrs ...color.Attribute)
+
+	// 由多行或多种颜色组成的文字，如开局信息、和牌/流局的结果，kind 为 round/win/draw/message
+	renderText(kind string, text *styledText)
+}
+
+var rendererNames = []string{"terminal", "plain", "json", "html", "tui"}
+
+// 由 main 根据参数和配置设置，默认为带颜色的终端输出
+var outputRenderer renderer = &textRenderer{w: terminalWriter{}}
+
+func newRenderer(name string, out io.Writer) (renderer, error) {
+	switch name {
+	case "", "terminal":
+		return &textRenderer{w: terminalWriter{}}, nil
+	case "plain":
+		return &textRenderer{w: plainWriter{out}}, nil
+	case "json":
+		return &jsonRenderer{json.NewEncoder(out)}, nil
+	case "html":
+		return &textRenderer{w: &htmlWriter{out: out}}, nil
+	case "tui":
+		if out == os.Stdout {
+			// 在 Windows 上需要 color.Output 来转换 ANSI 转义序列
+			out = color.Output
+		}
+		return newTUIRenderer(out), nil
+	}
+	return nil, fmt.Errorf(tr("未知的输出格式 %s，可选 %s"), name, strings.Join(rendererNames, "/"))
+}
+
+//
+
+// 何切分析中按顺序输出的内容：一组选项或一条提示
+type analysisItem interface {
+	isAnalysisItem()
+}
+
+// 一组何切选项，如「一向听：」下的各个切牌
+type analysisSection struct {
+	title      string                        // 如 一向听、鸣牌后两向听
+	isBackward bool                          // 是否为向听倒退
+	results    util.Hand14AnalysisResultList // 13 张牌时只有一个选项，DiscardTile 为 -1
+}
+
+// 何切分析中的提示，如【已胡牌】、建议向听倒退
+type analysisAlert struct {
+	msg  string
+	attr color.Attribute
+}
+
+func (*analysisSection) isAnalysisItem() {}
+func (*analysisAlert) isAnalysisItem()   {}
+
+type analysisOutput struct {
+	// 手牌，如 24m 55p & 777z，鸣牌分析时为 24m 55p + 3m?
+	hand string
+
+	items []analysisItem
+
+	// 各种牌的综合危险度，用于标出舍牌的危险度，可以为 nil
+	mixedRiskTable riskTable
+}
+
+func (o *analysisOutput) addSection(title string, isBackward bool, results util.Hand14AnalysisResultList) {
+	o.items = append(o.items, &analysisSection{title: title, isBackward: isBackward, results: results})
+}
+
+func (o *analysisOutput) addAlert(attr color.Attribute, msg string) {
+	o.items = append(o.items, &analysisAlert{msg: msg, attr: attr})
+}
+
+// 收集起来的带颜色的文字，交给 renderer.renderText 输出
+type styledText struct {
+	segments []styledSegment
+}
+
+type styledSegment struct {
+	text  string
+	attrs []color.Attribute
+}
+
+func (t *styledText) begin(kind string) {}
+
+func (t *styledText) print(text string, attrs ...color.Attribute) {
+	t.segments = append(t.segments, styledSegment{text, attrs})
+}
+
+func (t *styledText) end() {}
+
+func (t *styledText) writeTo(w styledWriter) {
+	for _, segment := range t.segments {
+		w.print(segment.text, segment.attrs...)
+	}
+}
+
+// 不含颜色的各行文字，去掉末尾的空行
+func (t *styledText) lines() []string {
+	text := ""
+	for _, segment := range t.segments {
+		text += segment.text
+	}
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+//
+
+// 文本输出，终端、纯文本和 HTML 的区别仅在于如何输出带颜色的文字
+type styledWriter interface {
+	// 开始输出一块内容，kind 为 discards/risks/analysis/message
+	begin(kind string)
+	print(text string, attrs ...color.Attribute)
+	end()
+}
+
+type textRenderer struct {
+	w styledWriter
+}
+
+func (r *textRenderer) renderDiscards(players []*playerInfo) {
+	r.w.begin("discards")
+	for i := len(players) - 1; i >= 1; i-- {
+		players[i].printDiscards(r.w)
+	}
+	r.w.print("\n")
+	r.w.end()
+}
+
+func (r *textRenderer) renderRisks(riskTables riskInfoList, hands []int, leftCounts []int) {
+	r.w.begin("risks")
+	riskTables.printWithHands(r.w, hands, leftCounts)
+	r.w.end()
+}
+
+func (r *textRenderer) renderAnalysis(output *analysisOutput) {
+	r.w.begin("analysis")
+	r.w.print(output.hand + "\n")
+	r.w.print(strings.Repeat("=", len(output.hand)) + "\n")
+	for _, item := range output.items {
+		switch item := item.(type) {
+		case *analysisSection:
+			r.w.print(trf("%s：", item.title) + "\n")
+			for _, result := range item.results {
+				printWaitsWithImproves13_oneRow(r.w, result.Result13, result.DiscardTile, result.OpenTiles, output.mixedRiskTable)
+			}
+		case *analysisAlert:
+			r.w.print(item.msg, item.attr)
+			r.w.print("\n")
+		}
+	}
+	r.w.print("\n")
+	r.w.end()
+}
+
+func (r *textRenderer) renderMessage(msg string, attrs ...color.Attribute) {
+	r.w.begin("message")
+	r.w.print(msg, attrs...)
+	r.w.print("\n")
+	r.w.end()
+}
+
+func (r *textRenderer) renderText(kind string, text *styledText) {
+	r.w.begin(kind)
+	text.writeTo(r.w)
+	r.w.end()
+}
+
+// 带颜色的终端输出
+// 输出至 color.Output，在 Windows 上也能正确显示颜色；输出不是终端时 color 会自动去掉颜色
+type terminalWriter struct{}
+
+func (terminalWriter) begin(kind string) {}
+
+func (terminalWriter) print(text string, attrs ...color.Attribute) {
+	if len(attrs) == 0 {
+		fmt.Fprint(color.Output, text)
+		return
+	}
+	color.New(attrs...).Fprint(color.Output, text)
+}
+
+func (terminalWriter) end() {}
+
+// 不含 ANSI 转义序列的纯文本输出
+type plainWriter struct {
+	out io.Writer
+}
+
+func (plainWriter) begin(kind string) {}
+
+func (w plainWriter) print(text string, attrs ...color.Attribute) {
+	fmt.Fprint(w.out, text)
+}
+
+func (plainWriter) end() {}
+
+// HTML 输出，每块内容为一个 <pre>，颜色用内联样式表示，便于嵌入其他页面
+type htmlWriter struct {
+	out io.Writer
+}
+
+// 与终端的配色一致
+var (
+	htmlColors   = []string{"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5"}
+	htmlHiColors = []string{"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff"}
+)
+
+func htmlStyle(attrs []color.Attribute) string {
+	styles := []string{}
+	for _, attr := range attrs {
+		switch {
+		case attr == color.Bold:
+			styles = append(styles, "font-weight:bold")
+		case attr >= color.FgBlack && attr <= color.FgWhite:
+			styles = append(styles, "color:"+htmlColors[attr-color.FgBlack])
+		case attr >= color.FgHiBlack && attr <= color.FgHiWhite:
+			styles = append(styles, "color:"+htmlHiColors[attr-color.FgHiBlack])
+		case attr >= color.BgBlack && attr <= color.BgWhite:
+			styles = append(styles, "background:"+htmlColors[attr-color.BgBlack])
+		case attr >= color.BgHiBlack && attr <= color.BgHiWhite:
+			styles = append(styles, "background:"+htmlHiColors[attr-color.BgHiBlack])
+		}
+	}
+	return strings.Join(styles, ";")
+}
+
+func (w *htmlWriter) begin(kind string) {
+	fmt.Fprintf(w.out, `<pre class="mahjong-helper-%s" style="background:#000000;color:#e5e5e5">`, kind)
+}
+
+func (w *htmlWriter) print(text string, attrs ...color.Attribute) {
+	text = html.EscapeString(text)
+	if style := htmlStyle(attrs); style != "" {
+		text = `<span style="` + style + `">` + text + `</span>`
+	}
+	fmt.Fprint(w.out, text)
+}
+
+func (w *htmlWriter) end() {
+	fmt.Fprintln(w.out, "</pre>")
+}
+
+//
+
+// JSON 输出，每次输出一行 JSON
+type jsonRenderer struct {
+	encoder *json.Encoder
+}
+
+type riverRecord struct {
+	Who            int      `json:"who"`
+	Name           string   `json:"name"`
+	Tiles          []string `json:"tiles"`
+	TsumogiriAt    []int    `json:"tsumogiri_at,omitempty"`     // 摸切的舍牌的下标
+	MeldDiscardsAt []int    `json:"meld_discards_at,omitempty"` // 鸣牌后的舍牌的下标
+	ReachTileAt    int      `json:"reach_tile_at"`              // 立直宣言牌的下标，未立直时为 -1
+}
+
+type analysisSectionRecord struct {
+	Title   string                 `json:"title"`
+	Choices []analysisChoiceRecord `json:"choices"`
+}
+
+type renderRecord struct {
+	Type string `json:"type"` // discards/risks/analysis/round/win/draw/message
+
+	Rivers []*riverRecord `json:"rivers,omitempty"`
+
+	Risks []*riskRecord `json:"risks,omitempty"`
+
+	Hand     string                   `json:"hand,omitempty"`
+	Sections []*analysisSectionRecord `json:"sections,omitempty"`
+
+	// 何切分析中的提示，单独的提示信息，或者开局信息、和牌/流局结果的各行文字
+	Messages []string `json:"messages,omitempty"`
+}
+
+func (r *jsonRenderer) encode(record *renderRecord) {
+	if err := r.encoder.Encode(record); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (r *jsonRenderer) renderDiscards(players []*playerInfo) {
+	record := &renderRecord{Type: "discards"}
+	for who, player := range players[1:] {
+		river := &riverRecord{Who: who + 1, Name: player.name, Tiles: []string{}, MeldDiscardsAt: player.meldDiscardsAt, ReachTileAt: player.reachTileAt}
+		for i, tile := range player.discardTiles {
+			if tile < 0 {
+				tile = ^tile
+				river.TsumogiriAt = append(river.TsumogiriAt, i)
+			}
+			river.Tiles = append(river.Tiles, util.Mahjong[tile])
+		}
+		record.Rivers = append(record.Rivers, river)
+	}
+	r.encode(record)
+}
+
+func (r *jsonRenderer) renderRisks(riskTables riskInfoList, hands []int, leftCounts []int) {
+	r.encode(&renderRecord{Type: "risks", Risks: riskTables.records(hands)})
+}
+
+func (r *jsonRenderer) renderAnalysis(output *analysisOutput) {
+	record := &renderRecord{Type: "analysis", Hand: output.hand}
+	for _, item := range output.items {
+		switch item := item.(type) {
+		case *analysisSection:
+			section := &analysisSectionRecord{Title: item.title}
+			for _, result := range item.results {
+				section.Choices = append(section.Choices, newAnalysisChoiceRecord14(result, item.isBackward))
+			}
+			record.Sections = append(record.Sections, section)
+		case *analysisAlert:
+			record.Messages = append(record.Messages, item.msg)
+		}
+	}
+	r.encode(record)
+}
+
+func (r *jsonRenderer) renderMessage(msg string, attrs ...color.Attribute) {
+	r.encode(&renderRecord{Type: "message", Messages: []string{msg}})
+}
+
+func (r *jsonRenderer) renderText(kind string, text *styledText) {
+	r.encode(&renderRecord{Type: kind, Messages: text.lines()})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"github.com/fatih/color"
+	"strings"
+	"testing"
+)
+
+func renderAnalysis(t *testing.T, name string, humanTiles string) string {
+	buf := &bytes.Buffer{}
+	r, err := newRenderer(name, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	playerInfo := model.NewSimplePlayerInfo(util.MustStrToTiles34(humanTiles), nil)
+	if _, err := analysisTiles34(playerInfo, nil); err != nil {
+		t.Fatal(err)
+	}
+	outputRenderer.renderMessage("振听", color.FgHiYellow)
+	return buf.String()
+}
+
+func TestPlainRenderer(t *testing.T) {
+	out := renderAnalysis(t, "plain", "24m 55p 3456789s 115z")
+	if strings.Contains(out, "\x1b[") {
+		t.Fatal("不应含有 ANSI 转义序列", out)
+	}
+	if !strings.HasPrefix(out, "24m 55p 3456789s 115z\n") || !strings.Contains(out, "切") || !strings.HasSuffix(out, "振听\n") {
+		t.Fatal("输出有误", out)
+	}
+}
+
+func TestJSONRenderer(t *testing.T) {
+	out := renderAnalysis(t, "json", "24m 55p 3456789s 115z")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatal("应当输出两行", out)
+	}
+
+	record := &renderRecord{}
+	if err := json.Unmarshal([]byte(lines[0]), record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Type != "analysis" || record.Hand != "24m 55p 3456789s 115z" || len(record.Sections) == 0 || len(record.Sections[0].Choices) == 0 {
+		t.Fatal("分析结果有误", lines[0])
+	}
+	if choice := record.Sections[0].Choices[0]; choice.Discard == "" || choice.WaitsCount == 0 {
+		t.Fatal("选项有误", lines[0])
+	}
+
+	record = &renderRecord{}
+	if err := json.Unmarshal([]byte(lines[1]), record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Type != "message" || len(record.Messages) != 1 || record.Messages[0] != "振听" {
+		t.Fatal("提示信息有误", lines[1])
+	}
+}
+
+func TestJSONRendererRoundEvents(t *testing.T) {
+	buf := &bytes.Buffer{}
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = &jsonRenderer{json.NewEncoder(buf)}
+
+	d := newRoundData(nil, 0, 1)
+	events := []Event{
+		&InitEvent{Dealer: 1, DoraIndicators: []int{2}, HandTiles: util.MustStrToTiles("24m 55p 3456789s 11z"), NumRedFives: []int{0, 1, 0}, Scores: []int{25000, 25000, 25000, 25000}},
+		&DiscardEvent{Who: 1, Tile: 27},
+		&DoraEvent{Indicator: 10},
+		&DrawGameEvent{Type: ryuukyokuTypeExhaustive, TenpaiWhos: []int{0}, DeltaPoints: []int{3000, -1000, -1000, -1000}},
+	}
+	for _, event := range events {
+		if err := d.handleEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 开局信息、杠宝牌和流局结果同样输出为 JSON，不含直接打印的文字
+	types := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := &renderRecord{}
+		if err := json.Unmarshal([]byte(line), record); err != nil {
+			t.Fatal("不是 JSON", line)
+		}
+		types = append(types, record.Type)
+		if record.Type == "draw" && (len(record.Messages) < 3 || !strings.Contains(record.Messages[len(record.Messages)-1], "28000")) {
+			t.Fatal("流局结果有误", line)
+		}
+	}
+	if types[0] != "round" || types[len(types)-1] != "draw" {
+		t.Fatal("输出有误", types)
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	out := renderAnalysis(t, "html", "24m 55p 3456789s 115z")
+	if strings.Count(out, "<pre") != 2 || strings.Count(out, "</pre>") != 2 {
+		t.Fatal("每块内容应为一个 pre", out)
+	}
+	if !strings.Contains(out, `<span style="color:#ffff00">振听</span>`) {
+		t.Fatal("颜色有误", out)
+	}
+
+	buf := &bytes.Buffer{}
+	w := &htmlWriter{out: buf}
+	w.print("<&>", color.BgWhite, color.FgBlack)
+	if s := buf.String(); s != `<span style="background:#e5e5e5;color:#000000">&lt;&amp;&gt;</span>` {
+		t.Fatal("转义有误", s)
+	}
+}
+
+func TestNewRenderer(t *testing.T) {
+	if _, err := newRenderer("xml", nil); err == nil {
+		t.Fatal("应当返回错误")
+	}
+}
+
+func TestTUIRenderer(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	buf := &bytes.Buffer{}
+	r := newTUIRenderer(buf)
+	defer func(r renderer) { outputRenderer = r }(outputRenderer)
+	outputRenderer = r
+
+	// 下家亲，对家立直，上家碰，然后自家摸牌
+	d := newRoundData(nil, 0, 1)
+	events := []Event{
+		&InitEvent{Dealer: 1, DoraIndicators: []int{2}, HandTiles: util.MustStrToTiles("24m 55p 3456789s 11z"), NumRedFives: []int{0, 1, 0}},
+		&DiscardEvent{Who: 1, Tile: 27},
+		&DiscardEvent{Who: 2, Tile: 33, IsReach: true},
+		&CallEvent{Who: 3, Meld: &model.Meld{MeldType: meldTypePon, Tiles: []int{33, 33, 33}, SelfTiles: []int{33, 33}, CalledTile: 33}},
+		&DiscardEvent{Who: 3, Tile: 0},
+		&DrawEvent{Tile: 3},
+	}
+	for _, event := range events {
+		if err := d.handleEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := buf.String()
+	if strings.Count(out, "\x1b[2J") != 1 || !strings.Contains(out, "\x1b[s\x1b[H") {
+		t.Fatal("应当只清屏一次，之后原地重绘", out)
+	}
+
+	// 最后一次重绘的内容
+	frame := out[strings.LastIndex(out, "\x1b[H")+len("\x1b[H"):]
+	frame = strings.TrimSuffix(frame, "\x1b[u")
+	lines := strings.Split(strings.TrimSuffix(frame, "\x1b[K\n"), "\x1b[K\n")
+	if len(lines) != r.height {
+		t.Fatal("界面行数有误", len(lines), r.height)
+	}
+	for _, s := range []string{"宝牌指示牌 3万  宝牌 4万(2)  牌山剩余 67 张", "对家: [7Z]", "上家: 1m | (777z)", "手牌: 244m 05p 3456789s 11z", "[听牌率100%]", "=====\x1b[K\n"} {
+		if !strings.Contains(frame, s) {
+			t.Fatal("缺少", s, frame)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 离线重放：读取 gamedata.log 或原始 JSONL 消息，按照收到的顺序重新交给天凤/雀魂/mjai 的解析器
+// 雀魂的原始 WebSocket 帧（每行一帧的十六进制，如 Wireshark 导出的 hex stream）会先解码成 JSON 消息，见 majsoul_raw.go
+// 天凤牌谱（mjlog）会先转换成网页版天凤的消息，见 tenhou_mjlog.go
+// 雀魂牌谱会先转换成雀魂的消息，见 majsoul_paipu.go
+
+// 重放中的一条消息
+type replayMessage struct {
+	lo   int // 在文件中的行号
+	data []byte
+
+	// 逐步重放到该消息时额外显示的信息，如牌谱中对手的手牌
+	note string
+}
+
+// 读取消息
+// gamedata.log 每行为 echo 的日志，其 message 字段为收到的消息
+// 原始 JSONL 每行即为一条消息
+func loadReplayMessages(path string) (messages []*replayMessage, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	logLine := struct {
+		Message string `json:"message"`
+	}{}
+
+	var frameDecoder *majsoulFrameDecoder
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for lo := 1; scanner.Scan(); lo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if frame, ok := parseHexFrame(line); ok {
+			if frameDecoder == nil {
+				frameDecoder = newMajsoulFrameDecoder()
+			}
+			msg, err := frameDecoder.decode(frame)
+			if err != nil {
+				return nil, fmt.Errorf(tr("第 %d 行 雀魂帧解码失败：%v"), lo, err)
+			}
+			if msg != nil {
+				messages = append(messages, &replayMessage{lo: lo, data: msg})
+			}
+			continue
+		}
+		if line == "" || line[0] != '{' {
+			continue
+		}
+
+		logLine.Message = ""
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil {
+			continue
+		}
+		msg := line
+		if logLine.Message != "" {
+			msg = strings.TrimSpace(logLine.Message)
+		}
+		// 跳过日志中的分隔线、"服务启动"等非消息内容
+		if msg == "" || msg[0] != '{' {
+			continue
+		}
+
+		messages = append(messages, &replayMessage{lo: lo, data: []byte(msg)})
+	}
+	err = scanner.Err()
+	return
+}
+
+// 十六进制的雀魂原始帧，首字节为 01/02/03
+func parseHexFrame(line string) (frame []byte, ok bool) {
+	line = strings.Replace(line, " ", "", -1)
+	if len(line) < 2 || line[0] != '0' || line[1] < '1' || line[1] > '3' {
+		return
+	}
+	frame, err := hex.DecodeString(line)
+	return frame, err == nil
+}
+
+// 天凤的消息均带有 tag 字段，mjai 的消息均带有字符串类型的 type 字段，据此判断数据来源
+func detectReplayDataSourceType(messages []*replayMessage) int {
+	for _, msg := range messages {
+		d := tenhouMessage{}
+		if err := json.Unmarshal(msg.data, &d); err == nil && d.Tag != "" {
+			return dataSourceTypeTenhou
+		}
+	}
+	for _, msg := range messages {
+		d := mjaiMessage{}
+		if err := json.Unmarshal(msg.data, &d); err == nil && d.Type != "" {
+			return dataSourceTypeMjai
+		}
+	}
+	return dataSourceTypeMajsoul
+}
+
+// 重放中的一局
+type replayRound struct {
+	name  string
+	start int // 该局 INIT 消息的下标
+}
+
+type replayer struct {
+	dataSourceType int
+	messages       []*replayMessage
+
+	h *mjHandler
+
+	// 已处理的消息数
+	pos int
+
+	// 每一步停在哪条消息上（摸牌、舍牌、鸣牌、和牌等），以及各局的开始位置
+	// 在首次完整重放时收集
+	steps  []int
+	rounds []replayRound
+}
+
+// seat 为重放天凤牌谱（mjlog）或雀魂牌谱时以哪个座位为自家，其余格式忽略该值
+func newReplayer(path string, seat int) (*replayer, error) {
+	data, isMjlog, err := readMjlogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var messages []*replayMessage
+	if isMjlog {
+		messages, err = loadMjlogMessages(data, seat)
+	} else if paipu, isPaipu, _err := parseMajsoulPaipu(data); isPaipu {
+		if err = _err; err == nil {
+			messages, err = loadMajsoulPaipuMessages(paipu, seat)
+		}
+	} else {
+		messages, err = loadReplayMessages(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf(tr("%s 中没有可以重放的消息"), path)
+	}
+	r := &replayer{
+		dataSourceType: detectReplayDataSourceType(messages),
+		messages:       messages,
+	}
+	r.reset()
+	return r, nil
+}
+
+// 重新开始，各项数据与刚启动服务时一致
+func (r *replayer) reset() {
+	r.h = &mjHandler{
+		tenhouRoundData:  &tenhouRoundData{isRoundEnd: true},
+		majsoulRoundData: &majsoulRoundData{accountID: gameConf.MajsoulAccountID},
+		mjaiRoundData:    &mjaiRoundData{},
+	}
+	r.h.tenhouRoundData.roundData = newRoundData(r.h.tenhouRoundData, 0, 0)
+	r.h.majsoulRoundData.roundData = newRoundData(r.h.majsoulRoundData, 0, 0)
+	r.h.mjaiRoundData.roundData = newRoundData(r.h.mjaiRoundData, 0, 0)
+	r.pos = 0
+}
+
+func (r *replayer) roundData() *roundData {
+	switch r.dataSourceType {
+	case dataSourceTypeTenhou:
+		return r.h.tenhouRoundData.roundData
+	case dataSourceTypeMjai:
+		return r.h.mjaiRoundData.roundData
+	default:
+		return r.h.majsoulRoundData.roundData
+	}
+}
+
+// 处理下一条消息，返回该消息是否为一步
+func (r *replayer) next(skipOutput bool) (isStep bool, isInit bool, err error) {
+	msg := r.messages[r.pos]
+	r.pos++
+
+	rd := r.roundData()
+	rd.skipOutput = skipOutput
+	rd.handledEvents = nil
+	defer func() { r.roundData().skipOutput = false }()
+
+	switch r.dataSourceType {
+	case dataSourceTypeTenhou:
+		d := tenhouMessage{}
+		if err = json.Unmarshal(msg.data, &d); err != nil {
+			return
+		}
+		err = r.h.handleTenhouMessage(&d, msg.data)
+	case dataSourceTypeMajsoul:
+		d := majsoulMessage{}
+		if err = json.Unmarshal(msg.data, &d); err != nil {
+			return
+		}
+		err = r.h.handleMajsoulMessage(&d, msg.data)
+	case dataSourceTypeMjai:
+		d := mjaiMessage{}
+		if err = json.Unmarshal(msg.data, &d); err != nil {
+			return
+		}
+		err = r.h.handleMjaiMessage(&d, msg.data)
+	default:
+		panic("not impl!")
+	}
+
+	// 根据处理了的事件判断该消息是否改变了局面
+	for _, event := range r.roundData().handledEvents {
+		switch event.(type) {
+		case *InitEvent:
+			isInit = true
+			isStep = true
+		case *DrawEvent, *DiscardEvent, *CallEvent, *KitaEvent, *WinEvent, *DrawGameEvent:
+			isStep = true
+		}
+	}
+	return
+}
+
+// 首次完整重放，收集每一步和各局的位置
+// batch 为 true 时正常输出分析结果，否则静默
+// 返回出错的消息数
+func (r *replayer) runAll(batch bool) (errorCount int) {
+	r.reset()
+	r.steps = nil
+	r.rounds = nil
+	for r.pos < len(r.messages) {
+		lo := r.messages[r.pos].lo
+		isStep, isInit, err := r.next(!batch)
+		if err != nil {
+			errorCount++
+			fmt.Printf(tr("第 %d 行 错误：%v\n"), lo, err)
+		}
+		if isStep {
+			r.steps = append(r.steps, r.pos-1)
+		}
+		if isInit {
+			if rd := r.roundData(); len(rd.players) > 0 {
+				name := trf("%s%d局 %d本场", tileName(rd.roundWindTile), rd.roundNumber%4+1, rd.game.honba)
+				r.rounds = append(r.rounds, replayRound{name: name, start: r.pos - 1})
+			}
+		}
+	}
+	return
+}
+
+// 重放到第 step 步（从 0 开始），之前的消息静默处理
+func (r *replayer) seek(step int) {
+	target := r.steps[step]
+	if target < r.pos {
+		r.reset()
+	}
+	for r.pos < target {
+		if _, _, err := r.next(true); err != nil && debugMode {
+			fmt.Println(tr("错误："), err)
+		}
+	}
+	note := r.messages[r.pos].note
+	if _, _, err := r.next(false); err != nil {
+		fmt.Println(tr("错误："), err)
+	}
+	if note != "" {
+		fmt.Println(note)
+	}
+}
+
+// 当前处于第几步，-1 表示尚未开始
+func (r *replayer) currentStep() int {
+	step := -1
+	for i, pos := range r.steps {
+		if pos < r.pos {
+			step = i
+		}
+	}
+	return step
+}
+
+func (r *replayer) stepOfMessage(pos int) int {
+	for i, p := range r.steps {
+		if p >= pos {
+			return i
+		}
+	}
+	return len(r.steps) - 1
+}
+
+func (r *replayer) printRounds() {
+	for i, round := range r.rounds {
+		fmt.Printf(tr("%3d: %s（第 %d 行）\n"), i+1, round.name, r.messages[round.start].lo)
+	}
+}
+
+func printReplayHelp() {
+	fmt.Println(tr("回车/n: 下一步  b: 上一步  r <局>: 跳到第几局  l: 局列表  c: 重放到结束  q: 退出"))
+}
+
+// 交互式重放
+func (r *replayer) interact() {
+	printReplayHelp()
+	r.printRounds()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		step := r.currentStep()
+		fmt.Printf("[%d/%d] > ", step+1, len(r.steps))
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		cmd := ""
+		if len(fields) > 0 {
+			cmd = fields[0]
+		}
+
+		switch cmd {
+		case "", "n":
+			if step+1 >= len(r.steps) {
+				color.HiYellow(tr("已经是最后一步"))
+				continue
+			}
+			r.seek(step + 1)
+		case "b":
+			if step <= 0 {
+				color.HiYellow(tr("已经是第一步"))
+				continue
+			}
+			r.seek(step - 1)
+		case "r":
+			if len(fields) < 2 {
+				r.printRounds()
+				continue
+			}
+			roundIndex, err := strconv.Atoi(fields[1])
+			if err != nil || roundIndex < 1 || roundIndex > len(r.rounds) {
+				fmt.Fprintln(os.Stderr, tr("局数有误"))
+				continue
+			}
+			r.seek(r.stepOfMessage(r.rounds[roundIndex-1].start))
+		case "l":
+			r.printRounds()
+		case "c":
+			for step := step + 1; step < len(r.steps); step++ {
+				r.seek(step)
+			}
+		case "q":
+			return
+		default:
+			printReplayHelp()
+		}
+	}
+}
+
+// 重放 gamedata.log、原始 JSONL、天凤牌谱（mjlog）或雀魂牌谱
+// batch 为 true 时一次性重放所有消息并统计错误，用于回归检查
+func runReplay(path string, batch bool, seat int) {
+	r, err := newReplayer(path, seat)
+	if err != nil {
+		errorExit(err)
+	}
+
+	if batch {
+		errorCount := r.runAll(true)
+		fmt.Printf(tr("重放完成：共 %d 条消息，%d 局，%d 个错误\n"), len(r.messages), len(r.rounds), errorCount)
+		if errorCount > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 逐步重放时会反复处理同一条消息，所以不记录牌谱
+	recordGame = false
+
+	if errorCount := r.runAll(false); errorCount > 0 {
+		color.HiYellow(tr("共有 %d 条消息解析出错"), errorCount)
+	}
+	if len(r.steps) == 0 {
+		errorExit(fmt.Errorf(tr("%s 中没有可以重放的对局"), path))
+	}
+	r.reset()
+	r.interact()
+}
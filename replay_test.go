@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestReplayer(t *testing.T) {
+	debugMode = true
+
+	messages := []string{
+		`{"tag":"HELO","uname":"NoName"}`,
+		`{"tag":"GO","type":"9"}`,
+		`{"tag":"INIT","seed":"0,0,0,1,2,36","ten":"250,250,250,250","oya":"0","hai":"0,4,8,48,53,56,96,100,104,108,109,110,89"}`,
+		`{"tag":"T112"}`,
+		`{"tag":"D112"}`,
+		`{"tag":"E91"}`,
+		`{"tag":"F92"}`,
+		`{"tag":"G93"}`,
+		`{"tag":"T113"}`,
+		`{"tag":"D0"}`,
+	}
+	// gamedata.log 格式
+	logData := `{"time":"2019-01-01T00:00:00+08:00","level":"INFO","message":"============"}` + "\n"
+	logData += `{"time":"2019-01-01T00:00:00+08:00","level":"INFO","message":"服务启动"}` + "\n"
+	for _, msg := range messages {
+		line, _ := json.Marshal(map[string]string{"level": "INFO", "message": msg})
+		logData += string(line) + "\n"
+	}
+
+	file, err := ioutil.TempFile("", "gamedata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(logData)
+	file.Close()
+
+	r, err := newReplayer(file.Name(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.dataSourceType != dataSourceTypeTenhou {
+		t.Fatal("数据来源有误")
+	}
+	if len(r.messages) != len(messages) || r.messages[0].lo != 3 {
+		t.Fatal("消息读取有误", len(r.messages))
+	}
+
+	if errorCount := r.runAll(false); errorCount > 0 {
+		t.Fatal("重放出错", errorCount)
+	}
+	if len(r.rounds) != 1 || r.rounds[0].start != 2 {
+		t.Fatal("局数有误", r.rounds)
+	}
+	// INIT 以及之后的 7 条消息
+	if len(r.steps) != 8 {
+		t.Fatal("步数有误", r.steps)
+	}
+
+	// 前进与后退得到的局面应相同
+	r.reset()
+	for step := 0; step < 4; step++ {
+		r.seek(step)
+	}
+	counts := append([]int(nil), r.roundData().counts...)
+	r.seek(6)
+	r.seek(3)
+	if r.currentStep() != 3 {
+		t.Fatal("当前步数有误", r.currentStep())
+	}
+	for i, c := range r.roundData().counts {
+		if c != counts[i] {
+			t.Fatal("后退后的手牌有误", r.roundData().counts)
+		}
+	}
+	if len(r.roundData().players[1].discardTiles) != 1 || len(r.roundData().players[2].discardTiles) != 0 {
+		t.Fatal("后退后的牌河有误")
+	}
+}
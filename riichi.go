@@ -0,0 +1,217 @@
+package main
+
+import (
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/fatih/color"
+)
+
+// 自家立直后的分析：荣和还是见逃、能否暗杠、立直振听
+
+// 立直和了时，每个里宝牌指示牌平均能翻出的里宝牌个数
+// 参考：「統計学」のマージャン戦術
+const avgUraDoraPerIndicator = 0.4
+
+// 立直和了时里宝牌个数的分布，下标为里宝牌个数
+// 视作每个里宝牌指示牌独立地以 avgUraDoraPerIndicator 的概率翻出一枚里宝牌
+func uraDoraProbabilities(indicatorCount int) []float64 {
+	probs := []float64{1}
+	for i := 0; i < indicatorCount; i++ {
+		next := make([]float64, len(probs)+1)
+		for uraDora, p := range probs {
+			next[uraDora] += p * (1 - avgUraDoraPerIndicator)
+			next[uraDora+1] += p * avgUraDoraPerIndicator
+		}
+		probs = next
+	}
+	return probs
+}
+
+// 自家 3k+1 张手牌的待牌
+func (d *roundData) selfWaits() util.Waits {
+	_, waits := util.CalculateShantenAndWaits13(d.counts, d.leftCounts)
+	return waits
+}
+
+// 牌山（不含王牌）的剩余枚数
+// 每次舍牌前都要摸一张牌，吃碰后的舍牌除外
+// 岭上牌会从牌山末尾补充到王牌中，所以大明杠后的舍牌同样相当于从牌山摸了一张牌，
+// 而暗杠、加杠和拔北则会额外减少一张
+func (d *roundData) liveWallLeft() int {
+	const deadWallTiles = 14
+	allTiles := 136
+	if d.isSanma() {
+		allTiles = 108
+	}
+	left := allTiles - deadWallTiles - 13*len(d.players) - len(d.globalDiscardTiles)
+	for _, player := range d.players {
+		for _, meld := range player.melds {
+			switch meld.MeldType {
+			case meldTypeChi, meldTypePon:
+				left++
+			case meldTypeAnkan:
+				left--
+			}
+			// 加杠由碰变化而来，碰的 +1 和岭上的 -1 相抵
+		}
+		left -= player.nukiDoraCount
+	}
+	return util.MaxInt(left, 0)
+}
+
+// 立直后他家舍牌，若为自家的和了牌，比较荣和与见逃（自摸/海底）的点数，给出建议
+func (d *roundData) analysisRiichiRon(who int, discardTile int) {
+	self := d.players[0]
+	if _, ok := d.selfWaits()[discardTile]; !ok {
+		return
+	}
+
+	if self.isRiichiFuriten {
+		outputRenderer.renderMessage(trf("%s 放出了和了牌 %s，但是已经立直振听", d.players[who].name, tileName(discardTile)), color.FgHiYellow)
+		return
+	}
+
+	wallLeft := d.liveWallLeft()
+	playerNumber := len(d.players)
+	honba := d.game.honba
+	riichiSticks := d.game.riichiSticks
+
+	pi := d.newModelPlayerInfo(nil)
+	pi.HandTiles34 = make([]int, 34)
+	copy(pi.HandTiles34, d.counts)
+	pi.HandTiles34[discardTile]++
+	pi.WinTile = discardTile
+
+	// 荣和
+	pi.IsLastTile = wallLeft == 0
+	ronResult := util.CalcPoint(pi)
+	ronDelta := func(uraDora int) []int {
+		point := ronResult.RonPayment(uraDora)
+		delta := make([]int, playerNumber)
+		delta[0] = point + 300*honba + 1000*riichiSticks
+		delta[who] = -point - 300*honba
+		return delta
+	}
+
+	msg := trf("%s 放出了和了牌 %s，荣和 %d 点", d.players[who].name, tileName(discardTile), ronResult.Point)
+	if pi.IsLastTile {
+		msg += tr("（河底）")
+	}
+	outputRenderer.renderMessage(msg)
+
+	// 从下家开始依次摸牌，自家还能摸到牌的前提是牌山剩余数不少于到自家的距离
+	selfDrawOffset := (playerNumber - who) % playerNumber
+	if wallLeft < selfDrawOffset {
+		outputRenderer.renderMessage(tr("已没有自摸的机会，建议荣和"), color.FgHiGreen)
+		return
+	}
+
+	// 见逃，下一巡自摸同样的牌
+	pi.IsTsumo = true
+	pi.IsLastTile = wallLeft == selfDrawOffset
+	tsumoResult := util.CalcPoint(pi)
+	tsumoDelta := func(uraDora int) []int {
+		delta := make([]int, playerNumber)
+		childPoint, parentPoint := tsumoResult.TsumoPayments(uraDora)
+		for other := 1; other < playerNumber; other++ {
+			pay := childPoint
+			if other == d.dealer {
+				pay = parentPoint
+			}
+			delta[other] = -pay - 100*honba
+			delta[0] += pay + 100*honba
+		}
+		delta[0] += 1000 * riichiSticks
+		return delta
+	}
+
+	msg = trf("若见逃，自摸 %d 点", tsumoResult.Point)
+	if pi.IsLastTile {
+		msg += tr("（海底）")
+	}
+	msg += trf("，期望里宝 %.1f 枚", avgUraDoraPerIndicator*float64(len(d.doraIndicators)))
+	outputRenderer.renderMessage(msg)
+
+	if !d.game.isAllLast(d.roundNumber) {
+		outputRenderer.renderMessage(tr("建议荣和"), color.FgHiGreen)
+		return
+	}
+
+	// All Last 时根据计入里宝后的平均顺位决定是否见逃
+	uraDoraProbs := uraDoraProbabilities(len(d.doraIndicators))
+	avgRank := func(deltaPoints func(uraDora int) []int) (rank float64) {
+		for uraDora, p := range uraDoraProbs {
+			rank += p * float64(d.game.rankAfter(0, deltaPoints(uraDora)))
+		}
+		return
+	}
+	ronRank := avgRank(ronDelta)
+	tsumoRank := avgRank(tsumoDelta)
+	outputRenderer.renderMessage(trf("All Last: 计入里宝后，荣和平均为第 %.2f 位，自摸平均为第 %.2f 位", ronRank, tsumoRank))
+	if tsumoRank < ronRank {
+		outputRenderer.renderMessage(tr("自摸可以提升顺位，可以考虑见逃（见逃后立直振听，只能自摸）"), color.FgHiYellow)
+	} else {
+		outputRenderer.renderMessage(tr("建议荣和"), color.FgHiGreen)
+	}
+}
+
+// 立直后自家摸牌
+// 若摸到的是第四张牌，判断暗杠后是否会改变听牌
+func (d *roundData) analysisRiichiDraw(tile int) {
+	self := d.players[0]
+
+	// 摸牌前的手牌
+	d.counts[tile]--
+	waits := d.selfWaits()
+	d.counts[tile]++
+
+	if _, ok := waits[tile]; ok {
+		outputRenderer.renderMessage(trf("自摸 %s，和了！", tileName(tile)), color.FgHiGreen)
+		return
+	}
+
+	if d.counts[tile] == 4 {
+		if d.canRiichiAnkan(tile, waits) {
+			outputRenderer.renderMessage(trf("可以暗杠 %s（不改变听牌）", tileName(tile)), color.FgHiGreen)
+		} else {
+			outputRenderer.renderMessage(trf("暗杠 %s 会改变听牌，不能暗杠", tileName(tile)), color.FgHiYellow)
+		}
+	}
+
+	text := &styledText{}
+	text.print(trf("摸切 %s", tileName(tile)))
+	if self.isRiichiFuriten {
+		text.print(tr("（立直振听）"), color.FgHiYellow)
+	}
+	text.print("\n")
+	outputRenderer.renderText("message", text)
+}
+
+// 立直后暗杠不能改变听牌
+// 这里只比较待牌的种类，没有考虑手牌拆解的变化（如 3334 暗杠 3 虽然不改变待牌，但是部分规则下也不允许）
+func (d *roundData) canRiichiAnkan(tile int, waits util.Waits) bool {
+	d.counts[tile] -= 4
+	_, kanWaits := util.CalculateShantenAndWaits13(d.counts, d.leftCounts)
+	d.counts[tile] += 4
+
+	if len(kanWaits) != len(waits) {
+		return false
+	}
+	for waitTile := range waits {
+		if _, ok := kanWaits[waitTile]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 立直后摸切了自摸的和了牌，或者见逃了他家舍牌，均为立直振听
+func (d *roundData) markRiichiFuriten() {
+	self := d.players[0]
+	if self.isRiichiFuriten {
+		return
+	}
+	self.isRiichiFuriten = true
+	if !d.skipOutput {
+		outputRenderer.renderMessage(tr("见逃，立直振听"), color.FgHiYellow)
+	}
+}
@@ -14,7 +14,6 @@ import (
 	"net"
 	"github.com/fatih/color"
 	"net/url"
-	"github.com/EndlessCheng/mahjong-helper/util/model"
 )
 
 type mjHandler struct {
@@ -27,6 +26,10 @@ type mjHandler struct {
 
 	majsoulMessageQueue chan []byte
 	majsoulRoundData    *majsoulRoundData
+	majsoulFrameDecoder *majsoulFrameDecoder
+
+	mjaiMessageQueue chan []byte
+	mjaiRoundData    *mjaiRoundData
 }
 
 func (h *mjHandler) index(c echo.Context) error {
@@ -40,6 +43,7 @@ func (h *mjHandler) index(c echo.Context) error {
 	return c.String(http.StatusOK, time.Now().Format("2006-01-02 15:04:05"))
 }
 
+// 分析手牌，返回 JSON 格式的分析结果，见 analysisRequest 和 analysisResponse
 func (h *mjHandler) analysis(c echo.Context) error {
 	if h.analysing {
 		return c.NoContent(http.StatusForbidden)
@@ -48,28 +52,26 @@ func (h *mjHandler) analysis(c echo.Context) error {
 	h.analysing = true
 	defer func() { h.analysing = false }()
 
-	d := struct {
-		Reset bool   `json:"reset"`
-		Tiles string `json:"tiles"`
-		//TargetTile string `json:"target_tile"`
-		//ShowDetail bool   `json:"show_detail"`
-	}{}
-	if err := c.Bind(&d); err != nil {
+	d := &analysisRequest{}
+	if err := c.Bind(d); err != nil {
 		fmt.Println(err)
 		return c.String(http.StatusBadRequest, err.Error())
 	}
 
-	if _, err := analysisHumanTiles(model.NewSimpleHumanTilesInfo(d.Tiles)); err != nil {
+	response, err := d.analysis()
+	if err != nil {
 		fmt.Println(err)
 		return c.String(http.StatusBadRequest, err.Error())
 	}
+	return c.JSON(http.StatusOK, response)
+}
 
-	//_, counts, _ := convert(d.Tiles)
-	//tile34, _ := _convert(d.TargetTile)
-	//counts[tile34]--
-	//analysisMeld(counts, nil, tile34, true)
-
-	return c.NoContent(http.StatusOK)
+// 通过 WebSocket 订阅分析结果，见 websocket.go
+func (h *mjHandler) subscribe(c echo.Context) error {
+	if err := analysisPusher.serve(c.Response(), c.Request()); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return nil
 }
 
 // 分析天凤 WebSocket 数据
@@ -101,29 +103,35 @@ func (h *mjHandler) runAnalysisTenhouMessageTask() {
 			}
 		}
 
-		originJSON := string(msg)
 		if h.log != nil {
-			h.log.Info(originJSON)
+			h.log.Info(string(msg))
 		}
 
-		// 登录验证通过
-		if d.Tag == "HELO" {
-			username, err := url.QueryUnescape(d.UserName)
-			if err != nil {
-				fmt.Println(err)
-			}
-			if username != h.tenhouRoundData.username {
-				fmt.Printf("%s 登录成功\n", username)
-				h.tenhouRoundData.username = username
-			}
+		if err := h.handleTenhouMessage(&d, msg); err != nil {
+			fmt.Println(tr("错误："), err)
 		}
+	}
+}
 
-		h.tenhouRoundData.msg = &d
-		h.tenhouRoundData.originJSON = originJSON
-		if err := h.tenhouRoundData.analysis(); err != nil {
-			fmt.Println("错误：", err)
+// 解析一条天凤消息，离线重放时同样调用此方法
+func (h *mjHandler) handleTenhouMessage(d *tenhouMessage, msg []byte) error {
+	// 登录验证通过
+	if d.Tag == "HELO" {
+		username, err := url.QueryUnescape(d.UserName)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if username != h.tenhouRoundData.username {
+			if !h.tenhouRoundData.skipOutput {
+				fmt.Printf(tr("%s 登录成功\n"), username)
+			}
+			h.tenhouRoundData.username = username
 		}
 	}
+
+	h.tenhouRoundData.msg = d
+	h.tenhouRoundData.originJSON = string(msg)
+	return h.tenhouRoundData.analysis()
 }
 
 // 分析雀魂 WebSocket 数据
@@ -138,6 +146,25 @@ func (h *mjHandler) analysisMajsoul(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+// 分析雀魂的原始 WebSocket 帧（二进制），每个请求为一个帧
+func (h *mjHandler) analysisMajsoulRaw(c echo.Context) error {
+	data, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		fmt.Println(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	msg, err := h.majsoulFrameDecoder.decode(data)
+	if err != nil {
+		fmt.Println(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if msg != nil {
+		h.majsoulMessageQueue <- msg
+	}
+	return c.NoContent(http.StatusOK)
+}
+
 func (h *mjHandler) runAnalysisMajsoulMessageTask() {
 	for msg := range h.majsoulMessageQueue {
 		d := majsoulMessage{}
@@ -146,34 +173,88 @@ func (h *mjHandler) runAnalysisMajsoulMessageTask() {
 			continue
 		}
 
-		originJSON := string(msg)
 		if h.log != nil {
-			h.log.Info(originJSON)
+			h.log.Info(string(msg))
+		}
+
+		if err := h.handleMajsoulMessage(&d, msg); err != nil {
+			fmt.Println(tr("错误："), err)
 		}
+	}
+}
 
-		// 登录验证通过
-		if d.AccountID > 0 && h.majsoulRoundData.accountID != d.AccountID {
-			h.majsoulRoundData.accountID = d.AccountID
+// 解析一条雀魂消息，离线重放时同样调用此方法
+func (h *mjHandler) handleMajsoulMessage(d *majsoulMessage, msg []byte) error {
+	skipOutput := h.majsoulRoundData.skipOutput
+
+	// 登录验证通过
+	if d.AccountID > 0 && h.majsoulRoundData.accountID != d.AccountID {
+		h.majsoulRoundData.accountID = d.AccountID
+		if !skipOutput {
 			printAccountInfo(d.AccountID)
-			continue
 		}
+		return nil
+	}
 
-		if d.Friends != nil {
-			fmt.Println("好友账号ID   好友上次登录时间        好友上次登出时间       好友昵称")
+	if d.Friends != nil {
+		if !skipOutput {
+			fmt.Println(tr("好友账号ID   好友上次登录时间        好友上次登出时间       好友昵称"))
 			for _, friend := range d.Friends {
 				fmt.Println(friend)
 			}
+		}
+		return nil
+	}
+
+	h.majsoulRoundData.msg = d
+	h.majsoulRoundData.originJSON = string(msg)
+	return h.majsoulRoundData.analysis()
+}
+
+// 分析 mjai 消息，每个请求可以是单条消息或消息数组
+func (h *mjHandler) analysisMjai(c echo.Context) error {
+	data, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		fmt.Println(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	messages, err := splitMjaiLine(data)
+	if err != nil {
+		fmt.Println(err)
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	for _, msg := range messages {
+		h.mjaiMessageQueue <- msg
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *mjHandler) runAnalysisMjaiMessageTask() {
+	for msg := range h.mjaiMessageQueue {
+		d := mjaiMessage{}
+		if err := json.Unmarshal(msg, &d); err != nil {
+			fmt.Println(err)
 			continue
 		}
 
-		h.majsoulRoundData.msg = &d
-		h.majsoulRoundData.originJSON = originJSON
-		if err := h.majsoulRoundData.analysis(); err != nil {
-			fmt.Println("错误：", err)
+		if h.log != nil {
+			h.log.Info(string(msg))
+		}
+
+		if err := h.handleMjaiMessage(&d, msg); err != nil {
+			fmt.Println(tr("错误："), err)
 		}
 	}
 }
 
+// 解析一条 mjai 消息，离线重放和标准输入输出模式同样调用此方法
+func (h *mjHandler) handleMjaiMessage(d *mjaiMessage, msg []byte) error {
+	h.mjaiRoundData.msg = d
+	h.mjaiRoundData.originJSON = string(msg)
+	return h.mjaiRoundData.analysis()
+}
+
 func runServer(isHTTPS bool) {
 	e := echo.New()
 	e.HideBanner = true
@@ -195,7 +276,7 @@ func runServer(isHTTPS bool) {
 	}()
 
 	if isHTTPS && gameConf.MajsoulAccountID != -1 {
-		color.HiYellow("[提醒] 从配置中读取出雀魂账号 %d", gameConf.MajsoulAccountID)
+		color.HiYellow(tr("[提醒] 从配置中读取出雀魂账号 %d"), gameConf.MajsoulAccountID)
 	}
 	h := &mjHandler{
 		log: e.Logger,
@@ -204,17 +285,28 @@ func runServer(isHTTPS bool) {
 		tenhouRoundData:     &tenhouRoundData{isRoundEnd: true},
 		majsoulMessageQueue: make(chan []byte, 100),
 		majsoulRoundData:    &majsoulRoundData{accountID: gameConf.MajsoulAccountID},
+		majsoulFrameDecoder: newMajsoulFrameDecoder(),
+		mjaiMessageQueue:    make(chan []byte, 100),
+		mjaiRoundData:       &mjaiRoundData{},
 	}
 	h.tenhouRoundData.roundData = newRoundData(h.tenhouRoundData, 0, 0)
 	h.majsoulRoundData.roundData = newRoundData(h.majsoulRoundData, 0, 0)
+	h.mjaiRoundData.roundData = newRoundData(h.mjaiRoundData, 0, 0)
 
 	go h.runAnalysisTenhouMessageTask()
 	go h.runAnalysisMajsoulMessageTask()
+	go h.runAnalysisMjaiMessageTask()
 
 	e.GET("/", h.index)
 	e.POST("/analysis", h.analysis)
 	e.POST("/tenhou", h.analysisTenhou)
 	e.POST("/majsoul", h.analysisMajsoul)
+	e.POST("/majsoul/raw", h.analysisMajsoulRaw)
+	e.POST("/mjai", h.analysisMjai)
+
+	// 订阅分析结果
+	analysisPusher = newWSHub()
+	e.GET("/ws", h.subscribe)
 
 	addr := ":12121"
 	var err error
@@ -229,7 +321,7 @@ func runServer(isHTTPS bool) {
 		// 检查是否为端口占用错误
 		if opErr, ok := err.(*net.OpError); ok && opErr.Op == "listen" {
 			if syscallErr, ok := opErr.Err.(*os.SyscallError); ok && syscallErr.Syscall == "bind" {
-				color.HiRed(addr + " 端口已被占用，程序无法启动（是否已经开启了本程序？）")
+				color.HiRed(tr("%s 端口已被占用，程序无法启动（是否已经开启了本程序？）"), addr)
 			}
 		}
 		errorExit(err)
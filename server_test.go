@@ -1,6 +1,12 @@
 package main
 
 import (
+	"encoding/json"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,3 +26,76 @@ func Test_mjHandler_runAnalysisTenhouMessageTask(t *testing.T) {
 
 	time.Sleep(time.Second)
 }
+
+func Test_mjHandler_analysis(t *testing.T) {
+	debugMode = true
+
+	h := &mjHandler{}
+	e := echo.New()
+	analysis := func(body string) (int, *analysisResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/analysis", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := h.analysis(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		response := &analysisResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, response
+	}
+
+	// 何切
+	_, response := analysis(`{"tiles":"24m 55p 3478s","melds":[{"type":"pon","tiles":"777z"}],"dora_indicators":"1m","self_wind":"2z"}`)
+	if response == nil || response.Shanten != 1 || len(response.Results14) == 0 || response.Results14[0].Result13 == nil || response.Results14[0].Result13.Waits.AllCount() == 0 {
+		t.Fatal("何切分析有误", response)
+	}
+
+	// 手牌中写上副露和赤5
+	_, response = analysis(`{"tiles":"24m 50p 3478s & (777z)","dora_indicators":"1m","self_wind":"2z"}`)
+	if response == nil || response.Shanten != 1 || len(response.Results14) == 0 {
+		t.Fatal("何切分析有误", response)
+	}
+
+	// 局况
+	_, response = analysis(`{"tiles":"123m 456p 789s 34m 22z","round_wind":"S","self_wind":"2z","riichi":true,"turn":8,"discards":"2m","seen":"5m"}`)
+	if response == nil || response.Shanten != 0 || response.Result13 == nil || response.Result13.FuritenRate != 1 || len(response.Results14) != 0 {
+		t.Fatal("局况分析有误", response)
+	}
+
+	// 没有指定自风时按子家计算，自风为东时按亲家计算
+	_, response = analysis(`{"tiles":"123m 456p 789s 34m 22z","turn":8}`)
+	_, dealerResponse := analysis(`{"tiles":"123m 456p 789s 34m 22z","turn":8,"self_wind":"E"}`)
+	if response == nil || dealerResponse == nil || dealerResponse.Result13.DamaPoint <= response.Result13.DamaPoint {
+		t.Fatal("亲子判断有误", response, dealerResponse)
+	}
+
+	// 鸣牌
+	_, response = analysis(`{"tiles":"24m 55p 347s","melds":[{"type":"pon","tiles":"777z"}],"target_tile":"3m","allow_chi":true}`)
+	if response == nil || response.Result13 == nil || len(response.Results14) == 0 || !reflect.DeepEqual(response.Results14[0].OpenTiles, []int{1, 3}) {
+		t.Fatal("鸣牌分析有误", response)
+	}
+	_, response = analysis(`{"tiles":"24m 55p 347s","melds":[{"type":"pon","tiles":"777z"}],"target_tile":"3m"}`)
+	if response == nil || response.Result13 == nil || len(response.Results14) != 0 {
+		t.Fatal("不能吃", response)
+	}
+
+	// 参数错误
+	for _, body := range []string{
+		`{"tiles":"24m 55p 3478s","melds":[{"type":"chi","tiles":"777z"}]}`,
+		`{"tiles":"24m 55p 3478s","melds":[{"type":"pon","tiles":"777z"}],"self_wind":"5z"}`,
+		`{"tiles":"24m 55p 3478s","melds":[{"type":"pon","tiles":"777z"}],"target_tile":"3m"}`,
+		`{"tiles":"11111m 55p 3478s"}`,
+		`{"tiles":"24m 55p 3478s & (777z"}`,
+		`{"tiles":"24m 55p 3478s & [456m]"}`,
+		`{"tiles":"24m 55p 3478s","melds":[{"type":"pon","tiles":"777z","red":true}]}`,
+	} {
+		if code, _ := analysis(body); code != http.StatusBadRequest {
+			t.Fatal(body, "应当返回 400", code)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"fmt"
 	"regexp"
+	"github.com/EndlessCheng/mahjong-helper/util"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
 	"sort"
 )
@@ -52,28 +53,38 @@ type tenhouMessage struct {
 	// `json:"ten"` // 立直成功后的各家点数 250,250,240,250
 	// `json:"step"` // 2
 
-	// 自摸/有人放铳 tag=牌, t>=8
-	T string `json:"t"` // 选项
+	// 摸牌/他家舍牌时自家可以进行的操作 tag=牌
+	T string `json:"t"` // 选项（位标志），见 tenhouOption
 
 	// 和牌 tag=AGARI
 	// ba, hai, m, machi, ten, yaku, doraHai, who, fromWho, sc
 	//Ba string `json:"ba"` // 0,0
 	// `json:"hai"` // 和牌型 8,9,11,14,19,125,126,127
 	// `json:"m"` // 副露编号 13527,50794
-	//Machi string `json:"machi"` // (待ち) 自摸/荣和的牌 126
-	// `json:"ten"` // 符数,点数,这张牌的来源 30,7700,0
-	//Yaku        string `json:"yaku"`       // 役（编号，翻数） 18,1,20,1,34,2
-	//DoraTile    string `json:"doraHai"`    // 宝牌 123
-	//UraDoraTile string `json:"doraHaiUra"` // 里宝牌 77
+	Machi string `json:"machi"` // (待ち) 自摸/荣和的牌 126
+	// `json:"ten"` // 符数,点数,满贯等级 30,7700,0
+	Yaku        string `json:"yaku"`       // 役（编号，翻数） 18,1,20,1,34,2
+	Yakuman     string `json:"yakuman"`    // 役满（编号） 39
+	DoraTile    string `json:"doraHai"`    // 宝牌指示牌 123（重连时为所有已翻出的宝牌指示牌）
+	UraDoraTile string `json:"doraHaiUra"` // 里宝牌指示牌 77
 	// `json:"who"` // 和牌者
-	//FromWho string `json:"fromWho"` // 自摸/荣和牌的来源
-	//Score   string `json:"sc"`      // 各家增减分 260,-77,310,77,220,0,210,0
+	FromWho string `json:"fromWho"` // 自摸/荣和牌的来源
+	Score string `json:"sc"` // 各家原点数和增减分（单位为百点）260,-77,310,77,220,0,210,0
+
+	// 流局 tag=RYUUKYOKU
+	// ba, sc, hai0-hai3, type
+	// `json:"sc"`
+	Hai0 string `json:"hai0"` // 听牌者的手牌，未听牌则为空
+	Hai1 string `json:"hai1"`
+	Hai2 string `json:"hai2"`
+	Hai3 string `json:"hai3"`
+	// `json:"type"` // 途中流局的类型 yao9, kaze4, reach4, ron3, kan4；流局满贯为 nm；荒牌流局时为空
 
 	// 游戏结束 tag=PROF
 
-	// 重连 tag=GO
+	// 游戏开始 tag=GO
 	// type, lobby, gpid
-	//Type  string `json:"type"`
+	Type string `json:"type"` // 对局类型（位标志，0x08 表示半庄战） 169
 	//Lobby string `json:"lobby"`
 	//GPID  string `json:"gpid"`
 
@@ -81,16 +92,21 @@ type tenhouMessage struct {
 	// `json:"seed"`
 	// `json:"ten"`
 	// `json:"oya"`
-	// `json:"hai"`
-	//Meld1    string `json:"m1"` // 各家副露编号 17450
-	//Meld2    string `json:"m2"`
-	//Meld3    string `json:"m3"`
-	//Kawa0 string `json:"kawa0"` // 各家牌河 112,73,3,131,43,98,78,116
-	//Kawa1 string `json:"kawa1"`
-	//Kawa2 string `json:"kawa2"`
-	//Kawa3 string `json:"kawa3"`
+	// `json:"hai"` // 当前手牌
+	// `json:"doraHai"` // 已翻出的宝牌指示牌 92,39
+	Meld0 string `json:"m0"` // 各家副露编号 17450,43595
+	Meld1 string `json:"m1"`
+	Meld2 string `json:"m2"`
+	Meld3 string `json:"m3"`
+	Kawa0 string `json:"kawa0"` // 各家牌河，255 表示下一张牌为立直宣言牌 112,73,3,255,131,43,98,78,116
+	Kawa1 string `json:"kawa1"`
+	Kawa2 string `json:"kawa2"`
+	Kawa3 string `json:"kawa3"`
 }
 
+// 重连时牌河中立直宣言牌的标记
+const tenhouKawaReachMark = "255"
+
 //
 
 type tenhouRoundData struct {
@@ -100,6 +116,8 @@ type tenhouRoundData struct {
 	username   string
 	msg        *tenhouMessage
 	isRoundEnd bool // 某人和牌或流局，初始化为 true
+
+	events eventQueue
 }
 
 func (*tenhouRoundData) _tenhouTileToTile34(tenhouTile int) int {
@@ -154,22 +172,16 @@ func (d *tenhouRoundData) _parseKan(data int) (meldType int, tenhouMeldTiles []i
 	baseAndCalled := data >> 8
 	base, called := baseAndCalled/4, baseAndCalled%4
 	tenhouMeldTiles = []int{4 * base, 1 + 4*base, 2 + 4*base, 3 + 4*base}
-	tenhouCalledTile = d._tenhouTileToTile34(tenhouMeldTiles[called])
-
-	// 通过判断 calledTile 的来源来是否为上一张舍牌，来判断是大明杠还是暗杠
-	latestDiscard := -1
-	if len(d.globalDiscardTiles) > 0 {
-		latestDiscard = d.globalDiscardTiles[len(d.globalDiscardTiles)-1]
-		if latestDiscard < 0 {
-			latestDiscard = ^latestDiscard
-		}
-	}
-	if d._tenhouTileToTile34(tenhouCalledTile) == latestDiscard {
-		// 大明杠
-		meldType = meldTypeMinkan
-	} else {
+	tenhouCalledTile = tenhouMeldTiles[called]
+
+	// 低两位表示 calledTile 的来源，0 表示来自自己，即暗杠
+	// 重连时没有舍牌顺序，所以不能通过 calledTile 是否为上一张舍牌来判断
+	if data&0x3 == 0 {
 		// 暗杠
 		meldType = meldTypeAnkan
+	} else {
+		// 大明杠
+		meldType = meldTypeMinkan
 	}
 	return
 }
@@ -186,13 +198,22 @@ func (d *tenhouRoundData) _parseTenhouMeld(data string) (meldType int, tenhouMel
 	case bits&0x18 > 0:
 		return d._parsePon(bits)
 	case bits&0x20 > 0:
-		// 拔北
-		panic("暂不支持三人麻将")
+		// 拔北不是副露，见 IsKita
+		panic("拔北不能作为副露解析")
 	default:
 		return d._parseKan(bits)
 	}
 }
 
+// 是否为拔北（三人麻将）
+func (d *tenhouRoundData) _isKita(data string) bool {
+	bits, err := strconv.Atoi(data)
+	if err != nil {
+		panic(err)
+	}
+	return bits&0x4 == 0 && bits&0x18 == 0 && bits&0x20 > 0
+}
+
 func (*tenhouRoundData) isRedFive(tenhouTile int) bool {
 	return tenhouTile == redFiveMan || tenhouTile == redFivePin || tenhouTile == redFiveSou
 }
@@ -223,6 +244,27 @@ func (d *tenhouRoundData) CheckMessage() bool {
 	return true
 }
 
+func (d *tenhouRoundData) IsGameStart() bool {
+	return d.msg.Tag == "GO"
+}
+
+func (d *tenhouRoundData) ParseGameStart() (gameLength int, playerNumber int) {
+	const (
+		hanchanFlag = 0x08
+		sanmaFlag   = 0x10
+	)
+	gameType, _ := strconv.Atoi(d.msg.Type)
+	gameLength = gameLengthTonpuu
+	if gameType&hanchanFlag > 0 {
+		gameLength = gameLengthHanchan
+	}
+	playerNumber = 4
+	if gameType&sanmaFlag > 0 {
+		playerNumber = 3
+	}
+	return
+}
+
 func (d *tenhouRoundData) IsInit() bool {
 	return d.msg.Tag == "INIT" || d.msg.Tag == "REINIT"
 }
@@ -247,6 +289,29 @@ func (d *tenhouRoundData) ParseInit() (roundNumber int, dealer int, doraIndicato
 	return
 }
 
+func (d *tenhouRoundData) ParseInitScores() (honba int, riichiSticks int, scores []int) {
+	splits := strings.Split(d.msg.Seed, ",")
+	if len(splits) != 6 {
+		panic(fmt.Sprintln("seed 解析失败", d.msg.Seed))
+	}
+	honba, _ = strconv.Atoi(splits[1])
+	riichiSticks, _ = strconv.Atoi(splits[2])
+	scores = d._parseScores(d.msg.Ten)
+	return
+}
+
+// 解析 ten 中的各家点数（单位为百点）
+func (d *tenhouRoundData) _parseScores(ten string) (scores []int) {
+	if ten == "" {
+		return
+	}
+	for _, split := range strings.Split(ten, ",") {
+		score, _ := strconv.Atoi(split)
+		scores = append(scores, 100*score)
+	}
+	return
+}
+
 var _selfDrawReg = regexp.MustCompile("^T[0-9]{1,3}$")
 
 func isTenhouSelfDraw(tag string) bool {
@@ -257,39 +322,124 @@ func (d *tenhouRoundData) IsSelfDraw() bool {
 	return isTenhouSelfDraw(d.msg.Tag)
 }
 
-func (d *tenhouRoundData) ParseSelfDraw() (tile int, isRedFive bool, kanDoraIndicator int) {
+func (d *tenhouRoundData) ParseSelfDraw() (tile int, isRedFive bool, options *ActionOptions) {
 	rawTile := d.msg.Tag[1:]
 	tile, isRedFive = d._parseTenhouTile(rawTile)
-	kanDoraIndicator = -1
+	options = d._parseOptions(true)
 	return
 }
 
+// 摸牌、舍牌消息中 t 的各个位
+const (
+	tenhouOptionPon            = 1 << iota // 碰
+	tenhouOptionKan                        // 他家舍牌时为大明杠，自家摸牌时为暗杠/加杠
+	tenhouOptionChi                        // 吃
+	tenhouOptionRon                        // 荣和
+	tenhouOptionTsumo                      // 自摸
+	tenhouOptionRiichi                     // 立直
+	tenhouOptionKyuushuKyuuhai             // 九种九牌
+)
+
+// 解析 t，没有可以进行的操作时返回 nil
+func (d *tenhouRoundData) _parseOptions(isSelfDraw bool) *ActionOptions {
+	if d.msg.T == "" {
+		return nil
+	}
+	t, err := strconv.Atoi(d.msg.T)
+	if err != nil {
+		panic(err)
+	}
+	if t == 0 {
+		return nil
+	}
+	if isSelfDraw {
+		return &ActionOptions{
+			Tsumo:          t&tenhouOptionTsumo > 0,
+			Riichi:         t&tenhouOptionRiichi > 0,
+			SelfKan:        t&tenhouOptionKan > 0,
+			KyuushuKyuuhai: t&tenhouOptionKyuushuKyuuhai > 0,
+		}
+	}
+	return &ActionOptions{
+		Chi:    t&tenhouOptionChi > 0,
+		Pon:    t&tenhouOptionPon > 0,
+		Minkan: t&tenhouOptionKan > 0,
+		Ron:    t&tenhouOptionRon > 0,
+	}
+}
+
 var _discardReg = regexp.MustCompile("^[DEFGefg][0-9]{1,3}$")
 
 func (d *tenhouRoundData) IsDiscard() bool {
 	return _discardReg.MatchString(d.msg.Tag)
 }
 
-func (d *tenhouRoundData) ParseDiscard() (who int, discardTile int, isRedFive bool, isTsumogiri bool, isReach bool, canBeMeld bool, kanDoraIndicator int) {
+func (d *tenhouRoundData) ParseDiscard() (who int, discardTile int, isRedFive bool, isTsumogiri bool, options *ActionOptions) {
 	// D=自家, e/E=下家, f/F=对家, g/G=上家
 	who = int(lower(d.msg.Tag[0]) - 'd')
 	rawTile := d.msg.Tag[1:]
 	discardTile, isRedFive = d._parseTenhouTile(rawTile)
 	if d.msg.Tag[0] != 'D' {
 		isTsumogiri = d.msg.Tag[0] >= 'a'
-		canBeMeld = d.msg.T != ""
+		options = d._parseOptions(false)
+	}
+	return
+}
+
+func (d *tenhouRoundData) ParseReinit() (players []*playerReinitInfo, doraIndicators []int) {
+	if d.msg.Tag != "REINIT" {
+		return
+	}
+
+	if d.msg.DoraTile != "" {
+		for _, tenhouTile := range strings.Split(d.msg.DoraTile, ",") {
+			doraIndicator, _ := d._parseTenhouTile(tenhouTile)
+			doraIndicators = append(doraIndicators, doraIndicator)
+		}
+	}
+
+	kawas := []string{d.msg.Kawa0, d.msg.Kawa1, d.msg.Kawa2, d.msg.Kawa3}
+	melds := []string{d.msg.Meld0, d.msg.Meld1, d.msg.Meld2, d.msg.Meld3}
+	players = make([]*playerReinitInfo, len(kawas))
+	for who := range players {
+		info := &playerReinitInfo{reachTileAt: -1}
+		if kawas[who] != "" {
+			for _, tenhouTile := range strings.Split(kawas[who], ",") {
+				if tenhouTile == tenhouKawaReachMark {
+					info.reachTileAt = len(info.discardTiles)
+					continue
+				}
+				// 天凤的重连数据不区分手切摸切，这里均视作手切
+				tile, _ := d._parseTenhouTile(tenhouTile)
+				info.discardTiles = append(info.discardTiles, tile)
+			}
+		}
+		if melds[who] != "" {
+			for _, data := range strings.Split(melds[who], ",") {
+				if d._isKita(data) {
+					info.nukiDoraCount++
+					continue
+				}
+				info.melds = append(info.melds, d._parseModelMeld(data))
+			}
+		}
+		players[who] = info
 	}
-	kanDoraIndicator = -1
 	return
 }
 
 func (d *tenhouRoundData) IsOpen() bool {
-	return d.msg.Tag == "N"
+	return d.msg.Tag == "N" && !d._isKita(d.msg.Meld)
 }
 
-func (d *tenhouRoundData) ParseOpen() (who int, meld *model.Meld, kanDoraIndicator int) {
+func (d *tenhouRoundData) ParseOpen() (who int, meld *model.Meld) {
 	who, _ = strconv.Atoi(d.msg.Who)
-	meldType, tenhouMeldTiles, tenhouCalledTile := d._parseTenhouMeld(d.msg.Meld)
+	meld = d._parseModelMeld(d.msg.Meld)
+	return
+}
+
+func (d *tenhouRoundData) _parseModelMeld(data string) *model.Meld {
+	meldType, tenhouMeldTiles, tenhouCalledTile := d._parseTenhouMeld(data)
 	meldTiles := make([]int, len(tenhouMeldTiles))
 	for i, tenhouTile := range tenhouMeldTiles {
 		meldTiles[i] = d._tenhouTileToTile34(tenhouTile)
@@ -297,14 +447,21 @@ func (d *tenhouRoundData) ParseOpen() (who int, meld *model.Meld, kanDoraIndicat
 	sort.Ints(meldTiles)
 	calledTile := d._tenhouTileToTile34(tenhouCalledTile)
 	isCalledTileRedFive := d.isRedFive(tenhouCalledTile)
-	meld = &model.Meld{
+	return &model.Meld{
 		MeldType:          meldType,
 		Tiles:             meldTiles,
 		CalledTile:        calledTile,
 		ContainRedFive:    d.containRedFive(tenhouMeldTiles),
 		RedFiveFromOthers: isCalledTileRedFive && (meldType == model.MeldTypeChi || meldType == model.MeldTypePon || meldType == model.MeldTypeMinkan),
 	}
-	kanDoraIndicator = -1
+}
+
+func (d *tenhouRoundData) IsKita() bool {
+	return d.msg.Tag == "N" && d._isKita(d.msg.Meld)
+}
+
+func (d *tenhouRoundData) ParseKita() (who int) {
+	who, _ = strconv.Atoi(d.msg.Who)
 	return
 }
 
@@ -319,6 +476,14 @@ func (d *tenhouRoundData) ParseReach() (who int) {
 	return
 }
 
+func (d *tenhouRoundData) ParseReachSuccess() (who int) {
+	if d.msg.Tag != "REACH" || d.msg.Step != "2" {
+		return -1
+	}
+	who, _ = strconv.Atoi(d.msg.Who)
+	return
+}
+
 func (d *tenhouRoundData) IsFuriten() bool {
 	return d.msg.Tag == "FURITEN"
 }
@@ -327,14 +492,230 @@ func (d *tenhouRoundData) IsRoundWin() bool {
 	return d.msg.Tag == "AGARI"
 }
 
-func (d *tenhouRoundData) ParseRoundWin() (whos []int, points []int) {
-	who, _ := strconv.Atoi(d.msg.Who)
-	splits := strings.Split(d.msg.Ten, ",")
-	if len(splits) < 2 {
+// 天凤的役，按编号排列
+// 宝牌、里宝牌、赤宝牌单独统计，不在 WinResult.Yakus 中
+const (
+	tenhouYakuDora    = 52
+	tenhouYakuUraDora = 53
+	tenhouYakuAkaDora = 54
+)
+
+var tenhouYakuList = []struct {
+	name     string
+	yakuType int
+}{
+	{"门前清自摸和", util.YakuTsumo},
+	{"立直", util.YakuRiichi},
+	{"一发", util.YakuIppatsu},
+	{"抢杠", util.YakuChankan},
+	{"岭上开花", util.YakuRinshan},
+	{"海底摸月", util.YakuHaitei},
+	{"河底捞鱼", util.YakuHoutei},
+	{"平和", util.YakuPinfu},
+	{"断幺九", util.YakuTanyao},
+	{"一杯口", util.YakuIipeikou},
+	{"自风 东", util.YakuYakuhai},
+	{"自风 南", util.YakuYakuhai},
+	{"自风 西", util.YakuYakuhai},
+	{"自风 北", util.YakuYakuhai},
+	{"场风 东", util.YakuYakuhai},
+	{"场风 南", util.YakuYakuhai},
+	{"场风 西", util.YakuYakuhai},
+	{"场风 北", util.YakuYakuhai},
+	{"役牌 白", util.YakuYakuhai},
+	{"役牌 发", util.YakuYakuhai},
+	{"役牌 中", util.YakuYakuhai},
+	{"两立直", util.YakuDaburii},
+	{"七对子", util.YakuChiitoi},
+	{"混全带幺九", util.YakuChanta},
+	{"一气通贯", util.YakuIttsuu},
+	{"三色同顺", util.YakuSanshokuDoujun},
+	{"三色同刻", util.YakuSanshokuDoukou},
+	{"三杠子", util.YakuSanKantsu},
+	{"对对和", util.YakuToitoi},
+	{"三暗刻", util.YakuSanAnkou},
+	{"小三元", util.YakuShousangen},
+	{"混老头", util.YakuHonroutou},
+	{"二杯口", util.YakuRyanpeikou},
+	{"纯全带幺九", util.YakuJunchan},
+	{"混一色", util.YakuHonitsu},
+	{"清一色", util.YakuChinitsu},
+	{"人和", winYakuTypeUnknown},
+	{"天和", winYakuTypeUnknown},
+	{"地和", winYakuTypeUnknown},
+	{"大三元", winYakuTypeUnknown},
+	{"四暗刻", winYakuTypeUnknown},
+	{"四暗刻单骑", winYakuTypeUnknown},
+	{"字一色", winYakuTypeUnknown},
+	{"绿一色", winYakuTypeUnknown},
+	{"清老头", winYakuTypeUnknown},
+	{"九莲宝灯", winYakuTypeUnknown},
+	{"纯正九莲宝灯", winYakuTypeUnknown},
+	{"国士无双", winYakuTypeUnknown},
+	{"国士无双十三面", winYakuTypeUnknown},
+	{"大四喜", winYakuTypeUnknown},
+	{"小四喜", winYakuTypeUnknown},
+	{"四杠子", winYakuTypeUnknown},
+}
+
+func (d *tenhouRoundData) _tenhouYaku(id int) *WinYaku {
+	if id < 0 || id >= len(tenhouYakuList) {
+		return &WinYaku{YakuType: winYakuTypeUnknown, Name: trf("未知役%d", id)}
+	}
+	yaku := tenhouYakuList[id]
+	return &WinYaku{YakuType: yaku.yakuType, Name: yaku.name}
+}
+
+func (d *tenhouRoundData) _parseInts(str string) (values []int) {
+	if str == "" {
+		return
+	}
+	for _, s := range strings.Split(str, ",") {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			panic(err)
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+// 解析天凤的和牌信息
+func (d *tenhouRoundData) ParseRoundWin() (results []*WinResult, deltaPoints []int) {
+	msg := d.msg
+	who, _ := strconv.Atoi(msg.Who)
+	fromWho, err := strconv.Atoi(msg.FromWho)
+	if err != nil {
+		fromWho = -1
+	}
+	deltaPoints = d._parseDeltaPoints(msg.Score)
+
+	result := &WinResult{
+		Who:         who,
+		FromWho:     fromWho,
+		WinTile:     -1,
+		NumRedFives: make([]int, 3),
+	}
+
+	// 符数,点数,满贯等级
+	if ten := d._parseInts(msg.Ten); len(ten) >= 2 {
+		result.Fu, result.Point = ten[0], ten[1]
+	}
+
+	countRedFives := func(tenhouTiles []int) {
+		for _, tenhouTile := range tenhouTiles {
+			if d.isRedFive(tenhouTile) {
+				result.NumRedFives[tenhouTile/36]++
+			}
+		}
+	}
+	if msg.Machi != "" {
+		result.WinTile, _ = d._parseTenhouTile(msg.Machi)
+	}
+	if hai := d._parseInts(msg.Hai); len(hai) > 0 && msg.Machi != "" {
+		result.HandTiles = make([]int, 34)
+		for _, tenhouTile := range hai {
+			result.HandTiles[d._tenhouTileToTile34(tenhouTile)]++
+		}
+		countRedFives(hai)
+	}
+	if msg.Meld != "" {
+		for _, data := range strings.Split(msg.Meld, ",") {
+			if d._isKita(data) {
+				continue
+			}
+			_, tenhouMeldTiles, _ := d._parseTenhouMeld(data)
+			countRedFives(tenhouMeldTiles)
+			result.Melds = append(result.Melds, d._parseModelMeld(data))
+		}
+	}
+
+	for _, indicators := range []struct {
+		str   string
+		tiles *[]int
+	}{{msg.DoraTile, &result.DoraIndicators}, {msg.UraDoraTile, &result.UraDoraIndicators}} {
+		for _, tenhouTile := range d._parseInts(indicators.str) {
+			*indicators.tiles = append(*indicators.tiles, d._tenhouTileToTile34(tenhouTile))
+		}
+	}
+
+	// 役（编号，翻数）
+	yaku := d._parseInts(msg.Yaku)
+	for i := 0; i+1 < len(yaku); i += 2 {
+		id, han := yaku[i], yaku[i+1]
+		switch id {
+		case tenhouYakuDora:
+			result.Dora += han
+		case tenhouYakuUraDora:
+			result.UraDora += han
+		case tenhouYakuAkaDora:
+			result.AkaDora += han
+		default:
+			if han == 0 {
+				continue
+			}
+			winYaku := d._tenhouYaku(id)
+			winYaku.Han = han
+			result.Yakus = append(result.Yakus, winYaku)
+		}
+		result.Han += han
+	}
+	for _, id := range d._parseInts(msg.Yakuman) {
+		winYaku := d._tenhouYaku(id)
+		winYaku.Yakuman = 1
+		result.Yakus = append(result.Yakus, winYaku)
+		result.YakumanTimes++
+	}
+	if result.YakumanTimes > 0 {
+		result.Han = 0
+	}
+
+	return []*WinResult{result}, deltaPoints
+}
+
+var tenhouRyuukyokuTypeMap = map[string]int{
+	"":       ryuukyokuTypeExhaustive,
+	"nm":     ryuukyokuTypeNagashiMangan,
+	"yao9":   ryuukyokuTypeKyuushuKyuuhai,
+	"kaze4":  ryuukyokuTypeSuufonRenda,
+	"reach4": ryuukyokuTypeSuuchaRiichi,
+	"kan4":   ryuukyokuTypeSuukanSanra,
+	"ron3":   ryuukyokuTypeSanchahou,
+}
+
+func (d *tenhouRoundData) IsRyuukyoku() bool {
+	return d.msg.Tag == "RYUUKYOKU"
+}
+
+func (d *tenhouRoundData) ParseRyuukyoku() (ryuukyokuType int, tenpaiWhos []int, deltaPoints []int) {
+	ryuukyokuType, ok := tenhouRyuukyokuTypeMap[d.msg.Type]
+	if !ok {
+		panic(fmt.Sprintln("未知的流局类型", d.msg.Type))
+	}
+
+	if ryuukyokuType == ryuukyokuTypeExhaustive || ryuukyokuType == ryuukyokuTypeNagashiMangan {
+		for who, hai := range []string{d.msg.Hai0, d.msg.Hai1, d.msg.Hai2, d.msg.Hai3} {
+			if hai != "" {
+				tenpaiWhos = append(tenpaiWhos, who)
+			}
+		}
+	}
+
+	deltaPoints = d._parseDeltaPoints(d.msg.Score)
+	return
+}
+
+// 解析 sc 中的增减分
+func (d *tenhouRoundData) _parseDeltaPoints(score string) (deltaPoints []int) {
+	if score == "" {
 		return
 	}
-	point, _ := strconv.Atoi(splits[1])
-	return []int{who}, []int{point}
+	splits := strings.Split(score, ",")
+	for i := 1; i < len(splits); i += 2 {
+		delta, _ := strconv.Atoi(splits[i])
+		deltaPoints = append(deltaPoints, 100*delta)
+	}
+	return
 }
 
 func (d *tenhouRoundData) IsNewDora() bool {
@@ -345,3 +726,78 @@ func (d *tenhouRoundData) ParseNewDora() (kanDoraIndicator int) {
 	kanDoraIndicator, _ = d._parseTenhouTile(d.msg.Hai)
 	return
 }
+
+//
+
+func (d *tenhouRoundData) Next() (Event, error) {
+	return d.events.next(d.msg, d.translate)
+}
+
+// 将天凤消息翻译成事件
+// 天凤的一条消息至多对应一个事件，杠宝牌、立直宣言和立直成功均为单独的消息
+func (d *tenhouRoundData) translate() (events []Event, err error) {
+	var event Event
+	switch {
+	case d.IsGameStart():
+		gameLength, playerNumber := d.ParseGameStart()
+		event = &GameStartEvent{GameLength: gameLength, PlayerNumber: playerNumber}
+	case d.IsInit():
+		roundNumber, dealer, doraIndicator, handTiles, numRedFives := d.ParseInit()
+		honba, riichiSticks, scores := d.ParseInitScores()
+		reinitPlayers, doraIndicators := d.ParseReinit()
+		if len(doraIndicators) == 0 {
+			doraIndicators = []int{doraIndicator}
+		}
+		event = &InitEvent{
+			RoundNumber:    roundNumber,
+			Dealer:         dealer,
+			DoraIndicators: doraIndicators,
+			HandTiles:      handTiles,
+			NumRedFives:    numRedFives,
+			Honba:          honba,
+			RiichiSticks:   riichiSticks,
+			Scores:         scores,
+			ReinitPlayers:  reinitPlayers,
+		}
+	case d.IsOpen():
+		who, meld := d.ParseOpen()
+		event = &CallEvent{Who: who, Meld: meld}
+	case d.IsKita():
+		event = &KitaEvent{Who: d.ParseKita()}
+	case d.IsReach():
+		event = &RiichiEvent{Who: d.ParseReach()}
+	case d.ParseReachSuccess() != -1:
+		event = &RiichiEvent{Who: d.ParseReachSuccess(), IsAccepted: true}
+	case d.IsFuriten():
+		event = &FuritenEvent{}
+	case d.IsSelfDraw():
+		tile, isRedFive, options := d.ParseSelfDraw()
+		event = &DrawEvent{Tile: tile, IsRedFive: isRedFive, Options: options}
+	case d.IsDiscard():
+		who, discardTile, isRedFive, isTsumogiri, options := d.ParseDiscard()
+		event = &DiscardEvent{
+			Who:         who,
+			Tile:        discardTile,
+			IsRedFive:   isRedFive,
+			IsTsumogiri: isTsumogiri,
+			Options:     options,
+			// 为防止先收到自家摸牌，然后收到上家舍牌，上家舍牌时不刷新
+			IsBeforeSelfDraw: who != 0 && who == d.kamicha(),
+		}
+	case d.IsRoundWin():
+		results, deltaPoints := d.ParseRoundWin()
+		event = newWinEvent(results, deltaPoints)
+	case d.IsRyuukyoku():
+		if _, ok := tenhouRyuukyokuTypeMap[d.msg.Type]; !ok {
+			return nil, fmt.Errorf("未知的流局类型 %s", d.msg.Type)
+		}
+		ryuukyokuType, tenpaiWhos, deltaPoints := d.ParseRyuukyoku()
+		event = &DrawGameEvent{Type: ryuukyokuType, TenpaiWhos: tenpaiWhos, DeltaPoints: deltaPoints}
+	case d.IsNewDora():
+		event = &DoraEvent{Indicator: d.ParseNewDora()}
+	default:
+		// 其他消息，如 HELO、UN、PROF 等
+		return
+	}
+	return []Event{event}, nil
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tenhou.net/6 的 JSON 牌谱
+// 格式为 {"name":[...],"rule":{"disp":"般南喰赤"},"log":[局, 局, ...]}
+// 每局为 [[场数,本场数,立直棒数], [各家点数], [宝牌指示牌], [里宝牌指示牌], 配牌0, 摸牌0, 舍牌0, 配牌1, ..., 舍牌3, [结果]]
+// 牌的写法：11-19 为 1-9m，21-29 为 1-9p，31-39 为 1-9s，41-47 为东南西北白发中，51-53 为赤5mps
+// 摸牌中的字符串为吃碰大明杠，如 c275226 p424242 39m393939，字母的位置表示从哪家鸣牌
+// 舍牌中 60 为摸切，r 开头为立直宣言牌，含 a/k 的字符串为暗杠/加杠，f44 为拔北，大明杠后的 0 为占位
+type tenhou6Log struct {
+	Name []string `json:"name"`
+	Rule struct {
+		Disp string `json:"disp"`
+	} `json:"rule"`
+	Log [][]json.RawMessage `json:"log"`
+}
+
+const (
+	tenhou6Tsumogiri   = 60
+	tenhou6Placeholder = 0
+)
+
+// 转换成 0-33 的牌
+func tenhou6Tile(code int) (tile34 int, isRedFive bool) {
+	switch {
+	case code >= 51 && code <= 53:
+		return (code-51)*9 + 4, true
+	case code >= 11 && code <= 39 && code%10 != 0:
+		return (code/10-1)*9 + code%10 - 1, false
+	case code >= 41 && code <= 47:
+		return 27 + code - 41, false
+	}
+	panic(fmt.Sprintf("无法解析 tenhou.net/6 的牌 %d", code))
+}
+
+// 解析鸣牌字符串，返回鸣牌类型、各张牌、被鸣的牌（暗杠/加杠为字母后的那张牌）和字母的位置
+func parseTenhou6Meld(s string) (meldType int, codes []int, calledCode int, letterAt int) {
+	letterAt = strings.IndexAny(s, "cpmka")
+	if letterAt == -1 || letterAt%2 != 0 || letterAt+3 > len(s) {
+		panic(fmt.Sprintf("无法解析 tenhou.net/6 的鸣牌 %s", s))
+	}
+	meldType = map[byte]int{
+		'c': meldTypeChi,
+		'p': meldTypePon,
+		'm': meldTypeMinkan,
+		'k': meldTypeKakan,
+		'a': meldTypeAnkan,
+	}[s[letterAt]]
+	digits := s[:letterAt] + s[letterAt+1:]
+	for i := 0; i+2 <= len(digits); i += 2 {
+		code, err := strconv.Atoi(digits[i : i+2])
+		if err != nil {
+			panic(fmt.Sprintf("无法解析 tenhou.net/6 的鸣牌 %s", s))
+		}
+		codes = append(codes, code)
+	}
+	calledCode = codes[letterAt/2]
+	return
+}
+
+// 将鸣牌字符串转换成副露
+func newTenhou6Meld(s string) (meld *model.Meld, calledCode int, letterAt int) {
+	meldType, codes, calledCode, letterAt := parseTenhou6Meld(s)
+	meld = &model.Meld{MeldType: meldType}
+	for _, code := range codes {
+		tile, isRedFive := tenhou6Tile(code)
+		meld.Tiles = append(meld.Tiles, tile)
+		if isRedFive {
+			meld.ContainRedFive = true
+		}
+	}
+	sort.Ints(meld.Tiles)
+	calledTile, isRedFive := tenhou6Tile(calledCode)
+	meld.CalledTile = calledTile
+	meld.RedFiveFromOthers = isRedFive && (meldType == meldTypeChi || meldType == meldTypePon || meldType == meldTypeMinkan)
+	return
+}
+
+//
+
+// 一局牌谱
+type tenhou6Round struct {
+	roundNumber    int
+	honba          int
+	riichiSticks   int
+	scores         []int
+	doraIndicators []int
+
+	haipais  [][]int
+	draws    [][]interface{} // 数字或者鸣牌字符串
+	discards [][]interface{} // 数字或者字符串
+}
+
+func parseTenhou6Round(raw []json.RawMessage) (r *tenhou6Round, err error) {
+	if len(raw) < 17 {
+		return nil, fmt.Errorf("牌谱数据有误：每局应有 17 项，实际为 %d 项", len(raw))
+	}
+	r = &tenhou6Round{}
+	info := []int{}
+	if err = json.Unmarshal(raw[0], &info); err != nil {
+		return
+	}
+	if len(info) < 3 {
+		return nil, fmt.Errorf("牌谱数据有误：%s", raw[0])
+	}
+	r.roundNumber, r.honba, r.riichiSticks = info[0], info[1], info[2]
+	if err = json.Unmarshal(raw[1], &r.scores); err != nil {
+		return
+	}
+	if err = json.Unmarshal(raw[2], &r.doraIndicators); err != nil {
+		return
+	}
+	for i := 4; i+2 < len(raw) && i < 16; i += 3 {
+		haipai := []int{}
+		draws := []interface{}{}
+		discards := []interface{}{}
+		if err = json.Unmarshal(raw[i], &haipai); err != nil {
+			return
+		}
+		if err = json.Unmarshal(raw[i+1], &draws); err != nil {
+			return
+		}
+		if err = json.Unmarshal(raw[i+2], &discards); err != nil {
+			return
+		}
+		r.haipais = append(r.haipais, haipai)
+		r.draws = append(r.draws, draws)
+		r.discards = append(r.discards, discards)
+	}
+	return
+}
+
+// 以 seat 为自家，将一局牌谱翻译成事件
+// 只用于还原每一巡的局面，所以不翻译和牌、流局
+func (r *tenhou6Round) events(seat int, playerNumber int) (events []Event) {
+	who := func(s int) int {
+		return (s - seat + playerNumber) % playerNumber
+	}
+	toTile := func(action interface{}) (code int, ok bool) {
+		f, ok := action.(float64)
+		return int(f), ok
+	}
+
+	dealer := r.roundNumber % 4
+	scores := make([]int, playerNumber)
+	for s := 0; s < playerNumber && s < len(r.scores); s++ {
+		scores[who(s)] = r.scores[s]
+	}
+	doraIndicator, _ := tenhou6Tile(r.doraIndicators[0])
+	handTiles := []int{}
+	numRedFives := make([]int, 3)
+	for _, code := range r.haipais[seat] {
+		tile, isRedFive := tenhou6Tile(code)
+		handTiles = append(handTiles, tile)
+		if isRedFive {
+			numRedFives[tile/9]++
+		}
+	}
+	events = append(events, &InitEvent{
+		RoundNumber:    r.roundNumber,
+		Dealer:         who(dealer),
+		DoraIndicators: []int{doraIndicator},
+		HandTiles:      handTiles,
+		NumRedFives:    numRedFives,
+		Honba:          r.honba,
+		RiichiSticks:   r.riichiSticks,
+		Scores:         scores,
+	})
+
+	drawAt := make([]int, playerNumber)
+	discardAt := make([]int, playerNumber)
+	lastDraws := make([]int, playerNumber)
+	doraAt := 1
+	isMinkanDoraPending := false // 大明杠的杠宝牌在岭上摸牌后翻开
+	newDora := func() {
+		if doraAt < len(r.doraIndicators) {
+			indicator, _ := tenhou6Tile(r.doraIndicators[doraAt])
+			events = append(events, &DoraEvent{Indicator: indicator})
+			doraAt++
+		}
+	}
+
+	p := dealer
+	needDraw := true
+	for {
+		if needDraw {
+			if drawAt[p] >= len(r.draws[p]) {
+				break
+			}
+			code, ok := toTile(r.draws[p][drawAt[p]])
+			if !ok {
+				panic(fmt.Sprintf("牌谱数据有误：座位 %d 的第 %d 次摸牌为 %v", p, drawAt[p]+1, r.draws[p][drawAt[p]]))
+			}
+			drawAt[p]++
+			lastDraws[p] = code
+			if p == seat {
+				tile, isRedFive := tenhou6Tile(code)
+				events = append(events, &DrawEvent{Tile: tile, IsRedFive: isRedFive})
+			}
+			if isMinkanDoraPending {
+				newDora()
+				isMinkanDoraPending = false
+			}
+		}
+		needDraw = true
+
+		// 自摸和了时没有舍牌
+		if discardAt[p] >= len(r.discards[p]) {
+			break
+		}
+		action := r.discards[p][discardAt[p]]
+		discardAt[p]++
+
+		isReach := false
+		code, ok := toTile(action)
+		if !ok {
+			s := action.(string)
+			switch {
+			case strings.HasPrefix(s, "r"):
+				isReach = true
+				code, _ = strconv.Atoi(s[1:])
+				events = append(events, &RiichiEvent{Who: who(p)})
+			case strings.HasPrefix(s, "f"):
+				// 拔北后从岭上摸牌
+				events = append(events, &KitaEvent{Who: who(p)})
+				continue
+			default:
+				// 暗杠、加杠后从岭上摸牌
+				meld, _, _ := newTenhou6Meld(s)
+				events = append(events, &CallEvent{Who: who(p), Meld: meld})
+				newDora()
+				continue
+			}
+		}
+
+		isTsumogiri := code == tenhou6Tsumogiri
+		if isTsumogiri {
+			code = lastDraws[p]
+		}
+		tile, isRedFive := tenhou6Tile(code)
+		events = append(events, &DiscardEvent{
+			Who:         who(p),
+			Tile:        tile,
+			IsRedFive:   isRedFive,
+			IsTsumogiri: isTsumogiri,
+		})
+
+		// 是否有人鸣了这张牌
+		next := (p + 1) % playerNumber
+		for offset := 1; offset < playerNumber; offset++ {
+			q := (p + offset) % playerNumber
+			if drawAt[q] >= len(r.draws[q]) {
+				continue
+			}
+			s, ok := r.draws[q][drawAt[q]].(string)
+			if !ok {
+				continue
+			}
+			meld, calledCode, letterAt := newTenhou6Meld(s)
+			// 字母在最前为上家，在第二张牌前为对家，否则为下家
+			from := (q + playerNumber - 1) % playerNumber
+			if letterAt == 2 {
+				from = (q + 2) % playerNumber
+			} else if letterAt > 2 {
+				from = (q + 1) % playerNumber
+			}
+			if from != p || calledCode != code {
+				continue
+			}
+
+			drawAt[q]++
+			events = append(events, &CallEvent{Who: who(q), Meld: meld})
+			next = q
+			if meld.MeldType == meldTypeMinkan {
+				if discardAt[q] < len(r.discards[q]) {
+					if placeholder, ok := toTile(r.discards[q][discardAt[q]]); ok && placeholder == tenhou6Placeholder {
+						discardAt[q]++
+					}
+				}
+				isMinkanDoraPending = true
+			} else {
+				needDraw = false
+			}
+			break
+		}
+
+		// 立直宣言牌没有被荣和
+		if isReach && (!needDraw || drawAt[next] < len(r.draws[next])) {
+			events = append(events, &RiichiEvent{Who: who(p), IsAccepted: true})
+		}
+		p = next
+	}
+	return
+}
+
+//
+
+// 某一巡自家摸牌后的局面
+type tenhou6Snapshot struct {
+	roundNumber int
+	honba       int
+	turn        int // 第几次摸牌，从 1 开始
+
+	playerInfo *model.PlayerInfo
+	riskTables riskInfoList
+}
+
+func (s *tenhou6Snapshot) name() string {
+	return trf("%s%d局 %d本场 第%d巡", tileName(27+s.roundNumber/4), s.roundNumber%4+1, s.honba, s.turn)
+}
+
+// 用于还原局面，按顺序返回事件
+type tenhou6RoundData struct {
+	*roundData
+	events []Event
+}
+
+func (d *tenhou6RoundData) GetDataSourceType() int {
+	return dataSourceTypeTenhou
+}
+
+func (d *tenhou6RoundData) GetMessage() string {
+	return ""
+}
+
+func (d *tenhou6RoundData) CheckMessage() bool {
+	return true
+}
+
+func (d *tenhou6RoundData) Next() (Event, error) {
+	if len(d.events) == 0 {
+		return nil, nil
+	}
+	event := d.events[0]
+	d.events = d.events[1:]
+	return event, nil
+}
+
+// 复制一份 PlayerInfo，之后的局面变化不会影响到它
+func copyPlayerInfo(pi *model.PlayerInfo) *model.PlayerInfo {
+	copyInts := func(a []int) []int {
+		return append([]int(nil), a...)
+	}
+	c := *pi
+	c.HandTiles34 = copyInts(pi.HandTiles34)
+	c.NumRedFives = copyInts(pi.NumRedFives)
+	c.LeftTiles34 = copyInts(pi.LeftTiles34)
+	c.DiscardTiles = copyInts(pi.DiscardTiles)
+	c.Melds = append([]model.Meld(nil), pi.Melds...)
+	return &c
+}
+
+// 解析 tenhou.net/6 牌谱，返回 seat 每次摸牌后的局面
+func loadTenhou6Snapshots(data []byte, seat int) (snapshots []*tenhou6Snapshot, err error) {
+	paifu := tenhou6Log{}
+	if err = json.Unmarshal(data, &paifu); err != nil {
+		return
+	}
+
+	playerNumber := 4
+	if strings.Contains(paifu.Rule.Disp, "三") || len(paifu.Name) == 3 {
+		playerNumber = 3
+	}
+	if seat < 0 || seat >= playerNumber {
+		return nil, fmt.Errorf("座位 %d 超出了玩家人数", seat)
+	}
+	gameLength := gameLengthHanchan
+	if strings.Contains(paifu.Rule.Disp, "東") || strings.Contains(paifu.Rule.Disp, "东") {
+		gameLength = gameLengthTonpuu
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("牌谱解析失败：%v", r)
+		}
+	}()
+
+	d := &tenhou6RoundData{}
+	d.roundData = newRoundData(d, 0, 0)
+	d.skipOutput = true
+	d.events = []Event{&GameStartEvent{GameLength: gameLength, PlayerNumber: playerNumber}}
+	if err = d.analysis(); err != nil {
+		return
+	}
+
+	for _, rawRound := range paifu.Log {
+		var round *tenhou6Round
+		round, err = parseTenhou6Round(rawRound)
+		if err != nil {
+			return
+		}
+		turn := 0
+		for _, event := range round.events(seat, playerNumber) {
+			d.events = []Event{event}
+			if err = d.analysis(); err != nil {
+				return
+			}
+			if _, ok := event.(*DrawEvent); ok {
+				turn++
+				riskTables := d.analysisTilesRisk()
+				snapshots = append(snapshots, &tenhou6Snapshot{
+					roundNumber: round.roundNumber,
+					honba:       round.honba,
+					turn:        turn,
+					playerInfo:  copyPlayerInfo(d.newModelPlayerInfo(riskTables)),
+					riskTables:  riskTables,
+				})
+			}
+		}
+	}
+	return
+}
+
+// 解析局名，如 E3 或东3 为东3局，E3-1 为东3局1本场
+// 不指定本场数时 honba 为 -1
+func parseRoundName(name string) (roundNumber int, honba int, err error) {
+	honba = -1
+	roundName := name
+	if splits := strings.SplitN(name, "-", 2); len(splits) == 2 {
+		roundName = splits[0]
+		if honba, err = strconv.Atoi(splits[1]); err != nil {
+			return 0, 0, fmt.Errorf("无法解析局名 %s", name)
+		}
+	}
+	runes := []rune(roundName)
+	if len(runes) != 2 || runes[1] < '1' || runes[1] > '4' {
+		return 0, 0, fmt.Errorf("无法解析局名 %s", name)
+	}
+	wind := -1
+	for i, w := range []rune("ESWN") {
+		if runes[0] == w || runes[0] == []rune("东南西北")[i] {
+			wind = i
+		}
+	}
+	if wind == -1 {
+		return 0, 0, fmt.Errorf("无法解析局名 %s", name)
+	}
+	roundNumber = 4*wind + int(runes[1]-'1')
+	return
+}
+
+// 分析 tenhou.net/6 牌谱中 seat 的局面
+// roundName 为空时列出所有局面，turn 为 0 时分析该局的每一巡
+func runTenhou6Analysis(path string, seat int, roundName string, turn int) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		errorExit(err)
+	}
+	snapshots, err := loadTenhou6Snapshots(data, seat)
+	if err != nil {
+		errorExit(err)
+	}
+
+	if roundName == "" {
+		for _, s := range snapshots {
+			fmt.Printf("%s: %s\n", s.name(), util.Tiles34ToStr(s.playerInfo.HandTiles34))
+		}
+		return
+	}
+
+	roundNumber, honba, err := parseRoundName(roundName)
+	if err != nil {
+		errorExit(err)
+	}
+	found := false
+	for _, s := range snapshots {
+		if s.roundNumber != roundNumber || honba != -1 && s.honba != honba || turn > 0 && s.turn != turn {
+			continue
+		}
+		found = true
+		fmt.Println(s.name())
+		outputRenderer.renderRisks(s.riskTables, s.playerInfo.HandTiles34, s.playerInfo.LeftTiles34)
+		if _, err := analysisTiles34(s.playerInfo, s.riskTables.mixedRiskTable()); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if !found {
+		errorExit(fmt.Errorf("牌谱中没有 %s 第 %d 巡的局面", roundName, turn))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTenhou6Meld(t *testing.T) {
+	meld, calledCode, letterAt := newTenhou6Meld("c275226")
+	if meld.MeldType != meldTypeChi || calledCode != 27 || letterAt != 0 || meld.CalledTile != 15 || !meld.ContainRedFive || meld.RedFiveFromOthers {
+		t.Fatal("吃解析有误", meld, calledCode)
+	}
+	meld, calledCode, letterAt = newTenhou6Meld("39m393939")
+	if meld.MeldType != meldTypeMinkan || calledCode != 39 || letterAt != 2 || len(meld.Tiles) != 4 || meld.Tiles[0] != 26 {
+		t.Fatal("大明杠解析有误", meld, calledCode)
+	}
+	meld, _, _ = newTenhou6Meld("424242a42")
+	if meld.MeldType != meldTypeAnkan || meld.CalledTile != 28 {
+		t.Fatal("暗杠解析有误", meld)
+	}
+}
+
+func TestParseRoundName(t *testing.T) {
+	for name, expected := range map[string][2]int{"E3": {2, -1}, "东3": {2, -1}, "S1-2": {4, 2}, "南4": {7, -1}} {
+		roundNumber, honba, err := parseRoundName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if roundNumber != expected[0] || honba != expected[1] {
+			t.Fatal(name, roundNumber, honba)
+		}
+	}
+	for _, name := range []string{"", "E5", "X1", "E1-x"} {
+		if _, _, err := parseRoundName(name); err == nil {
+			t.Fatal(name, "应该解析失败")
+		}
+	}
+	if _, _, err := parseRoundName("S1-x"); err == nil || !strings.Contains(err.Error(), "S1-x") {
+		t.Fatal("错误信息中应为完整的局名", err)
+	}
+}
+
+func TestTenhou6MinkanDora(t *testing.T) {
+	// 庄家手切 1m，自家（南家）大明杠后从岭上摸 2m 并摸切
+	r := &tenhou6Round{
+		scores:         []int{25000, 25000, 25000, 25000},
+		doraIndicators: []int{21, 31},
+		haipais:        [][]int{{11, 12, 13, 14, 15, 16, 17, 18, 19, 21, 23, 24, 25}, {11, 11, 11, 31, 32, 33, 34, 35, 36, 37, 38, 39, 41}, {}, {}},
+		draws:          [][]interface{}{{21.0}, {"m11111111", 12.0}, {}, {}},
+		discards:       [][]interface{}{{11.0}, {0.0, 60.0}, {}, {}},
+	}
+	drawAt, doraAt := -1, -1
+	for i, event := range r.events(1, 4) {
+		switch e := event.(type) {
+		case *DrawEvent:
+			drawAt = i
+		case *DoraEvent:
+			doraAt = i
+			if e.Indicator != 18 {
+				t.Fatal("杠宝牌指示牌有误", e.Indicator)
+			}
+		}
+	}
+	if drawAt == -1 || doraAt != drawAt+1 {
+		t.Fatal("大明杠的杠宝牌应在岭上摸牌后翻开", drawAt, doraAt)
+	}
+}
+
+func TestLoadTenhou6Snapshots(t *testing.T) {
+	debugMode = true
+
+	paifu := `{"name":["a","b","c","d"],"rule":{"disp":"般南喰赤","aka":1},"log":[[
+[0,0,0],[25000,25000,25000,25000],[21],[],
+[11,12,13,14,15,16,17,18,19,21,22,23,24],[41,22],[41,60],
+[41,41,31,32,33,34,35,36,37,38,25,26,27],["p414141"],[27],
+[42,42,43,43,44,44,28,29,29,28,39,39,45],[11],[60],
+[46,46,47,47,11,12,13,14,15,16,17,18,19],[21],["r60"],
+["流局",[0,0,0,0]]]]}`
+
+	snapshots, err := loadTenhou6Snapshots([]byte(paifu), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatal("局面数有误", len(snapshots))
+	}
+
+	s := snapshots[1]
+	if s.roundNumber != 0 || s.turn != 2 || s.name() != "东1局 0本场 第2巡" {
+		t.Fatal("局面信息有误", s.name())
+	}
+	pi := s.playerInfo
+	if pi.HandTiles34[10] != 2 || len(pi.DiscardTiles) != 1 || pi.DiscardTiles[0] != 27 || !pi.IsParent {
+		t.Fatal("手牌或牌河有误", pi.HandTiles34, pi.DiscardTiles)
+	}
+	if len(pi.DoraTiles) != 1 || pi.DoraTiles[0] != 10 {
+		t.Fatal("宝牌有误", pi.DoraTiles)
+	}
+	// 下家碰了三张东，上家摸切立直 1p，对家摸切 1m
+	if pi.LeftTiles34[27] != 1 || pi.LeftTiles34[9] != 1 || pi.LeftTiles34[0] != 2 {
+		t.Fatal("剩余牌有误", pi.LeftTiles34)
+	}
+	if s.riskTables[3].tenpaiRate != 100 {
+		t.Fatal("上家应该已立直", s.riskTables[3].tenpaiRate)
+	}
+
+	// 自家在第 1 巡的手牌不受之后的影响
+	if snapshots[0].playerInfo.HandTiles34[27] != 1 || snapshots[0].playerInfo.HandTiles34[10] != 1 {
+		t.Fatal("第 1 巡的手牌有误", snapshots[0].playerInfo.HandTiles34)
+	}
+}
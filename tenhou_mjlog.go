@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// 天凤牌谱（mjlog）导入
+// mjlog 为 XML 格式，其标签与网页版天凤的消息一致，区别在于：
+// - 座位均为绝对座位（0 为第一局的东家），而网页版的消息中 0 为自家
+// - INIT 中有四家的手牌 hai0-hai3，T/U/V/W 中有四家摸到的牌
+// - 舍牌均为大写，不区分手切摸切
+// - 没有表示能否鸣牌的 t
+// 这里以某个座位的视角，将 mjlog 转换成网页版的消息，交给 tenhouRoundData 解析
+
+const mjlogRootTag = "mjloggm"
+
+// 读取 mjlog 文件，下载的 .mjlog 为 gzip 压缩的 XML
+// 不是 mjlog 时 isMjlog 为 false
+func readMjlogFile(path string) (data []byte, isMjlog bool, err error) {
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		var reader *gzip.Reader
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		defer reader.Close()
+		data, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return
+		}
+	}
+	isMjlog = bytes.Contains(data[:util.MinInt(len(data), 512)], []byte("<"+mjlogRootTag))
+	return
+}
+
+// mjlog 中的一个标签
+type mjlogTag struct {
+	name  string
+	attrs map[string]string
+}
+
+func parseMjlogTags(data []byte) (tags []*mjlogTag, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return tags, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local == mjlogRootTag {
+			continue
+		}
+		tag := &mjlogTag{name: start.Name.Local, attrs: map[string]string{}}
+		for _, attr := range start.Attr {
+			tag.attrs[attr.Name.Local] = attr.Value
+		}
+		tags = append(tags, tag)
+	}
+}
+
+// 将 mjlog 转换成以 seat 为自家的网页版消息
+type mjlogConverter struct {
+	seat         int
+	playerNumber int
+
+	// 自家手牌，用于生成表示能否鸣牌的 t
+	counts    []int
+	isReached bool
+
+	// 各家最近摸到的牌（天凤的牌编号），用于判断摸切
+	drawTiles []int
+
+	// 复用 tenhouRoundData 的牌和副露解析
+	parser *tenhouRoundData
+}
+
+func newMjlogConverter(seat int) *mjlogConverter {
+	return &mjlogConverter{
+		seat:         seat,
+		playerNumber: 4,
+		counts:       make([]int, 34),
+		drawTiles:    []int{-1, -1, -1, -1},
+		parser:       &tenhouRoundData{},
+	}
+}
+
+// 绝对座位转换成 0=自家, 1=下家, 2=对家, 3=上家
+func (c *mjlogConverter) who(seat int) int {
+	return (seat - c.seat + c.playerNumber) % c.playerNumber
+}
+
+func (c *mjlogConverter) whoStr(seat string) string {
+	s, err := strconv.Atoi(seat)
+	if err != nil {
+		panic(fmt.Sprintln("座位解析失败", seat))
+	}
+	return strconv.Itoa(c.who(s))
+}
+
+// 将按座位排列的逗号分隔的值转换成按 0=自家, 1=下家, 2=对家, 3=上家 排列
+// groupSize 为每个座位对应的值的个数，如 sc 中每个座位有原点数和增减分两个值
+// 三人麻将时天凤仍会给出 4 家的值，多余的保持原位
+func (c *mjlogConverter) rotate(values string, groupSize int) string {
+	if values == "" {
+		return values
+	}
+	splits := strings.Split(values, ",")
+	if len(splits) < c.playerNumber*groupSize {
+		return values
+	}
+	rotated := make([]string, len(splits))
+	copy(rotated, splits)
+	for seat := 0; seat < c.playerNumber; seat++ {
+		who := c.who(seat)
+		copy(rotated[who*groupSize:(who+1)*groupSize], splits[seat*groupSize:(seat+1)*groupSize])
+	}
+	return strings.Join(rotated, ",")
+}
+
+func (c *mjlogConverter) parseTile(tenhouTile string) int {
+	tile, _ := c.parser._parseTenhouTile(tenhouTile)
+	return tile
+}
+
+// 他家舍牌时，自家能否碰、大明杠、吃（位标志与网页版天凤相同：1=碰, 2=大明杠, 4=吃）
+func (c *mjlogConverter) meldFlags(who int, tile int) (flags int) {
+	if c.isReached {
+		return
+	}
+	if c.counts[tile] >= 2 {
+		flags |= 1
+	}
+	if c.counts[tile] == 3 {
+		flags |= 2
+	}
+	if who == c.playerNumber-1 && c.playerNumber == 4 && tile < 27 {
+		has := func(t int) bool { return t/9 == tile/9 && t >= 0 && c.counts[t] > 0 }
+		if has(tile-2) && has(tile-1) || has(tile-1) && has(tile+1) || has(tile+1) && has(tile+2) {
+			flags |= 4
+		}
+	}
+	return
+}
+
+// 转换一个标签，不需要的标签返回 nil
+func (c *mjlogConverter) convert(tag *mjlogTag) map[string]string {
+	msg := map[string]string{"tag": tag.name}
+	attrs := tag.attrs
+
+	switch name := tag.name; {
+	case name == "GO":
+		msg["type"] = attrs["type"]
+		gameType, _ := strconv.Atoi(attrs["type"])
+		c.playerNumber = 4
+		if gameType&0x10 > 0 {
+			c.playerNumber = 3
+		}
+		if c.seat >= c.playerNumber {
+			panic(fmt.Sprintf("座位 %d 超出了玩家人数", c.seat))
+		}
+	case name == "INIT":
+		msg["seed"] = attrs["seed"]
+		msg["ten"] = c.rotate(attrs["ten"], 1)
+		msg["oya"] = c.whoStr(attrs["oya"])
+		msg["hai"] = attrs["hai"+strconv.Itoa(c.seat)]
+
+		c.counts = make([]int, 34)
+		c.isReached = false
+		c.drawTiles = []int{-1, -1, -1, -1}
+		for _, tenhouTile := range strings.Split(msg["hai"], ",") {
+			c.counts[c.parseTile(tenhouTile)]++
+		}
+	case len(name) >= 2 && strings.IndexByte("TUVW", name[0]) != -1 && isDigits(name[1:]):
+		seat := int(name[0] - 'T')
+		tenhouTile, _ := strconv.Atoi(name[1:])
+		c.drawTiles[seat] = tenhouTile
+		if seat != c.seat {
+			// 网页版看不到他家摸的牌
+			return nil
+		}
+		c.counts[tenhouTile/4]++
+		msg["tag"] = "T" + name[1:]
+	case len(name) >= 2 && strings.IndexByte("DEFG", name[0]) != -1 && isDigits(name[1:]):
+		seat := int(name[0] - 'D')
+		tenhouTile, _ := strconv.Atoi(name[1:])
+		who := c.who(seat)
+		isTsumogiri := tenhouTile == c.drawTiles[seat]
+		c.drawTiles[seat] = -1
+		if who == 0 {
+			c.counts[tenhouTile/4]--
+			msg["tag"] = "D" + name[1:]
+			break
+		}
+		// 他家摸切为小写
+		letter := byte('D' + who)
+		if isTsumogiri {
+			letter = lower(letter)
+		}
+		msg["tag"] = string(letter) + name[1:]
+		if flags := c.meldFlags(who, tenhouTile/4); flags > 0 {
+			msg["t"] = strconv.Itoa(flags)
+		}
+	case name == "N":
+		msg["who"] = c.whoStr(attrs["who"])
+		msg["m"] = attrs["m"]
+		if msg["who"] != "0" {
+			break
+		}
+		// 自家鸣牌，从手牌中去掉对应的牌
+		if c.parser._isKita(attrs["m"]) {
+			const kitaTile = 30
+			c.counts[kitaTile]--
+			break
+		}
+		meldType, tenhouMeldTiles, tenhouCalledTile := c.parser._parseTenhouMeld(attrs["m"])
+		switch meldType {
+		case meldTypeKakan:
+			c.counts[tenhouCalledTile/4]--
+		case meldTypeAnkan:
+			c.counts[tenhouCalledTile/4] -= 4
+		default:
+			for _, tenhouTile := range tenhouMeldTiles {
+				if tenhouTile != tenhouCalledTile {
+					c.counts[tenhouTile/4]--
+				}
+			}
+		}
+	case name == "REACH":
+		msg["who"] = c.whoStr(attrs["who"])
+		msg["step"] = attrs["step"]
+		msg["ten"] = c.rotate(attrs["ten"], 1)
+		if msg["who"] == "0" && msg["step"] == "1" {
+			c.isReached = true
+		}
+	case name == "DORA":
+		msg["hai"] = attrs["hai"]
+	case name == "AGARI":
+		for key, value := range attrs {
+			msg[key] = value
+		}
+		msg["who"] = c.whoStr(attrs["who"])
+		msg["fromWho"] = c.whoStr(attrs["fromWho"])
+		msg["sc"] = c.rotate(attrs["sc"], 2)
+		if owari, ok := attrs["owari"]; ok {
+			msg["owari"] = c.rotate(owari, 2)
+		}
+	case name == "RYUUKYOKU":
+		for key, value := range attrs {
+			msg[key] = value
+		}
+		msg["sc"] = c.rotate(attrs["sc"], 2)
+		if owari, ok := attrs["owari"]; ok {
+			msg["owari"] = c.rotate(owari, 2)
+		}
+		for seat := 0; seat < 4; seat++ {
+			delete(msg, "hai"+strconv.Itoa(seat))
+		}
+		for seat := 0; seat < c.playerNumber; seat++ {
+			if hai, ok := attrs["hai"+strconv.Itoa(seat)]; ok {
+				msg["hai"+strconv.Itoa(c.who(seat))] = hai
+			}
+		}
+	default:
+		// SHUFFLE, UN, TAIKYOKU, BYE 等
+		return nil
+	}
+	return msg
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// 读取 mjlog，以 seat 的视角转换成重放用的网页版天凤消息
+// 消息的行号为其在 mjlog 中是第几个标签
+func loadMjlogMessages(data []byte, seat int) (messages []*replayMessage, err error) {
+	tags, err := parseMjlogTags(data)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mjlog 解析失败：%v", r)
+		}
+	}()
+
+	c := newMjlogConverter(seat)
+	for i, tag := range tags {
+		msg := c.convert(tag)
+		if msg == nil {
+			continue
+		}
+		var data []byte
+		data, err = json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		messages = append(messages, &replayMessage{lo: i + 1, data: data})
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testMjlog = `<mjloggm ver="2.3"><SHUFFLE seed="mt19937ar-sha512-n288-base64,xxx" ref=""/><GO type="169" lobby="0"/><UN n0="a" n1="b" n2="c" n3="d" dan="0,0,0,0" rate="1500.00,1500.00,1500.00,1500.00" sx="M,M,M,M"/><TAIKYOKU oya="0"/>` +
+	`<INIT seed="0,0,0,2,1,38" ten="250,240,260,250" oya="0" hai0="36,40,44,48,53,56,60,64,68,72,76,80,84" hai1="108,109,0,4,8,12,20,24,28,112,116,120,124" hai2="1,5,9,13,17,21,25,29,33,2,6,10,14" hai3="37,41,45,49,57,61,65,69,73,77,81,85,89"/>` +
+	`<T96/><D96/><U125/><E112/><V110/><F110/><N who="1" m="42601" /><E116/>` +
+	`<RYUUKYOKU ba="0,0" sc="250,0,240,0,260,0,250,0" owari="250,0.0,240,0.0,260,0.0,250,0.0" /></mjloggm>`
+
+func TestLoadMjlogMessages(t *testing.T) {
+	messages, err := loadMjlogMessages([]byte(testMjlog), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTags := []string{"GO", "INIT", "g96", "T125", "D112", "e110", "N", "D116", "RYUUKYOKU"}
+	if len(messages) != len(expectedTags) {
+		t.Fatal("消息数有误", len(messages))
+	}
+	msgs := make([]tenhouMessage, len(messages))
+	for i, message := range messages {
+		if err := json.Unmarshal(message.data, &msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+		if msgs[i].Tag != expectedTags[i] {
+			t.Fatal("标签有误", i, msgs[i].Tag)
+		}
+	}
+
+	init := msgs[1]
+	if init.Dealer != "3" || init.Ten != "240,260,250,250" || init.Hai != "108,109,0,4,8,12,20,24,28,112,116,120,124" {
+		t.Fatal("INIT 转换有误", init)
+	}
+	if msgs[2].T != "" || msgs[5].T != "1" {
+		t.Fatal("鸣牌选项有误", msgs[2].T, msgs[5].T)
+	}
+	if msgs[6].Who != "0" {
+		t.Fatal("副露者有误", msgs[6].Who)
+	}
+	if msgs[8].Score != "240,0,260,0,250,0,250,0" {
+		t.Fatal("sc 转换有误", msgs[8].Score)
+	}
+}
+
+func TestReplayMjlog(t *testing.T) {
+	debugMode = true
+
+	// 下载的 mjlog 为 gzip 压缩的 XML
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(testMjlog))
+	w.Close()
+
+	file, err := ioutil.TempFile("", "mjlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(buf.Bytes())
+	file.Close()
+
+	r, err := newReplayer(file.Name(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.dataSourceType != dataSourceTypeTenhou {
+		t.Fatal("数据来源有误")
+	}
+	if errorCount := r.runAll(false); errorCount > 0 {
+		t.Fatal("重放出错", errorCount)
+	}
+	if len(r.rounds) != 1 {
+		t.Fatal("局数有误", len(r.rounds))
+	}
+
+	r.reset()
+	r.seek(6) // 自家碰东
+	rd := r.roundData()
+	if len(rd.players[0].melds) != 1 || rd.players[0].melds[0].CalledTile != 27 || rd.counts[27] != 0 {
+		t.Fatal("碰有误", rd.players[0].melds, rd.counts)
+	}
+	if rd.dealer != 3 || len(rd.players[3].discardTiles) != 1 || rd.players[3].discardTiles[0] != ^24 {
+		t.Fatal("舍牌有误", rd.dealer, rd.players[3].discardTiles)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"github.com/EndlessCheng/mahjong-helper/util"
 	"github.com/EndlessCheng/mahjong-helper/util/model"
@@ -60,7 +62,7 @@ func TestAnalysisTilesRisk(t *testing.T) {
 	d.players[3].latestDiscardAtGlobal = 10
 
 	table := d.analysisTilesRisk()
-	table.printWithHands(handsTiles34, d.leftCounts)
+	outputRenderer.renderRisks(table, handsTiles34, d.leftCounts)
 }
 
 func TestReg(t *testing.T) {
@@ -88,3 +90,207 @@ func TestReg(t *testing.T) {
 	d.msg.Tag = "E123123"
 	t.Log(d.IsDiscard() == false)
 }
+
+func TestTenhouReinit(t *testing.T) {
+	debugMode = true
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	d.msg = &tenhouMessage{
+		Tag:    "REINIT",
+		Seed:   "1,0,0,3,2,92",
+		Ten:    "250,250,240,250",
+		Dealer: "1",
+		Hai:    "30,60,108,31,78,107,25,23,2,14,122,44,49",
+		Kawa0:  "100,73,3",
+		Kawa1:  "131,43,255,98",
+		Kawa2:  "116,4,8",
+		Kawa3:  "132,120",
+		Meld3:  "43595",
+	}
+	if err := d.analysis(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.globalDiscardTiles) != 11 {
+		t.Fatal("牌河恢复有误", d.globalDiscardTiles)
+	}
+	if !d.players[1].isReached || d.players[1].reachTileAt != 2 {
+		t.Fatal("立直恢复有误", *d.players[1])
+	}
+	if len(d.players[3].melds) != 1 || !d.players[3].isNaki {
+		t.Fatal("副露恢复有误", *d.players[3])
+	}
+}
+
+func TestTenhouRyuukyoku(t *testing.T) {
+	d := &tenhouRoundData{
+		msg: &tenhouMessage{
+			Tag:   "RYUUKYOKU",
+			Score: "250,15,250,-15,240,15,260,-15",
+			Hai0:  "1,2,3",
+			Hai2:  "4,5,6",
+		},
+	}
+	ryuukyokuType, tenpaiWhos, deltaPoints := d.ParseRyuukyoku()
+	if ryuukyokuType != ryuukyokuTypeExhaustive {
+		t.Fatal("应当为荒牌流局", ryuukyokuType)
+	}
+	if !reflect.DeepEqual(tenpaiWhos, []int{0, 2}) {
+		t.Fatal("听牌者有误", tenpaiWhos)
+	}
+	if !reflect.DeepEqual(deltaPoints, []int{1500, -1500, 1500, -1500}) {
+		t.Fatal("点数变化有误", deltaPoints)
+	}
+
+	// 途中流局没有听牌者和点数变化
+	d.msg = &tenhouMessage{Tag: "RYUUKYOKU", Type: "yao9", Score: "250,0,250,0,240,0,260,0"}
+	ryuukyokuType, tenpaiWhos, deltaPoints = d.ParseRyuukyoku()
+	if ryuukyokuType != ryuukyokuTypeKyuushuKyuuhai {
+		t.Fatal("应当为九种九牌", ryuukyokuType)
+	}
+	if len(tenpaiWhos) != 0 {
+		t.Fatal("听牌者有误", tenpaiWhos)
+	}
+	for _, delta := range deltaPoints {
+		if delta != 0 {
+			t.Fatal("点数变化有误", deltaPoints)
+		}
+	}
+}
+
+func TestTenhouGameScores(t *testing.T) {
+	debugMode = true
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, msg := range []*tenhouMessage{
+		{Tag: "GO", Type: "9"},
+		{Tag: "INIT", Seed: "4,1,1,3,2,92", Ten: "250,240,250,250", Dealer: "2", Hai: "30,60,108,31,78,107,25,23,2,14,122,44,49"},
+		{Tag: "REACH", Who: "1", Step: "2", Ten: "250,230,250,250"},
+		{Tag: "AGARI", Who: "1", Ten: "30,7700,0", Score: "250,-80,230,100,250,0,250,0"},
+	} {
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if d.game.gameLength != gameLengthHanchan || d.game.honba != 1 || d.game.riichiSticks != 0 {
+		t.Fatal("场况有误", *d.game)
+	}
+	if d.game.scores[0] != 17000 || d.game.scores[1] != 33000 {
+		t.Fatal("点数有误", d.game.scores)
+	}
+}
+
+func TestTenhouSanma(t *testing.T) {
+	debugMode = true
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, msg := range []*tenhouMessage{
+		{Tag: "GO", Type: "25"},
+		{Tag: "INIT", Seed: "4,0,0,1,2,36", Ten: "350,350,350,0", Dealer: "1", Hai: "0,1,32,40,44,48,72,76,80,108,112,116,120"},
+		{Tag: "N", Who: "1", Meld: "31008"},
+		{Tag: "F65"},
+		{Tag: "T123"},
+		{Tag: "N", Who: "0", Meld: "31520"},
+	} {
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(d.players) != 3 || d.players[2].name != "上家" {
+		t.Fatal("玩家人数有误", len(d.players))
+	}
+	if d.roundWindTile != 28 || d.players[0].selfWindTile != 29 {
+		t.Fatal("场风或自风有误", d.roundWindTile, d.players[0].selfWindTile)
+	}
+	if d.game.scores[0] != 35000 {
+		t.Fatal("点数有误", d.game.scores)
+	}
+	if d.players[0].nukiDoraCount != 1 || d.players[1].nukiDoraCount != 1 || d.counts[30] != 1 || d.leftCounts[30] != 1 {
+		t.Fatal("拔北有误", d.players[0].nukiDoraCount, d.players[1].nukiDoraCount, d.counts[30], d.leftCounts[30])
+	}
+	if d.leftCounts[1] != 0 || d.leftCounts[7] != 0 {
+		t.Fatal("三人麻将不应有 2-8m", d.leftCounts)
+	}
+	if dl := d.doraList(); len(dl) != 1 || dl[0] != 10 {
+		t.Fatal("宝牌有误", dl)
+	}
+	pi := d.newModelPlayerInfo(nil)
+	if !pi.IsSanma || pi.NukiDoraCount != 1 {
+		t.Fatal("PlayerInfo 有误", pi.IsSanma, pi.NukiDoraCount)
+	}
+}
+
+func TestTenhouRiichiFuriten(t *testing.T) {
+	debugMode = true
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, msg := range []*tenhouMessage{
+		{Tag: "INIT", Seed: "0,0,0,1,2,36", Ten: "250,250,250,250", Dealer: "0", Hai: "0,4,8,48,53,56,96,100,104,108,109,110,89"},
+		{Tag: "T112"},
+		{Tag: "REACH", Who: "0", Step: "1"},
+		{Tag: "D112"},
+		{Tag: "REACH", Who: "0", Step: "2"},
+		{Tag: "F90"}, // 对家放出和了牌 5s，见逃
+		{Tag: "G120"},
+		{Tag: "T111"},
+	} {
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !d.players[0].isRiichiFuriten {
+		t.Fatal("应为立直振听")
+	}
+	if !d.canRiichiAnkan(27, util.Waits{22: 1}) {
+		t.Fatal("暗杠东不改变听牌")
+	}
+	if left := d.liveWallLeft(); left != 136-14-13*4-3 {
+		t.Fatal("牌山剩余数有误", left)
+	}
+}
+
+func TestUraDoraProbabilities(t *testing.T) {
+	probs := uraDoraProbabilities(2)
+	sum, avg := 0.0, 0.0
+	for uraDora, p := range probs {
+		sum += p
+		avg += p * float64(uraDora)
+	}
+	if len(probs) != 3 || math.Abs(sum-1) > 1e-9 || math.Abs(avg-2*avgUraDoraPerIndicator) > 1e-9 {
+		t.Fatal("里宝牌个数的分布有误", probs)
+	}
+}
+
+func TestTenhouOptions(t *testing.T) {
+	d := &tenhouRoundData{}
+
+	// 上家舍牌，可以吃碰荣和
+	d.msg = &tenhouMessage{Tag: "G52", T: "13"}
+	if _, _, _, _, options := d.ParseDiscard(); options == nil || !options.Chi || !options.Pon || options.Minkan || !options.Ron || !options.canCall() {
+		t.Fatal("舍牌选项有误", options)
+	}
+	d.msg = &tenhouMessage{Tag: "G52"}
+	if _, _, _, _, options := d.ParseDiscard(); options != nil || options.canCall() {
+		t.Fatal("不能鸣牌", options)
+	}
+
+	// 自家摸牌，可以自摸立直暗杠
+	d.msg = &tenhouMessage{Tag: "T52", T: "50"}
+	if _, _, options := d.ParseSelfDraw(); options == nil || !options.Tsumo || !options.Riichi || !options.SelfKan || options.KyuushuKyuuhai || options.canCall() {
+		t.Fatal("摸牌选项有误", options)
+	}
+	d.msg = &tenhouMessage{Tag: "T52", T: "64"}
+	if _, _, options := d.ParseSelfDraw(); options == nil || !options.KyuushuKyuuhai {
+		t.Fatal("摸牌选项有误", options)
+	}
+}
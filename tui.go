@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"github.com/fatih/color"
+	"io"
+	"strings"
+)
+
+// 全屏界面（-render=tui）
+// 不再调用 clear 清屏，而是用 ANSI 转义序列把光标移回左上角，逐行覆盖上一次的内容，避免滚屏和闪烁
+// 界面从上到下分为以下几块，每块的行数固定，每次事件后原地更新：
+//   局况：场次、点数、宝牌指示牌、宝牌及其剩余枚数、牌山剩余
+//   牌河：四家的舍牌和副露，立直宣言牌用 [] 标出，鸣牌后的舍牌背景高亮
+//   手牌：自家手牌和副露
+//   铳率：他家视角下自家牌河的易读程度，他家的听牌率和手牌对各家的危险度
+//   何切：何切/鸣牌分析的结果
+//   提示：最近的几条提示信息
+// 开局信息、和牌结果等 renderText 的输出显示在界面下方，下次 clearConsole 时清除
+
+// 各块的行数
+const (
+	tuiBoardLines    = 2
+	tuiRiverLines    = 4
+	tuiHandLines     = 1
+	tuiRiskLines     = 5
+	tuiAnalysisLines = 12
+	tuiMessageLines  = 4
+)
+
+// 需要整个局面的输出，在每次处理完事件后调用
+type boardRenderer interface {
+	renderBoard(d *roundData)
+}
+
+type tuiRenderer struct {
+	out io.Writer
+
+	// 是否已清过屏
+	started bool
+
+	// 上一次输出的界面行数，界面下方为直接打印的信息
+	height int
+
+	board    []string
+	rivers   []string
+	hand     []string
+	risks    []string
+	analysis []string
+	messages []string
+}
+
+func newTUIRenderer(out io.Writer) *tuiRenderer {
+	return &tuiRenderer{out: out}
+}
+
+// 将带颜色的文字按行收集起来，供各块使用
+type tuiLineWriter struct {
+	lines []string
+	line  string
+}
+
+func (w *tuiLineWriter) begin(kind string) {}
+
+func (w *tuiLineWriter) print(text string, attrs ...color.Attribute) {
+	for i, s := range strings.Split(text, "\n") {
+		if i > 0 {
+			w.lines = append(w.lines, w.line)
+			w.line = ""
+		}
+		if s == "" {
+			continue
+		}
+		if len(attrs) > 0 {
+			s = color.New(attrs...).Sprint(s)
+		}
+		w.line += s
+	}
+}
+
+func (w *tuiLineWriter) end() {}
+
+// 收集到的各行，去掉末尾的空行
+func (w *tuiLineWriter) result() []string {
+	lines := w.lines
+	if w.line != "" {
+		lines = append(lines, w.line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// 某家的牌河，后面跟着副露
+func printRiverWithMelds(w styledWriter, p *playerInfo) {
+	w.print(p.name + ":")
+	for i := range p.discardTiles {
+		w.print(" ")
+		tile, bgColor, fgColor := p.discardTileStyle(i)
+		if i == p.reachTileAt {
+			// 立直宣言牌
+			w.print("["+tile+"]", color.BgYellow, color.FgBlack)
+			continue
+		}
+		w.print(tile, bgColor, fgColor)
+	}
+	if len(p.melds) > 0 {
+		w.print(" |")
+		for _, meld := range p.melds {
+			w.print(" " + humanMeld(meld))
+		}
+	}
+	w.print("\n")
+}
+
+func (r *tuiRenderer) renderDiscards(players []*playerInfo) {
+	w := &tuiLineWriter{}
+	for i := len(players) - 1; i >= 0; i-- {
+		printRiverWithMelds(w, players[i])
+	}
+	r.rivers = w.result()
+	r.redraw()
+}
+
+func (r *tuiRenderer) renderRisks(riskTables riskInfoList, hands []int, leftCounts []int) {
+	w := &tuiLineWriter{}
+	if riskTables[0].riskTable != nil {
+		riskTables.printSelfReadability(w)
+	}
+	names := playerNames(len(riskTables))
+	for i := len(riskTables) - 1; i >= 1; i-- {
+		ri := riskTables[i]
+		w.print(trf("%s安牌:", names[i]))
+		if len(ri.riskTable) > 0 {
+			ri.riskTable.printWithHands(w, hands, ri.tenpaiRate/100)
+		}
+		w.print(" " + trf("[听牌率%d%%]", int(ri.tenpaiRate)) + "\n")
+	}
+	w.print(tr("综合安牌:"))
+	riskTables.mixedRiskTable().printWithHands(w, hands, 1)
+	r.risks = w.result()
+	r.redraw()
+}
+
+func (r *tuiRenderer) renderAnalysis(output *analysisOutput) {
+	w := &tuiLineWriter{}
+	(&textRenderer{w: w}).renderAnalysis(output)
+	r.analysis = w.result()
+	r.redraw()
+}
+
+func (r *tuiRenderer) renderMessage(msg string, attrs ...color.Attribute) {
+	w := &tuiLineWriter{}
+	w.print(msg, attrs...)
+	r.messages = append(r.messages, w.result()...)
+	if len(r.messages) > tuiMessageLines {
+		r.messages = r.messages[len(r.messages)-tuiMessageLines:]
+	}
+	r.redraw()
+}
+
+// 显示在界面下方，下次 clearConsole 时清除
+func (r *tuiRenderer) renderText(kind string, text *styledText) {
+	w := &tuiLineWriter{}
+	text.writeTo(w)
+	for _, line := range w.result() {
+		fmt.Fprintln(r.out, line)
+	}
+}
+
+func (r *tuiRenderer) renderBoard(d *roundData) {
+	w := &tuiLineWriter{}
+	w.print(trf("%s%d局 %d本场", tileName(d.roundWindTile), d.roundNumber%4+1, d.game.honba) + " " + trf("自风%s", tileName(d.players[0].selfWindTile)) + " |")
+	for who, player := range d.players {
+		w.print(" " + player.name)
+		c := color.FgWhite
+		if who == d.dealer {
+			c = color.FgHiYellow // 亲家高亮
+		}
+		w.print(fmt.Sprint(d.game.scores[who]), c)
+	}
+	w.print("\n")
+
+	indicators := []string{}
+	for _, indicator := range d.doraIndicators {
+		indicators = append(indicators, tileName(indicator))
+	}
+	doras := []string{}
+	for _, dora := range d.doraList() {
+		doras = append(doras, fmt.Sprintf("%s(%d)", tileName(dora), d.leftCounts[dora]))
+	}
+	// liveWallLeft 按舍牌计数，自家摸牌（或吃碰）后、舍牌前还要再减去这一张
+	wallLeft := d.liveWallLeft()
+	if util.CountOfTiles34(d.counts)%3 == 2 {
+		wallLeft = util.MaxInt(wallLeft-1, 0)
+	}
+	w.print(trf("宝牌指示牌 %s  宝牌 %s  牌山剩余 %d 张", strings.Join(indicators, " "), strings.Join(doras, " "), wallLeft), color.FgHiYellow)
+	r.board = w.result()
+
+	w = &tuiLineWriter{}
+	for i := len(d.players) - 1; i >= 0; i-- {
+		printRiverWithMelds(w, d.players[i])
+	}
+	r.rivers = w.result()
+
+	melds := []model.Meld{}
+	for _, meld := range d.players[0].melds {
+		melds = append(melds, *meld)
+	}
+	playerInfo := &model.PlayerInfo{HandTiles34: d.counts, Melds: melds, NumRedFives: d.numRedFives}
+	r.hand = []string{trf("手牌: %s", humanTilesOfPlayer(playerInfo))}
+
+	r.redraw()
+}
+
+// 清除上一次事件的铳率、何切和提示，以及界面下方直接打印的信息
+func (r *tuiRenderer) clear() {
+	r.risks = nil
+	r.analysis = nil
+	r.messages = nil
+	if r.started {
+		fmt.Fprintf(r.out, "\x1b[%d;1H\x1b[J", r.height+1)
+	}
+}
+
+// 将 lines 补齐或截断至 n 行
+func tuiPane(lines []string, n int) []string {
+	pane := make([]string, n)
+	if len(lines) > n {
+		copy(pane, lines[:n-1])
+		pane[n-1] = "……"
+		return pane
+	}
+	copy(pane, lines)
+	return pane
+}
+
+func tuiTitle(title string) string {
+	return color.New(color.FgHiBlack).Sprint("── " + title + " ──")
+}
+
+// 原地重绘整个界面
+func (r *tuiRenderer) redraw() {
+	lines := tuiPane(r.board, tuiBoardLines)
+	lines = append(lines, tuiTitle(tr("牌河")))
+	lines = append(lines, tuiPane(r.rivers, tuiRiverLines)...)
+	lines = append(lines, tuiPane(r.hand, tuiHandLines)...)
+	lines = append(lines, tuiTitle(tr("铳率")))
+	lines = append(lines, tuiPane(r.risks, tuiRiskLines)...)
+	lines = append(lines, tuiTitle(tr("何切")))
+	lines = append(lines, tuiPane(r.analysis, tuiAnalysisLines)...)
+	lines = append(lines, tuiTitle(tr("提示")))
+	lines = append(lines, tuiPane(r.messages, tuiMessageLines)...)
+
+	buf := &bytes.Buffer{}
+	if !r.started {
+		// 第一次输出时清屏，之后光标留在界面下方
+		buf.WriteString("\x1b[H\x1b[2J")
+	} else {
+		// 保存光标位置，重绘后恢复，以免打断界面下方的输出
+		buf.WriteString("\x1b[s\x1b[H")
+	}
+	for _, line := range lines {
+		buf.WriteString(line + "\x1b[K\n")
+	}
+	if r.started {
+		buf.WriteString("\x1b[u")
+	}
+	r.started = true
+	r.height = len(lines)
+
+	// 一次性写入，减少闪烁
+	r.out.Write(buf.Bytes())
+}
@@ -60,12 +60,52 @@ func CalculateAgariRateOfEachTile(waits Waits, playerInfo *model.PlayerInfo) map
 				rate *= numberDoraAgariMulti
 			}
 		}
+		if tile < 27 && len(playerInfo.SelfRiskTiles34) > 0 {
+			rate *= selfRiskAgariMulti(tile, tileType27[tile], playerInfo)
+		}
 		tileAgariRate[tile] = rate
 	}
 
 	return tileAgariRate
 }
 
+// 他家视角下自家某张待牌的和率修正倍数
+// 大于 1 表示他家认为这张牌比同类牌安全（如筋牌陷阱），更容易打出；小于 1 表示他家会避开这张牌
+// 字牌或没有自家铳率时为 1
+func SelfRiskAgariMulti(tile int, playerInfo *model.PlayerInfo) float64 {
+	if tile >= 27 || len(playerInfo.SelfRiskTiles34) == 0 {
+		return 1
+	}
+	return selfRiskAgariMulti(tile, calcTileType27(playerInfo.DiscardTiles)[tile], playerInfo)
+}
+
+// 根据他家视角下自家的铳率，修正数牌待牌的和率
+// 筋牌类型相同时，NC、早外等使得他家认为某张牌越安全（铳率越低），他家就越容易打出这张牌
+func selfRiskAgariMulti(tile int, t tileType, playerInfo *model.PlayerInfo) float64 {
+	const (
+		minMulti = 0.5
+		maxMulti = 2.0
+	)
+
+	turns := MinInt(playerInfo.PassedTurns(), MaxTurns)
+	if turns == 0 {
+		turns = 1
+	}
+	baseRisk := RiskRate[turns][t]
+	risk := playerInfo.SelfRiskTiles34[tile]
+	if risk <= 0 {
+		return maxMulti
+	}
+	multi := baseRisk / risk
+	if multi < minMulti {
+		return minMulti
+	}
+	if multi > maxMulti {
+		return maxMulti
+	}
+	return multi
+}
+
 // 计算平均和率
 func CalculateAvgAgariRate(waits Waits, playerInfo *model.PlayerInfo) float64 {
 	if playerInfo == nil {
@@ -27,3 +27,28 @@ func TestCalculateAvgAgariRate(t *testing.T) {
 	assert.InDelta(t, 48.93434, CalculateAvgAgariRate(Waits{1: 4, 4: 2}, nil), eps)
 	assert.InDelta(t, 54.5818, CalculateAvgAgariRate(Waits{1: 4, 4: 4}, nil), eps)
 }
+
+func TestCalculateAgariRateOfEachTileWithSelfRisk(t *testing.T) {
+	// 无筋 2m 单骑，他家视角下 2m 的铳率与无筋相同时和率不变，铳率更低时和率更高
+	waits := Waits{1: 3}
+	base := CalculateAgariRateOfEachTile(waits, nil)[1]
+
+	selfRisk := make([]float64, 34)
+	selfRisk[1] = RiskRate[1][tileTypeNoSuji28]
+	pi := &model.PlayerInfo{SelfRiskTiles34: selfRisk}
+	assert.InDelta(t, base, CalculateAgariRateOfEachTile(waits, pi)[1], 1e-3)
+
+	selfRisk[1] = RiskRate[1][tileTypeSuji28]
+	assert.True(t, CalculateAgariRateOfEachTile(waits, pi)[1] > base)
+}
+
+func TestSelfRiskAgariMulti(t *testing.T) {
+	selfRisk := make([]float64, 34)
+	selfRisk[1] = RiskRate[1][tileTypeNoSuji28]
+	selfRisk[4] = RiskRate[1][tileTypeSuji28]
+	pi := &model.PlayerInfo{SelfRiskTiles34: selfRisk}
+	assert.InDelta(t, 1, SelfRiskAgariMulti(1, pi), 1e-3)
+	assert.True(t, SelfRiskAgariMulti(4, pi) > 1)
+	assert.Equal(t, 1.0, SelfRiskAgariMulti(27, pi))
+	assert.Equal(t, 1.0, SelfRiskAgariMulti(1, &model.PlayerInfo{}))
+}
@@ -4,6 +4,14 @@ package model
 type HumanTilesInfo struct {
 	HumanTiles     string
 	HumanDoraTiles string
+
+	HumanDoraIndicators string // 宝牌指示牌
+	HumanDiscardTiles   string // 自家舍牌，用于判断振听
+	HumanSeenTiles      string // 他家舍牌、副露等其余可见的牌，这些牌不在牌山中
+	HumanRoundWind      string // 场风，默认为东
+	HumanSelfWind       string // 自风，默认为东但按子家计算，指定自风为东时为亲家
+	IsRiichi            bool
+	Turn                int // 巡目，为 0 时按自家舍牌的个数推算
 }
 
 func NewSimpleHumanTilesInfo(humanTiles string) *HumanTilesInfo {
@@ -13,9 +13,16 @@ type PlayerInfo struct {
 	IsParent      bool // 是否为亲家
 	IsDaburii     bool // 是否双立直
 	IsRiichi      bool // 是否立直
+	IsLastTile    bool // 是否为最后一张牌（自摸时为海底，荣和时为河底）
+	IsSanma       bool // 是否为三人麻将
+	NukiDoraCount int  // 拔北宝牌的个数（三人麻将）
 
-	DiscardTiles []int // 自家舍牌，用于判断和率，是否振听等  *注意创建 PlayerInfo 的时候把负数调整成正的！
-	LeftTiles34  []int // 剩余牌
+	DiscardTiles    []int // 自家舍牌，用于判断和率，是否振听等  *注意创建 PlayerInfo 的时候把负数调整成正的！
+	Turn            int   // 巡目，即第几次摸牌，从 1 开始，为 0 时按自家舍牌的个数推算
+	IsRiichiFuriten bool  // 立直后见逃和了牌产生的振听，直到本局结束
+	LeftTiles34     []int // 剩余牌
+
+	SelfRiskTiles34 []float64 // 他家视角下自家各张牌的铳率，用于修正各张待牌的和率，可以为空
 
 	//LeftRedFives []int // 剩余赤5个数，用于估算打点
 	//AvgUraDora float64 // 平均里宝牌个数，用于计算立直时的打点
@@ -48,6 +55,13 @@ func (pi *PlayerInfo) CountDora() (count int) {
 	for _, num := range pi.NumRedFives {
 		count += num
 	}
+	// 拔北宝牌，若北本身是宝牌则额外计算
+	count += pi.NukiDoraCount
+	for _, doraTile := range pi.DoraTiles {
+		if doraTile == 30 {
+			count += pi.NukiDoraCount
+		}
+	}
 	return
 }
 
@@ -90,10 +104,21 @@ func (pi *PlayerInfo) IsNaki() bool {
 	return false
 }
 
+// 自家已经过的巡数
+func (pi *PlayerInfo) PassedTurns() int {
+	if pi.Turn > 0 {
+		return pi.Turn - 1
+	}
+	return len(pi.DiscardTiles)
+}
+
 // 是否振听
 // 仅限听牌时调用
 // TODO: Waits 移进来
 func (pi *PlayerInfo) IsFuriten(waits map[int]int) bool {
+	if pi.IsRiichiFuriten {
+		return true
+	}
 	for _, discardTile := range pi.DiscardTiles {
 		if _, ok := waits[discardTile]; ok {
 			return true
@@ -105,6 +130,10 @@ func (pi *PlayerInfo) IsFuriten(waits map[int]int) bool {
 /************* 以下接口暂为内部调用 ************/
 
 func (pi *PlayerInfo) FillLeftTiles34() {
+	if pi.IsSanma {
+		pi.LeftTiles34 = InitSanmaLeftTiles34WithTiles34(pi.HandTiles34)
+		return
+	}
 	pi.LeftTiles34 = InitLeftTiles34WithTiles34(pi.HandTiles34)
 }
 
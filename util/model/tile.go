@@ -15,7 +15,24 @@ func InitLeftTiles34WithTiles34(tiles34 []int) []int {
 	return leftTiles34
 }
 
+// 三人麻将中不使用的牌，即 2-8m
+func IsSanmaUnusedTile(tile int) bool {
+	return tile >= 1 && tile <= 7
+}
+
+// 三人麻将的剩余牌，2-8m 为 0
+func InitSanmaLeftTiles34WithTiles34(tiles34 []int) []int {
+	leftTiles34 := InitLeftTiles34WithTiles34(tiles34)
+	for i := range leftTiles34 {
+		if IsSanmaUnusedTile(i) {
+			leftTiles34[i] = 0
+		}
+	}
+	return leftTiles34
+}
+
 // 根据宝牌指示牌计算出宝牌
+// 三人麻将中 1m 指示 9m，见 SanmaDoraTile
 func DoraTile(doraIndicator int) (dora int) {
 	if doraIndicator < 27 { // mps
 		if doraIndicator%9 < 8 {
@@ -35,6 +52,14 @@ func DoraTile(doraIndicator int) (dora int) {
 	return 31
 }
 
+// 三人麻将中根据宝牌指示牌计算出宝牌（1m 的下一张是 9m）
+func SanmaDoraTile(doraIndicator int) (dora int) {
+	if doraIndicator == 0 {
+		return 8
+	}
+	return DoraTile(doraIndicator)
+}
+
 // 根据宝牌指示牌计算出宝牌
 func DoraList(doraIndicators []int) (doraList []int) {
 	for _, doraIndicator := range doraIndicators {
@@ -42,3 +67,11 @@ func DoraList(doraIndicators []int) (doraList []int) {
 	}
 	return
 }
+
+// 三人麻将中根据宝牌指示牌计算出宝牌
+func SanmaDoraList(doraIndicators []int) (doraList []int) {
+	for _, doraIndicator := range doraIndicators {
+		doraList = append(doraList, SanmaDoraTile(doraIndicator))
+	}
+	return
+}
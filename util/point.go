@@ -69,6 +69,17 @@ func CalcPointTsumoSum(han int, fu int, yakumanTimes int, isParent bool) int {
 	return 2*childPoint + parentPoint
 }
 
+// 番数 符数 役满倍数 是否为亲家
+// 返回三人麻将自摸时的点数
+// 采用自摸损规则，即少了北家支付的那一份
+func CalcPointTsumoSumSanma(han int, fu int, yakumanTimes int, isParent bool) int {
+	childPoint, parentPoint := CalcPointTsumo(han, fu, yakumanTimes, isParent)
+	if isParent {
+		return 2 * childPoint
+	}
+	return childPoint + parentPoint
+}
+
 //
 
 type PointResult struct {
@@ -85,6 +96,29 @@ type PointResult struct {
 	agariRate float64 // 无役时的和率为 0
 }
 
+// 番数（含宝牌）
+func (pr *PointResult) Han() int {
+	return pr.han
+}
+
+func (pr *PointResult) Fu() int {
+	return pr.fu
+}
+
+func (pr *PointResult) YakuTypes() []int {
+	return pr.yakuTypes
+}
+
+// 额外加上 extraHan 番（如里宝）后荣和的点数（不含本场棒）
+func (pr *PointResult) RonPayment(extraHan int) int {
+	return CalcPointRon(pr.han+extraHan, pr.fu, pr.yakumanTimes, pr.isParent)
+}
+
+// 额外加上 extraHan 番（如里宝）后自摸时的子家支付点数和亲家支付点数（不含本场棒）
+func (pr *PointResult) TsumoPayments(extraHan int) (childPoint int, parentPoint int) {
+	return CalcPointTsumo(pr.han+extraHan, pr.fu, pr.yakumanTimes, pr.isParent)
+}
+
 // 已和牌，计算自摸或荣和时的点数（不考虑里宝、一发等情况）
 // 无役时返回的点数为 0（和率也为 0）
 // 调用前请设置 IsTsumo WinTile
@@ -106,7 +140,11 @@ func CalcPoint(playerInfo *model.PlayerInfo) (result *PointResult) {
 		yakumanTimes := CalcYakumanTimes(yakuTypes)
 		var pt int
 		if _hi.IsTsumo {
-			pt = CalcPointTsumoSum(han, fu, yakumanTimes, _hi.IsParent)
+			if _hi.IsSanma {
+				pt = CalcPointTsumoSumSanma(han, fu, yakumanTimes, _hi.IsParent)
+			} else {
+				pt = CalcPointTsumoSum(han, fu, yakumanTimes, _hi.IsParent)
+			}
 		} else {
 			pt = CalcPointRon(han, fu, yakumanTimes, _hi.IsParent)
 		}
@@ -23,6 +23,12 @@ func TestCalcPointTsumoSum(t *testing.T) {
 	assert.Equal(t, 12000, CalcPointTsumoSum(4, 40, 0, true))
 }
 
+func TestCalcPointTsumoSumSanma(t *testing.T) {
+	assert.Equal(t, 3900, CalcPointTsumoSumSanma(3, 40, 0, false))
+	assert.Equal(t, 5200, CalcPointTsumoSumSanma(3, 40, 0, true))
+	assert.Equal(t, 6000, CalcPointTsumoSumSanma(5, 30, 0, false))
+}
+
 func TestCalcRonPointWithHands(t *testing.T) {
 	// 子家默听荣和
 	newPIWithWinTile := func(humanTiles string, winHumanTile string) *model.PlayerInfo {
@@ -739,9 +739,9 @@ func CalculateShantenWithImproves14(playerInfo *model.PlayerInfo) (shanten int,
 }
 
 // 计算最小向听数，鸣牌方式
-func calculateMeldShanten(tiles34 []int, calledTile int, isRedFive bool, allowChi bool) (minShanten int, meldCombinations []model.Meld) {
+func calculateMeldShanten(tiles34 []int, calledTile int, isRedFive bool, allowChi bool, allowPon bool) (minShanten int, meldCombinations []model.Meld) {
 	// 是否能碰
-	if tiles34[calledTile] >= 2 {
+	if allowPon && tiles34[calledTile] >= 2 {
 		meldCombinations = append(meldCombinations, model.Meld{
 			MeldType:          model.MeldTypePon,
 			Tiles:             []int{calledTile, calledTile, calledTile},
@@ -810,12 +810,13 @@ func calculateMeldShanten(tiles34 []int, calledTile int, isRedFive bool, allowCh
 // calledTile 他家出的牌，尝试鸣这张牌
 // isRedFive 这张牌是否为赤5
 // allowChi 是否允许吃这张牌
-func CalculateMeld(playerInfo *model.PlayerInfo, calledTile int, isRedFive bool, allowChi bool) (minShanten int, results Hand14AnalysisResultList, incShantenResults Hand14AnalysisResultList) {
+// allowPon 是否允许碰这张牌
+func CalculateMeld(playerInfo *model.PlayerInfo, calledTile int, isRedFive bool, allowChi bool, allowPon bool) (minShanten int, results Hand14AnalysisResultList, incShantenResults Hand14AnalysisResultList) {
 	if len(playerInfo.LeftTiles34) == 0 {
 		playerInfo.FillLeftTiles34()
 	}
 
-	minShanten, meldCombinations := calculateMeldShanten(playerInfo.HandTiles34, calledTile, isRedFive, allowChi)
+	minShanten, meldCombinations := calculateMeldShanten(playerInfo.HandTiles34, calledTile, isRedFive, allowChi, allowPon)
 
 	for _, c := range meldCombinations {
 		// 尝试鸣这张牌
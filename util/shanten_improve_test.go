@@ -187,7 +187,7 @@ func TestCalculateMeld(t *testing.T) {
 	tile = "4p"
 	tile = "3s"
 	tile = "7z"
-	shanten, results, incShantenResults := CalculateMeld(pi, MustStrToTile34(tile), false, true)
+	shanten, results, incShantenResults := CalculateMeld(pi, MustStrToTile34(tile), false, true, true)
 	t.Log("鸣牌后" + NumberToChineseShanten(shanten))
 	for _, result := range results {
 		t.Log(result)
@@ -353,3 +353,14 @@ func Test_calculateIsolatedTileValue(t *testing.T) {
 	assert.InDelta(t, 116, float64(calculateIsolatedTileValue(MustStrToTile34("3z"), newPI(29, 27, "2s"))), eps)
 	assert.InDelta(t, 97, float64(calculateIsolatedTileValue(MustStrToTile34("4z"), newPI(29, 27, "2s"))), eps)
 }
+
+func TestCalculateMeldWithoutPon(t *testing.T) {
+	// 只能吃时不分析碰
+	pi := model.NewSimplePlayerInfo(MustStrToTiles34("466m 234467p 77s 77z"), nil)
+	_, results, incShantenResults := CalculateMeld(pi, MustStrToTile34("7z"), false, true, false)
+	assert.Empty(t, results)
+	assert.Empty(t, incShantenResults)
+
+	_, results, _ = CalculateMeld(pi, MustStrToTile34("7z"), false, false, true)
+	assert.NotEmpty(t, results)
+}
@@ -102,6 +102,10 @@ func _search13(currentShanten int, playerInfo *model.PlayerInfo, stopAtShanten i
 		if tiles34[i] == 4 {
 			continue
 		}
+		if playerInfo.IsSanma && model.IsSanmaUnusedTile(i) {
+			// 三人麻将没有 2-8m
+			continue
+		}
 		tiles34[i]++
 		if isTenpai {
 			// 优化：听牌时改用更为快速的 IsAgari
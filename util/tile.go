@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"sort"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
 )
 
 var Mahjong = [...]string{
@@ -156,6 +157,17 @@ func InitLeftTiles34() []int {
 	return leftTiles34
 }
 
+// 三人麻将的牌山，共 108 张，没有 2-8m
+func InitSanmaLeftTiles34() []int {
+	leftTiles34 := InitLeftTiles34()
+	for i := range leftTiles34 {
+		if model.IsSanmaUnusedTile(i) {
+			leftTiles34[i] = 0
+		}
+	}
+	return leftTiles34
+}
+
 // 根据传入的牌，返回移除这些牌后剩余的牌
 func InitLeftTiles34WithTiles34(tiles34 []int) []int {
 	leftTiles34 := make([]int, 34)
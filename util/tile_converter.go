@@ -25,6 +25,9 @@ func StrToTile34(humanTile string) (tile34 int, err error) {
 		return -1, errors.New("[StrToTile34] 参数错误: " + humanTile)
 	}
 	i := humanTile[0]
+	if i < '0' || i > '9' {
+		return -1, errors.New("[StrToTile34] 参数错误: " + humanTile)
+	}
 	if i == '0' {
 		i = '5'
 	}
@@ -91,6 +94,26 @@ func MustStrToTiles34(humanTiles string) []int {
 	return tiles34
 }
 
+// e.g. "0m 500p" => [1, 2, 0]
+// 按照 mps 的顺序，统计 humanTiles 中各个赤5（记作 0）的个数
+func CountRedFives(humanTiles string) []int {
+	numRedFives := make([]int, 3)
+	cnt := 0
+	for i := 0; i < len(humanTiles); i++ {
+		switch c := humanTiles[i]; {
+		case c == '0':
+			cnt++
+		case c >= '1' && c <= '9':
+		default:
+			if idx := byteAtStr(c, "mps"); idx != -1 {
+				numRedFives[idx] += cnt
+			}
+			cnt = 0
+		}
+	}
+	return numRedFives
+}
+
 // e.g. "11122z" => [27, 27, 27, 28, 28]
 func StrToTiles(humanTiles string) (tiles []int, err error) {
 	defer func() {
@@ -99,6 +122,12 @@ func StrToTiles(humanTiles string) (tiles []int, err error) {
 		}
 	}()
 
+	// 在 mpsz 后面加上空格方便解析不含空格的 humanTiles
+	humanTiles = strings.Replace(humanTiles, "m", "m ", -1)
+	humanTiles = strings.Replace(humanTiles, "p", "p ", -1)
+	humanTiles = strings.Replace(humanTiles, "s", "s ", -1)
+	humanTiles = strings.Replace(humanTiles, "z", "z ", -1)
+
 	humanTiles = strings.TrimSpace(humanTiles)
 	if humanTiles == "" {
 		return nil, errors.New("[StrToTiles34] 参数错误: 处理的手牌不能为空")
@@ -106,6 +135,9 @@ func StrToTiles(humanTiles string) (tiles []int, err error) {
 
 	for _, split := range strings.Split(humanTiles, " ") {
 		split = strings.TrimSpace(split)
+		if split == "" {
+			continue
+		}
 		if len(split) < 2 {
 			return nil, errors.New("[StrToTiles34] 参数错误: " + humanTiles)
 		}
@@ -142,7 +174,7 @@ func TilesToStr(tiles []int) (humanTiles string) {
 		for _, idx := range _tiles {
 			if idx >= lowerIndex && idx < upperIndex {
 				found = true
-				humanTiles += string('1' + idx - lowerIndex)
+				humanTiles += string(rune('1' + idx - lowerIndex))
 			}
 		}
 		if found {
@@ -167,7 +199,35 @@ func Tiles34ToStr(tiles34 []int) (humanTiles string) {
 			if i >= lowerIndex && i < upperIndex {
 				for j := 0; j < c; j++ {
 					found = true
-					humanTiles += string('1' + i - lowerIndex)
+					humanTiles += string(rune('1' + i - lowerIndex))
+				}
+			}
+		}
+		if found {
+			humanTiles += endsWith
+		}
+	}
+	merge(0, 9, "m ")
+	merge(9, 18, "p ")
+	merge(18, 27, "s ")
+	merge(27, 34, "z")
+	return strings.TrimSpace(humanTiles)
+}
+
+// 赤5记作 0，e.g. 两张 5m 中有一张赤5 => "05m"
+// numRedFives 按照 mps 的顺序，超过 5 的个数时按 5 的个数算
+func Tiles34ToStrWithRedFives(tiles34 []int, numRedFives []int) (humanTiles string) {
+	merge := func(lowerIndex, upperIndex int, endsWith string) {
+		found := false
+		for i, c := range tiles34 {
+			if i >= lowerIndex && i < upperIndex {
+				for j := 0; j < c; j++ {
+					found = true
+					if i < 27 && i%9 == 4 && j < numRedFives[i/9] {
+						humanTiles += "0"
+					} else {
+						humanTiles += string(rune('1' + i - lowerIndex))
+					}
 				}
 			}
 		}
@@ -26,3 +26,20 @@ func TestConvert(t *testing.T) {
 		assert.Equal(t, tiles, Tiles34ToStr(MustStrToTiles34(tiles)))
 	}
 }
+
+func TestRedFives(t *testing.T) {
+	assert.Equal(t, []int{1, 2, 0}, CountRedFives("0m 500p"))
+	assert.Equal(t, []int{0, 0, 1}, CountRedFives("340s 1z"))
+	assert.Equal(t, []int{0, 0, 0}, CountRedFives("55m 1z"))
+
+	for _, tiles := range []string{"3405m 55p 0s", "0m", "123m 55p 406s 777z"} {
+		assert.Equal(t, tiles, Tiles34ToStrWithRedFives(MustStrToTiles34(tiles), CountRedFives(tiles)))
+	}
+}
+
+func TestStrToTilesWithoutSpaces(t *testing.T) {
+	assert.Equal(t, []int{1, 31}, MustStrToTiles("2m5z"))
+	assert.Equal(t, []int{4, 4, 13}, MustStrToTiles("50m 5p"))
+	_, err := StrToTiles("2mz")
+	assert.Error(t, err)
+}
@@ -91,6 +91,14 @@ func (hi *_handInfo) tsumo() bool {
 	return !hi.IsNaki() && hi.IsTsumo
 }
 
+func (hi *_handInfo) haitei() bool {
+	return hi.IsTsumo && hi.IsLastTile
+}
+
+func (hi *_handInfo) houtei() bool {
+	return !hi.IsTsumo && hi.IsLastTile
+}
+
 // 门清限定
 func (hi *_handInfo) chiitoi() bool {
 	return hi.divideResult.IsChiitoi
@@ -493,6 +501,8 @@ var yakuCheckerMap = map[int]yakuChecker{
 	YakuRiichi:         (*_handInfo).riichi,
 	YakuChiitoi:        (*_handInfo).chiitoi,
 	YakuTsumo:          (*_handInfo).tsumo,
+	YakuHaitei:         (*_handInfo).haitei,
+	YakuHoutei:         (*_handInfo).houtei,
 	YakuPinfu:          (*_handInfo).pinfu,
 	YakuRyanpeikou:     (*_handInfo).ryanpeikou,
 	YakuIipeikou:       (*_handInfo).iipeikou,
@@ -9,7 +9,7 @@ import (
 
 func errorExit(args ...interface{}) {
 	fmt.Fprintln(os.Stderr, args...)
-	fmt.Println("按任意键退出...")
+	fmt.Println(tr("按任意键退出..."))
 	bufio.NewReader(os.Stdin).ReadByte()
 	os.Exit(1)
 }
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通过 WebSocket 推送分析结果，供浏览器中的悬浮窗、用户脚本等订阅
+// 每处理完一个事件，推送一条与牌谱记录相同格式的 JSON（见 recordEvent），其中包含何切的各个选项、局面和各家的铳率表
+// 这里只实现了服务端推送所需的 RFC 6455 的最小子集：握手、发送文本帧、响应 ping 和 close，客户端发来的消息会被忽略
+
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpcodeText  = 0x1
+	wsOpcodeClose = 0x8
+	wsOpcodePing  = 0x9
+	wsOpcodePong  = 0xA
+)
+
+// 客户端发来的帧的最大长度，超过时断开连接
+const wsMaxReadPayload = 1 << 16
+
+const wsWriteTimeout = 5 * time.Second
+
+// 每个订阅者待发送消息的队列长度，队列满时说明客户端太慢，断开该连接，以免拖慢分析
+const wsSendQueueSize = 16
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// 推送和响应 ping 可能在不同的 goroutine 中进行
+	mu sync.Mutex
+
+	// 待推送的消息，由 writeLoop 发送，由 wsHub 在移除该连接时关闭
+	send chan []byte
+}
+
+func newWSConn(conn net.Conn, rw *bufio.ReadWriter) *wsConn {
+	return &wsConn{conn: conn, rw: rw, send: make(chan []byte, wsSendQueueSize)}
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header[name] {
+		for _, s := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 将 HTTP 连接升级为 WebSocket 连接
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet || !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("不是 WebSocket 握手请求")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		return nil, errors.New("不支持的 WebSocket 版本 " + r.Header.Get("Sec-Websocket-Version"))
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if key == "" {
+		return nil, errors.New("缺少 Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("连接不支持 Hijack")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWSConn(conn, rw), nil
+}
+
+// 发送一个帧，服务端发送的帧不加掩码
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode} // FIN
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeText(data []byte) error {
+	return c.writeFrame(wsOpcodeText, data)
+}
+
+// 读取一个帧，客户端发送的帧均带有掩码
+func (c *wsConn) readFrame() (opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(c.rw, header); err != nil {
+		return
+	}
+	opcode = header[0] & 0x0F
+	isMasked := header[1]&0x80 > 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		b := make([]byte, 2)
+		if _, err = io.ReadFull(c.rw, b); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(b))
+	case 127:
+		b := make([]byte, 8)
+		if _, err = io.ReadFull(c.rw, b); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(b)
+	}
+	if length > wsMaxReadPayload {
+		return 0, nil, fmt.Errorf("帧过长 %d", length)
+	}
+
+	mask := make([]byte, 4)
+	if isMasked {
+		if _, err = io.ReadFull(c.rw, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return
+	}
+	if isMasked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// 发送队列中的消息，直到队列被关闭
+func (c *wsConn) writeLoop() {
+	for data := range c.send {
+		if err := c.writeText(data); err != nil {
+			// 连接已断开，readLoop 随之返回，之后由 wsHub 关闭队列
+			c.conn.Close()
+			for range c.send {
+			}
+			return
+		}
+	}
+}
+
+// 读取客户端发来的帧，直到连接关闭
+func (c *wsConn) readLoop() {
+	defer c.conn.Close()
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpcodePing:
+			if err := c.writeFrame(wsOpcodePong, payload); err != nil {
+				return
+			}
+		case wsOpcodeClose:
+			c.writeFrame(wsOpcodeClose, payload)
+			return
+		}
+	}
+}
+
+//
+
+// 管理所有订阅者
+type wsHub struct {
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+
+	// 最近一次推送的消息，新的订阅者连接后会立即收到
+	latest []byte
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{conns: map[*wsConn]struct{}{}}
+}
+
+func (h *wsHub) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.latest != nil {
+		c.send <- h.latest
+	}
+	h.conns[c] = struct{}{}
+}
+
+// 移除连接并关闭其发送队列，需持有 h.mu
+func (h *wsHub) _remove(c *wsConn) {
+	if _, ok := h.conns[c]; ok {
+		delete(h.conns, c)
+		close(c.send)
+	}
+	if len(h.conns) == 0 {
+		// 没有订阅者时不再推送，清除上一次的消息，以免之后的订阅者收到过时的局面
+		h.latest = nil
+	}
+}
+
+func (h *wsHub) remove(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h._remove(c)
+}
+
+func (h *wsHub) hasSubscribers() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns) > 0
+}
+
+// 只把消息放入各个连接的发送队列，不等待发送完成
+func (h *wsHub) broadcast(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latest = data
+	for c := range h.conns {
+		select {
+		case c.send <- data:
+		default:
+			// 发送队列已满，客户端太慢，断开该连接
+			h._remove(c)
+			c.conn.Close()
+		}
+	}
+}
+
+// 处理 WebSocket 订阅请求，直到连接关闭
+func (h *wsHub) serve(w http.ResponseWriter, r *http.Request) error {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return err
+	}
+	go c.writeLoop()
+	h.add(c)
+	c.readLoop()
+	h.remove(c)
+	return nil
+}
+
+// 分析结果的推送，由 runServer 创建，为 nil 时不推送
+var analysisPusher *wsHub
+
+// 是否有需要推送分析结果的订阅者，没有时不必生成推送的内容
+func isPushingAnalysis() bool {
+	return analysisPusher != nil && analysisPusher.hasSubscribers()
+}
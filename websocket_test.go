@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWSAcceptKey(t *testing.T) {
+	// RFC 6455 中的例子
+	if key := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("Sec-WebSocket-Accept 有误", key)
+	}
+}
+
+func TestWSHub(t *testing.T) {
+	hub := newWSHub()
+	hub.broadcast([]byte(`{"type":"init"}`))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := hub.serve(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-Websocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("握手有误", response.Status, response.Header)
+	}
+
+	c := &wsConn{conn: conn, rw: bufio.NewReadWriter(reader, bufio.NewWriter(conn))}
+	readText := func() map[string]interface{} {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opcode != wsOpcodeText {
+			t.Fatal("帧类型有误", opcode)
+		}
+		msg := map[string]interface{}{}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	// 连接后立即收到最近一次推送的消息
+	if msg := readText(); msg["type"] != "init" {
+		t.Fatal("最近一次推送的消息有误", msg)
+	}
+
+	// 超过 125 字节的消息
+	long := `{"type":"draw","hand":"` + strings.Repeat("1m", 100) + `"}`
+	hub.broadcast([]byte(long))
+	if msg := readText(); msg["type"] != "draw" {
+		t.Fatal("推送的消息有误", msg)
+	}
+
+	// 不是握手请求
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("应当拒绝", resp.Status)
+	}
+}
+
+func TestWSHubSlowClient(t *testing.T) {
+	hub := newWSHub()
+
+	// 客户端从不读取，写操作会一直阻塞
+	server, client := net.Pipe()
+	defer client.Close()
+	c := newWSConn(server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)))
+	go c.writeLoop()
+	hub.add(c)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i <= wsSendQueueSize+1; i++ {
+			hub.broadcast([]byte(`{"type":"draw"}`))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("推送被慢客户端阻塞")
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.conns[c]; ok {
+		t.Fatal("应当断开慢客户端")
+	}
+}
+
+func TestAnalysisPush(t *testing.T) {
+	debugMode = true
+	analysisPusher = newWSHub()
+	defer func() { analysisPusher = nil }()
+
+	analysis := func() {
+		d := &tenhouRoundData{isRoundEnd: true}
+		d.roundData = newRoundData(d, 0, 0)
+		for _, msg := range []*tenhouMessage{
+			{Tag: "INIT", Seed: "0,0,0,1,2,36", Ten: "250,250,250,250", Dealer: "0", Hai: "0,4,8,48,53,56,96,100,104,108,109,110,89"},
+			{Tag: "T112"},
+		} {
+			d.msg = msg
+			if err := d.analysis(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 没有订阅者时不生成推送的内容
+	analysis()
+	if analysisPusher.latest != nil {
+		t.Fatal("没有订阅者时不应推送", string(analysisPusher.latest))
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	analysisPusher.add(newWSConn(server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))))
+	analysis()
+
+	event := &recordEvent{}
+	if err := json.Unmarshal(analysisPusher.latest, event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != recordEventTypeDraw || event.Snapshot == nil || event.Analysis == nil || len(event.Analysis.Choices) == 0 {
+		t.Fatal("推送的分析结果有误", string(analysisPusher.latest))
+	}
+	if len(event.Risks) != 3 || event.Risks[0].Who != 1 || len(event.Risks[0].Risks) == 0 {
+		t.Fatal("推送的铳率表有误", string(analysisPusher.latest))
+	}
+}
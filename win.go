@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"github.com/EndlessCheng/mahjong-helper/util/model"
+	"github.com/fatih/color"
+	"strings"
+)
+
+// 和牌结果：和牌者、放铳者、和了牌、各个役的番数、符数以及宝牌、里宝牌、赤宝牌的个数
+// 天凤的 AGARI 和雀魂的 ActionHule 都带有完整的和牌信息，解析成 WinResult 后统一打印
+// 打印时用 util.CalcPoint 重新计算一遍番数和符数，与实际不一致时给出提示，以便发现打点计算的问题
+
+// util 中没有的役（役满等）
+const winYakuTypeUnknown = -1
+
+// 一个役，不含宝牌
+type WinYaku struct {
+	YakuType int // util 中的役种，util 中没有的役为 winYakuTypeUnknown
+	Name     string
+	Han      int // 役满时为 0
+	Yakuman  int // 役满倍数，不是役满时为 0
+}
+
+// 当前语言的役名
+// 役牌要区分是哪种牌，役满在 util 中没有对应的役种，这两种情况按平台提供的役名翻译
+func (y *WinYaku) displayName() string {
+	if y.YakuType == winYakuTypeUnknown || y.YakuType == util.YakuYakuhai {
+		return tr(y.Name)
+	}
+	return yakuName(y.YakuType)
+}
+
+type WinResult struct {
+	Who     int
+	FromWho int // 放铳者，自摸时与 Who 相同，无法确定时为 -1
+	WinTile int // 数据源没有提供时为 -1
+
+	// 和了时的手牌（含和了牌，不含副露），数据源没有提供时为 nil
+	HandTiles   []int
+	Melds       []*model.Meld
+	NumRedFives []int // 手牌和副露中的赤5
+
+	Yakus        []*WinYaku
+	Han          int // 含宝牌，役满时为 0
+	Fu           int
+	YakumanTimes int
+
+	Dora     int
+	UraDora  int
+	AkaDora  int
+	NukiDora int
+
+	DoraIndicators    []int
+	UraDoraIndicators []int
+
+	Point int // 不含本场棒和立直棒
+}
+
+func (r *WinResult) isTsumo() bool {
+	return r.FromWho == r.Who
+}
+
+func (r *WinResult) winTileStr() string {
+	if r.WinTile < 0 {
+		return ""
+	}
+	return util.Mahjong[r.WinTile]
+}
+
+// 和了时的手牌，副露写在 & 之后，没有手牌时为空
+func (r *WinResult) handStr() string {
+	if r.HandTiles == nil {
+		return ""
+	}
+	hand := util.Tiles34ToStr(r.HandTiles)
+	if len(r.Melds) > 0 {
+		hand += " &"
+		for _, meld := range r.Melds {
+			hand += " " + util.TilesToStr(meld.Tiles)
+		}
+	}
+	return hand
+}
+
+func newWinEvent(results []*WinResult, deltaPoints []int) *WinEvent {
+	e := &WinEvent{DeltaPoints: deltaPoints, Results: results}
+	for _, r := range results {
+		e.Whos = append(e.Whos, r.Who)
+		e.Points = append(e.Points, r.Point)
+	}
+	return e
+}
+
+// 最近一次舍牌的玩家，用于确定荣和时的放铳者
+func (d *roundData) latestDiscardWho() (who int) {
+	who = -1
+	latest := -1
+	for i, player := range d.players {
+		if player.latestDiscardAtGlobal > latest {
+			who, latest = i, player.latestDiscardAtGlobal
+		}
+	}
+	return
+}
+
+// 抢杠时被抢的玩家
+func (d *roundData) chankanWho(winner int, tile int) int {
+	for who, player := range d.players {
+		if who == winner || len(player.melds) == 0 {
+			continue
+		}
+		if meld := player.melds[len(player.melds)-1]; meld.MeldType == meldTypeKakan && meld.CalledTile == tile {
+			return who
+		}
+	}
+	return -1
+}
+
+// 满贯、跳满等
+func winLimitName(han int, fu int, yakumanTimes int) string {
+	switch {
+	case yakumanTimes == 1:
+		return tr("役满")
+	case yakumanTimes > 1:
+		return trf("%d倍役满", yakumanTimes)
+	case han >= 13:
+		return tr("累计役满")
+	case han >= 11:
+		return tr("三倍满")
+	case han >= 8:
+		return tr("倍满")
+	case han >= 6:
+		return tr("跳满")
+	case han == 5 || han == 4 && fu >= 40 || han == 3 && fu >= 70:
+		return tr("满贯")
+	}
+	return ""
+}
+
+func (d *roundData) printWinResult(w styledWriter, r *WinResult) {
+	winner := d.players[r.Who].name
+	if r.isTsumo() {
+		w.print(fmt.Sprintln(winner, tr("自摸"), r.winTileStr()))
+	} else if r.FromWho >= 0 {
+		w.print(fmt.Sprintln(winner, tr("荣和"), d.players[r.FromWho].name, r.winTileStr()))
+	} else {
+		w.print(fmt.Sprintln(winner, tr("荣和"), r.winTileStr()))
+	}
+
+	if hand := r.handStr(); hand != "" {
+		w.print(hand + "\n")
+	}
+
+	var yakus []string
+	for _, yaku := range r.Yakus {
+		if yaku.Yakuman > 0 {
+			yakus = append(yakus, yaku.displayName())
+		} else {
+			yakus = append(yakus, trf("%s %d番", yaku.displayName(), yaku.Han))
+		}
+	}
+	for _, dora := range []struct {
+		name  string
+		count int
+	}{{"宝牌", r.Dora}, {"赤宝牌", r.AkaDora}, {"里宝牌", r.UraDora}, {"拔北宝牌", r.NukiDora}} {
+		if dora.count > 0 {
+			yakus = append(yakus, trf("%s %d番", tr(dora.name), dora.count))
+		}
+	}
+	if len(yakus) > 0 {
+		w.print(strings.Join(yakus, "  ") + "\n")
+	}
+
+	if r.YakumanTimes > 0 {
+		w.print(winLimitName(r.Han, r.Fu, r.YakumanTimes))
+	} else {
+		w.print(trf("%d符 %d番", r.Fu, r.Han))
+		if name := winLimitName(r.Han, r.Fu, 0); name != "" {
+			w.print(" " + name)
+		}
+	}
+	w.print(trf(" %d点\n", r.Point), color.FgHiGreen)
+
+	if msg := d.checkWinResult(r); msg != "" {
+		w.print(msg, color.FgHiYellow)
+		w.print("\n")
+	}
+}
+
+// 用 util.CalcPoint 重新计算番数和符数，不一致时返回提示
+// 没有手牌、役满或有 util 中没有的役时无法计算，返回空字符串
+func (d *roundData) checkWinResult(r *WinResult) string {
+	if r.HandTiles == nil || r.YakumanTimes > 0 {
+		return ""
+	}
+
+	melds := []model.Meld{}
+	for _, meld := range r.Melds {
+		melds = append(melds, *meld)
+	}
+	doraTiles := model.DoraList(r.DoraIndicators)
+	if d.isSanma() {
+		doraTiles = model.SanmaDoraList(r.DoraIndicators)
+	}
+	pi := &model.PlayerInfo{
+		HandTiles34:   append([]int(nil), r.HandTiles...),
+		Melds:         melds,
+		DoraTiles:     doraTiles,
+		NumRedFives:   append([]int(nil), r.NumRedFives...),
+		IsTsumo:       r.isTsumo(),
+		WinTile:       r.WinTile,
+		RoundWindTile: d.roundWindTile,
+		SelfWindTile:  d.players[r.Who].selfWindTile,
+		IsParent:      r.Who == d.dealer,
+		IsSanma:       d.isSanma(),
+		NukiDoraCount: d.players[r.Who].nukiDoraCount, // 天凤的拔北宝牌算在宝牌中，这里以实际拔北的个数为准
+	}
+
+	// 一发、岭上、抢杠和里宝牌不在 util 的计算范围内，直接加上实际的番数
+	extraHan := r.UraDora
+	for _, yaku := range r.Yakus {
+		switch yaku.YakuType {
+		case util.YakuRiichi:
+			pi.IsRiichi = true
+		case util.YakuDaburii:
+			pi.IsRiichi = true
+			pi.IsDaburii = true
+		case util.YakuHaitei, util.YakuHoutei:
+			pi.IsLastTile = true
+		case util.YakuIppatsu, util.YakuRinshan, util.YakuChankan:
+			extraHan += yaku.Han
+		case winYakuTypeUnknown:
+			return ""
+		}
+	}
+
+	result := util.CalcPoint(pi)
+	if result.Point == 0 {
+		return tr("打点校验：计算结果为无役")
+	}
+	if han := result.Han() + extraHan; han != r.Han || result.Fu() != r.Fu {
+		return trf("打点校验：实际为 %d符%d番，计算结果为 %d符%d番 %s", r.Fu, r.Han, result.Fu(), han, yakuTypesToStr(result.YakuTypes()))
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/EndlessCheng/mahjong-helper/util"
+	"reflect"
+	"testing"
+)
+
+func TestTenhouWinResult(t *testing.T) {
+	debugMode = true
+
+	d := &tenhouRoundData{isRoundEnd: true}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, msg := range []*tenhouMessage{
+		{Tag: "GO", Type: "9"},
+		{Tag: "INIT", Seed: "0,0,0,3,2,0", Ten: "250,250,250,250", Dealer: "0", Hai: "30,60,108,31,78,107,25,23,2,14,122,44,49"},
+	} {
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 下家荣和自家 234m 345p 678p 56s 88s + 7s，立直平和断幺宝牌1
+	d.msg = &tenhouMessage{
+		Tag:         "AGARI",
+		Who:         "1",
+		FromWho:     "0",
+		Hai:         "4,8,12,44,48,53,56,60,64,89,92,96,100,101",
+		Machi:       "96",
+		Ten:         "30,7700,0",
+		Yaku:        "1,1,7,1,8,1,52,1,53,0",
+		DoraTile:    "0",
+		UraDoraTile: "116",
+		Score:       "250,-77,250,77,250,0,250,0",
+	}
+	results, deltaPoints := d.ParseRoundWin()
+	if len(results) != 1 || !reflect.DeepEqual(deltaPoints, []int{-7700, 7700, 0, 0}) {
+		t.Fatal("和牌解析有误", results, deltaPoints)
+	}
+	r := results[0]
+	if r.Who != 1 || r.FromWho != 0 || r.isTsumo() || r.WinTile != 24 || r.Fu != 30 || r.Han != 4 || r.Point != 7700 {
+		t.Fatal("和牌信息有误", *r)
+	}
+	if r.Dora != 1 || r.UraDora != 0 || r.AkaDora != 0 || len(r.Yakus) != 3 || r.Yakus[1].YakuType != util.YakuPinfu || r.Yakus[1].Han != 1 {
+		t.Fatal("役有误", *r)
+	}
+	if hand := r.handStr(); hand != "234m 345678p 56788s" {
+		t.Fatal("手牌有误", hand)
+	}
+	if msg := d.checkWinResult(r); msg != "" {
+		t.Fatal("打点校验不应有误", msg)
+	}
+
+	// 符数不一致
+	r.Fu = 40
+	if msg := d.checkWinResult(r); msg == "" {
+		t.Fatal("打点校验应当有误")
+	}
+
+	// 役满不校验
+	d.msg = &tenhouMessage{Tag: "AGARI", Who: "1", FromWho: "1", Hai: "4,8,12,44,48,53,56,60,64,89,92,96,100,101", Machi: "96", Ten: "0,48000,5", Yakuman: "37"}
+	results, _ = d.ParseRoundWin()
+	if r := results[0]; r.YakumanTimes != 1 || r.Han != 0 || !r.isTsumo() || d.checkWinResult(r) != "" {
+		t.Fatal("役满解析有误", *r)
+	}
+}
+
+func TestMajsoulWinResult(t *testing.T) {
+	debugMode = true
+
+	d := &majsoulRoundData{accountID: 100}
+	d.roundData = newRoundData(d, 0, 0)
+	for _, raw := range []string{
+		`{"is_game_start":false,"seat_list":[200,100,300,400],"game_config":{"mode":{"mode":2}}}`,
+		`{"chang":0,"ju":0,"ben":0,"tiles":["1m","3m","7m","3p","6p","7p","6s","1z","1z","2z","3z","4z","7z"],"dora":"1m","scores":[25000,25000,25000,25000],"liqibang":0,"md5":"abc"}`,
+		`{"seat":0,"tile":"4p","is_liqi":false,"moqie":false,"is_wliqi":false}`,
+	} {
+		msg := &majsoulMessage{}
+		if err := json.Unmarshal([]byte(raw), msg); err != nil {
+			t.Fatal(err)
+		}
+		d.msg = msg
+		if err := d.analysis(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 座位 2（下家）自摸平和断幺宝牌1，座位 3（对家）荣和有副露的手牌
+	msg := &majsoulMessage{}
+	raw := `{"hules":[` +
+		`{"hand":["2m","3m","4m","3p","4p","5p","6p","7p","8p","5s","6s","8s","8s"],"hu_tile":"7s","seat":2,"zimo":true,"doras":["1m"],"count":4,"fu":20,"fans":[{"name":"门前清自摸和","val":1},{"name":"平和","val":1},{"name":"断幺九","val":1},{"name":"宝牌","val":1}],"point_zimo_qin":2600,"point_zimo_xian":1300},` +
+		`{"hand":["2m","3m","4m","3p","5p","8s","8s"],"ming":["kezi(5z,5z,5z)","shunzi(0s,4s,6s)"],"hu_tile":"4p","seat":3,"zimo":false,"doras":["1m"],"count":3,"fu":30,"fans":[{"name":"役牌 白","val":1},{"name":"宝牌","val":1},{"name":"红宝牌","val":1}],"point_rong":3900}` +
+		`],"delta_scores":[-5200,0,5200,0]}`
+	if err := json.Unmarshal([]byte(raw), msg); err != nil {
+		t.Fatal(err)
+	}
+	d.msg = msg
+	results, deltaPoints := d.ParseRoundWin()
+	if len(results) != 2 || !reflect.DeepEqual(deltaPoints, []int{0, 5200, 0, -5200}) {
+		t.Fatal("和牌解析有误", results, deltaPoints)
+	}
+
+	r := results[0]
+	if r.Who != 1 || !r.isTsumo() || r.WinTile != 24 || r.Han != 4 || r.Fu != 20 || r.Point != 5200 || r.Dora != 1 || len(r.Yakus) != 3 {
+		t.Fatal("自摸信息有误", *r)
+	}
+	if msg := d.checkWinResult(r); msg != "" {
+		t.Fatal("打点校验不应有误", msg)
+	}
+
+	r = results[1]
+	if r.Who != 2 || r.FromWho != 3 || r.Point != 3900 || r.AkaDora != 1 || len(r.Melds) != 2 || r.Melds[0].MeldType != meldTypePon || !r.Melds[1].ContainRedFive || r.NumRedFives[2] != 1 {
+		t.Fatal("荣和信息有误", *r)
+	}
+	if r.Yakus[0].YakuType != util.YakuYakuhai {
+		t.Fatal("役牌有误", r.Yakus[0].Name)
+	}
+	if msg := d.checkWinResult(r); msg != "" {
+		t.Fatal("打点校验不应有误", msg)
+	}
+}